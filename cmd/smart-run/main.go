@@ -2,15 +2,23 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/awaistahir/smart-run/internal/carbon"
 	"github.com/awaistahir/smart-run/internal/engine"
 	"github.com/awaistahir/smart-run/internal/prices"
 	"github.com/awaistahir/smart-run/internal/store"
+	"github.com/awaistahir/smart-run/internal/weather"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -18,6 +26,8 @@ import (
 var (
 	cfgFile string
 	dbPath  string
+	// warnings reports what the price and weather clients work around
+	warnings = log.New(os.Stderr, "Warning: ", 0)
 )
 
 func main() {
@@ -37,6 +47,12 @@ your household appliances based on Octopus Agile pricing.`,
 	rootCmd.AddCommand(planCmd())
 	rootCmd.AddCommand(initCmd())
 	rootCmd.AddCommand(applianceCmd())
+	rootCmd.AddCommand(batteryCmd())
+	rootCmd.AddCommand(tariffCmd())
+	rootCmd.AddCommand(accountCmd())
+	rootCmd.AddCommand(runCmd())
+	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(backtestCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -82,24 +98,36 @@ func fetchCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
-			client := prices.NewOctopusClient(region)
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return fmt.Errorf("opening database: %w", err)
+			}
+			defer st.Close()
+
+			provider, err := priceSource(st, region)
+			if err != nil {
+				return err
+			}
 
 			var priceSlots []engine.PriceSlot
-			var err error
+			var stale bool
 
 			if date == "today" {
-				priceSlots, err = client.FetchTodayAndTomorrow(ctx, region)
+				priceSlots, stale, err = provider.FetchTodayAndTomorrow(ctx)
 			} else {
 				day, parseErr := time.Parse("2006-01-02", date)
 				if parseErr != nil {
 					return fmt.Errorf("invalid date format (use YYYY-MM-DD): %w", parseErr)
 				}
-				priceSlots, err = client.HalfHourly(ctx, day, region)
+				priceSlots, stale, err = provider.HalfHourly(ctx, day)
 			}
 
 			if err != nil {
 				return err
 			}
+			if stale {
+				fmt.Fprintln(os.Stderr, "Warning: prices unavailable, showing cached prices")
+			}
 
 			// Output as JSON
 			enc := json.NewEncoder(os.Stdout)
@@ -108,7 +136,7 @@ func fetchCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&region, "region", "r", "C", "Octopus region (A-P)")
+	cmd.Flags().StringVarP(&region, "region", "r", "", "Octopus region (A-P; defaults to the household's)")
 	cmd.Flags().StringVarP(&date, "date", "d", "today", "Date to fetch (YYYY-MM-DD or 'today')")
 
 	return cmd
@@ -118,6 +146,10 @@ func planCmd() *cobra.Command {
 	var region string
 	var lat, lon float64
 	var applianceID string
+	var perAppliance bool
+	var maxKW float64
+	var stepMinutes int
+	var soc float64
 
 	cmd := &cobra.Command{
 		Use:   "plan",
@@ -133,10 +165,9 @@ func planCmd() *cobra.Command {
 			defer st.Close()
 
 			// Fetch prices
-			pricesClient := prices.NewOctopusClient(region)
-			priceSlots, err := pricesClient.FetchTodayAndTomorrow(ctx, region)
+			priceSlots, err := fetchPrices(ctx, st, region)
 			if err != nil {
-				return fmt.Errorf("fetching prices: %w", err)
+				return err
 			}
 
 			fmt.Fprintf(os.Stderr, "Fetched %d price slots\n", len(priceSlots))
@@ -147,6 +178,47 @@ func planCmd() *cobra.Command {
 				return fmt.Errorf("getting household: %w (run 'smart-run init' first)", err)
 			}
 
+			if cmd.Flags().Changed("step") {
+				household.StartStepMinutes = stepMinutes
+			}
+			if region == "" {
+				region = household.Region
+			}
+
+			// Fetch grid carbon intensity when the household weights it
+			var carbonSlots []engine.CarbonSlot
+			if household.CarbonWeight > 0 {
+				carbonSlots, err = carbon.NewIntensityClient().ForPriceSlots(ctx, priceSlots, region)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: carbon intensity unavailable - %v\n", err)
+				}
+			}
+
+			// Forecast solar generation when the household has panels
+			var pvSlots []engine.PVSlot
+			if household.PVKWp > 0 {
+				if !cmd.Flags().Changed("lat") {
+					lat = household.Latitude
+				}
+				if !cmd.Flags().Changed("lon") {
+					lon = household.Longitude
+				}
+				array := weather.PVArray{KWp: household.PVKWp, TiltDeg: household.PVTiltDeg, AzimuthDeg: household.PVAzimuthDeg}
+				pvSlots, err = weather.NewOpenMeteoClient(lat, lon).PVForecast(ctx, array, 2)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: solar forecast unavailable - %v\n", err)
+				}
+			}
+
+			// Solar used at home is solar not sold, so cost it at the export price
+			var exportSlots []engine.PriceSlot
+			if len(pvSlots) > 0 {
+				exportSlots, err = exportPrices(ctx, st, household, region)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: export prices unavailable - %v\n", err)
+				}
+			}
+
 			// Get appliances
 			appliances, err := st.GetAppliances(household.ID)
 			if err != nil {
@@ -171,10 +243,94 @@ func planCmd() *cobra.Command {
 				}
 			}
 
-			// Generate recommendations for each appliance
+			// Today's state of charge for EVs, when given on the command line
+			if cmd.Flags().Changed("soc") {
+				for _, a := range appliances {
+					if a.Class == engine.ClassEV {
+						a.SoCPercent = soc
+					}
+				}
+			}
+
+			// Plan all appliances together within the household power limit, so
+			// they don't all pile into the same cheap slots
+			if !perAppliance {
+				if cmd.Flags().Changed("max-kw") {
+					household.MaxImportKW = maxKW
+				}
+
+				loads := []engine.HouseholdLoad{}
+				for _, a := range appliances {
+					if !a.Enabled {
+						continue
+					}
+
+					constraints := engine.Constraints{
+						Allowed:          a.AllowedWindows,
+						Blocked:          a.BlockedWindows,
+						QuietHours:       household.QuietHours,
+						FinishBy:         a.FinishBy,
+						StartBy:          a.StartBy,
+						PriceCapPence:    a.PriceCapPencePerKWh,
+						NoiseLevel:       a.NoiseLevel,
+						ToleranceMinutes: a.ToleranceMinutes,
+					}
+
+					opts := engine.Options{
+						EstKWh:           a.EstKWh,
+						CarbonWeight:     household.CarbonWeight,
+						CarbonIntensity:  carbonSlots,
+						PVWeight:         household.PVWeight,
+						PVForecast:       pvSlots,
+						ExportPrices:     exportSlots,
+						PowerProfile:     a.PowerProfile,
+						StartStepMinutes: household.StartStepMinutes,
+						Interruptible:    engine.InterruptibleFor(a),
+						LatePenaltyPence: household.LatePenaltyPence,
+					}
+
+					ev, err := engine.EVChargeFor(a, time.Now())
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: %s - %v\n", a.Name, err)
+						continue
+					}
+					if ev != nil {
+						ev.Apply(&constraints, &opts)
+					}
+
+					loads = append(loads, engine.HouseholdLoad{Appliance: a, Constraints: constraints, Options: opts, EVCharge: ev})
+				}
+
+				// Only plan over slots that haven't started yet
+				upcoming := []engine.PriceSlot{}
+				for _, slot := range priceSlots {
+					if slot.Start.After(time.Now()) {
+						upcoming = append(upcoming, slot)
+					}
+				}
+				if len(upcoming) == 0 {
+					return fmt.Errorf("no upcoming price slots available")
+				}
+
+				plan, err := engine.PlanHousehold(upcoming, loads, household)
+				if err != nil {
+					return fmt.Errorf("planning household: %w", err)
+				}
+
+				for _, u := range plan.Unscheduled {
+					fmt.Fprintf(os.Stderr, "Warning: %s - %s\n", u.ApplianceName, u.Reason)
+				}
+
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(plan)
+			}
+
+			// Each appliance's own best windows, as if it ran alone
 			type applianceRec struct {
-				Appliance       string                   `json:"appliance"`
-				Recommendations []engine.Recommendation  `json:"recommendations"`
+				Appliance       string                  `json:"appliance"`
+				Recommendations []engine.Recommendation `json:"recommendations"`
+				EVCharge        *engine.EVChargePlan    `json:"ev_charge,omitempty"`
 			}
 
 			results := []applianceRec{}
@@ -185,18 +341,51 @@ func planCmd() *cobra.Command {
 				}
 
 				constraints := engine.Constraints{
-					Allowed:       a.AllowedWindows,
-					Blocked:       a.BlockedWindows,
-					QuietHours:    household.QuietHours,
-					FinishBy:      a.FinishBy,
-					StartBy:       a.StartBy,
-					PriceCapPence: a.PriceCapPencePerKWh,
-					NoiseLevel:    a.NoiseLevel,
+					Allowed:          a.AllowedWindows,
+					Blocked:          a.BlockedWindows,
+					QuietHours:       household.QuietHours,
+					FinishBy:         a.FinishBy,
+					StartBy:          a.StartBy,
+					PriceCapPence:    a.PriceCapPencePerKWh,
+					NoiseLevel:       a.NoiseLevel,
+					ToleranceMinutes: a.ToleranceMinutes,
 				}
 
 				opts := engine.Options{
-					EstKWh:       a.EstKWh,
-					CarbonWeight: household.CarbonWeight,
+					EstKWh:           a.EstKWh,
+					CarbonWeight:     household.CarbonWeight,
+					CarbonIntensity:  carbonSlots,
+					PVWeight:         household.PVWeight,
+					PVForecast:       pvSlots,
+					ExportPrices:     exportSlots,
+					PowerProfile:     a.PowerProfile,
+					StartStepMinutes: household.StartStepMinutes,
+					Interruptible:    engine.InterruptibleFor(a),
+					LatePenaltyPence: household.LatePenaltyPence,
+				}
+
+				// EVs charge to a state-of-charge target by their ready-by time
+				if a.Class == engine.ClassEV {
+					ev, err := engine.EVChargeFor(a, time.Now())
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: %s - %v\n", a.Name, err)
+						continue
+					}
+					plan, err := engine.PlanEVCharge(priceSlots, *ev, constraints, opts)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: %s - %v\n", a.Name, err)
+						continue
+					}
+					if plan.Warning != "" {
+						fmt.Fprintf(os.Stderr, "Warning: %s - %s\n", a.Name, plan.Warning)
+					}
+
+					rec := applianceRec{Appliance: a.Name, Recommendations: []engine.Recommendation{}, EVCharge: plan}
+					if plan.Recommendation != nil {
+						rec.Recommendations = append(rec.Recommendations, *plan.Recommendation)
+					}
+					results = append(results, rec)
+					continue
 				}
 
 				recs, err := engine.BestWindows(priceSlots, a.CycleMinutes, constraints, opts, 3)
@@ -218,10 +407,14 @@ func planCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&region, "region", "r", "C", "Octopus region")
+	cmd.Flags().StringVarP(&region, "region", "r", "", "Octopus region (defaults to the household's)")
 	cmd.Flags().Float64Var(&lat, "lat", 51.5074, "Latitude for weather")
 	cmd.Flags().Float64Var(&lon, "lon", -0.1278, "Longitude for weather")
 	cmd.Flags().StringVarP(&applianceID, "appliance", "a", "", "Specific appliance ID (optional)")
+	cmd.Flags().Float64Var(&soc, "soc", 0, "Current EV state of charge in percent (default from appliance settings)")
+	cmd.Flags().IntVar(&stepMinutes, "step", 30, "Candidate start granularity in minutes (e.g. 5, 15, 30)")
+	cmd.Flags().BoolVar(&perAppliance, "per-appliance", false, "List each appliance's best windows on its own instead of one household schedule")
+	cmd.Flags().Float64Var(&maxKW, "max-kw", 0, "Household import limit in kW (default from household settings)")
 
 	return cmd
 }
@@ -243,11 +436,21 @@ func initCmd() *cobra.Command {
 				Region:    "C",       // Default to London region
 				Latitude:  51.5074,   // Default to London latitude
 				Longitude: -0.1278,   // Default to London longitude
+				Tariff:    engine.TariffAgile,
 				QuietHours: []engine.TimeWindow{
 					{Start: "22:00", End: "07:00", DaysOfWeek: []int{1, 2, 3, 4, 5, 6, 7}},
 				},
 				StaggerHeavyLoads: true,
+				StaggerGapMinutes: 30,
+				LatePenaltyPence:  0.5,
+				PriceCapPence:     26.35,
 				CarbonWeight:      0.0,
+				PVTiltDeg:         35,
+				PVAzimuthDeg:      180,
+				PVWeight:          1.0,
+
+				BatteryEfficiency:     0.9,
+				BatteryReservePercent: 10,
 			}
 
 			if err := st.SaveHousehold(household); err != nil {
@@ -273,6 +476,7 @@ func applianceCmd() *cobra.Command {
 
 	cmd.AddCommand(applianceAddCmd())
 	cmd.AddCommand(applianceListCmd())
+	cmd.AddCommand(applianceProfileCmd())
 
 	return cmd
 }
@@ -283,6 +487,14 @@ func applianceAddCmd() *cobra.Command {
 	var estKWh float64
 	var noiseLevel int
 	var priority int
+	var ratedKW float64
+	var tolerance int
+	var interruptible bool
+	var minBlock int
+	var maxInterruptions int
+	var batteryKWh float64
+	var soc, targetSoC float64
+	var readyBy string
 
 	cmd := &cobra.Command{
 		Use:   "add",
@@ -295,13 +507,31 @@ func applianceAddCmd() *cobra.Command {
 			defer st.Close()
 
 			appliance := &engine.Appliance{
-				ID:           fmt.Sprintf("%s-%d", name, time.Now().Unix()),
-				Name:         name,
-				CycleMinutes: cycleMin,
-				EstKWh:       estKWh,
-				NoiseLevel:   noiseLevel,
-				Priority:     priority,
-				Enabled:      true,
+				ID:               fmt.Sprintf("%s-%d", name, time.Now().Unix()),
+				Name:             name,
+				CycleMinutes:     cycleMin,
+				ToleranceMinutes: tolerance,
+				EstKWh:           estKWh,
+				NoiseLevel:       noiseLevel,
+				Priority:         priority,
+				RatedKW:          ratedKW,
+				Enabled:          true,
+				Interruptible:    interruptible,
+				MinBlockMinutes:  minBlock,
+				MaxInterruptions: maxInterruptions,
+				BatteryKWh:       batteryKWh,
+				SoCPercent:       soc,
+				TargetSoCPercent: targetSoC,
+				ReadyBy:          readyBy,
+			}
+
+			// A battery capacity makes this an EV charged to a target
+			if batteryKWh > 0 {
+				if ratedKW <= 0 {
+					return fmt.Errorf("an EV needs --rated-kw set to the charger power")
+				}
+				appliance.Class = engine.ClassEV
+				appliance.ControlType = engine.ControlSmart
 			}
 
 			if err := st.SaveAppliance(appliance, "default"); err != nil {
@@ -312,6 +542,12 @@ func applianceAddCmd() *cobra.Command {
 			fmt.Printf("  ID: %s\n", appliance.ID)
 			fmt.Printf("  Cycle: %d minutes\n", cycleMin)
 			fmt.Printf("  Est. consumption: %.2f kWh\n", estKWh)
+			if interruptible {
+				fmt.Printf("  Interruptible: min block %d minutes\n", minBlock)
+			}
+			if appliance.Class == engine.ClassEV {
+				fmt.Printf("  EV: %.0f kWh battery, charge to %.0f%% by %s\n", batteryKWh, targetSoC, readyBy)
+			}
 
 			return nil
 		},
@@ -322,6 +558,15 @@ func applianceAddCmd() *cobra.Command {
 	cmd.Flags().Float64VarP(&estKWh, "kwh", "k", 1.0, "Estimated kWh consumption")
 	cmd.Flags().IntVar(&noiseLevel, "noise", 3, "Noise level (1-5)")
 	cmd.Flags().IntVar(&priority, "priority", 3, "Priority (1-5)")
+	cmd.Flags().Float64Var(&ratedKW, "rated-kw", 0, "Peak power draw in kW (optional, used for staggering)")
+	cmd.Flags().IntVar(&tolerance, "tolerance", 0, "Minutes the deadline may be overrun when the saving beats the late penalty")
+	cmd.Flags().BoolVar(&interruptible, "interruptible", false, "Can pause and resume, e.g. EV charger or storage heater")
+	cmd.Flags().IntVar(&minBlock, "min-block", 0, "Shortest run in minutes once started (interruptible only)")
+	cmd.Flags().IntVar(&maxInterruptions, "max-interruptions", 0, "Most pauses allowed, 0 = unlimited (interruptible only)")
+	cmd.Flags().Float64Var(&batteryKWh, "battery-kwh", 0, "EV battery capacity in kWh (makes this an EV)")
+	cmd.Flags().Float64Var(&soc, "soc", 0, "EV state of charge now, in percent")
+	cmd.Flags().Float64Var(&targetSoC, "target-soc", 80, "EV state of charge wanted by --ready-by, in percent")
+	cmd.Flags().StringVar(&readyBy, "ready-by", "07:00", "Time the EV has to be charged by (HH:mm)")
 
 	cmd.MarkFlagRequired("name")
 
@@ -366,6 +611,1120 @@ func applianceListCmd() *cobra.Command {
 	}
 }
 
+func applianceProfileCmd() *cobra.Command {
+	var applianceID string
+	var csvPath string
+	var segmentMinutes int
+
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Import a power profile from smart-plug readings (CSV)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			appliance, err := st.GetAppliance(applianceID)
+			if err != nil {
+				return fmt.Errorf("appliance not found: %s", applianceID)
+			}
+
+			f, err := os.Open(csvPath)
+			if err != nil {
+				return fmt.Errorf("opening CSV: %w", err)
+			}
+			defer f.Close()
+
+			profile, err := engine.ParsePowerProfileCSV(f, segmentMinutes)
+			if err != nil {
+				return err
+			}
+
+			// The profile is the cycle: its length and energy replace the estimates
+			previousCycle := appliance.CycleMinutes
+			appliance.PowerProfile = profile
+			appliance.EstKWh = engine.ProfileKWh(profile)
+			appliance.CycleMinutes = engine.ProfileMinutes(profile)
+
+			if err := st.SaveAppliance(appliance, "default"); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Imported power profile for %s\n", appliance.Name)
+			fmt.Printf("  Segments: %d x %d minutes\n", len(profile), segmentMinutes)
+			fmt.Printf("  Duration: %d minutes (cycle was %d)\n", appliance.CycleMinutes, previousCycle)
+			fmt.Printf("  Energy: %.2f kWh\n", appliance.EstKWh)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&applianceID, "appliance", "a", "", "Appliance ID (required)")
+	cmd.Flags().StringVar(&csvPath, "csv", "", "CSV of timestamp,power_w readings (required)")
+	cmd.Flags().IntVar(&segmentMinutes, "segment", 5, "Profile segment length in minutes")
+
+	cmd.MarkFlagRequired("appliance")
+	cmd.MarkFlagRequired("csv")
+
+	return cmd
+}
+
+func batteryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "battery",
+		Short: "Plan home battery charging and discharging",
+	}
+
+	cmd.AddCommand(batterySetCmd())
+	cmd.AddCommand(batteryPlanCmd())
+
+	return cmd
+}
+
+func batterySetCmd() *cobra.Command {
+	var capacity, chargeKW, dischargeKW, efficiency, reserve float64
+	var baseLoad []float64
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Configure the household battery",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			household, err := st.GetHousehold("default")
+			if err != nil {
+				return fmt.Errorf("getting household: %w (run 'smart-run init' first)", err)
+			}
+
+			if cmd.Flags().Changed("capacity") {
+				household.BatteryKWh = capacity
+			}
+			if cmd.Flags().Changed("charge-kw") {
+				household.BatteryChargeKW = chargeKW
+			}
+			if cmd.Flags().Changed("discharge-kw") {
+				household.BatteryDischargeKW = dischargeKW
+			}
+			if cmd.Flags().Changed("efficiency") {
+				household.BatteryEfficiency = efficiency
+			}
+			if cmd.Flags().Changed("reserve") {
+				household.BatteryReservePercent = reserve
+			}
+			if cmd.Flags().Changed("base-load") {
+				household.BaseLoadKW = baseLoad
+			}
+
+			if err := st.SaveHousehold(household); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Battery: %.1f kWh, charge %.1f kW, discharge %.1f kW\n",
+				household.BatteryKWh, household.BatteryChargeKW, household.BatteryDischargeKW)
+			fmt.Printf("  Round-trip efficiency: %.0f%%, reserve %.0f%%\n",
+				household.BatteryEfficiency*100, household.BatteryReservePercent)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Float64Var(&capacity, "capacity", 0, "Usable capacity in kWh")
+	cmd.Flags().Float64Var(&chargeKW, "charge-kw", 0, "Maximum charge rate in kW")
+	cmd.Flags().Float64Var(&dischargeKW, "discharge-kw", 0, "Maximum discharge rate in kW")
+	cmd.Flags().Float64Var(&efficiency, "efficiency", 0.9, "Round-trip efficiency (0-1)")
+	cmd.Flags().Float64Var(&reserve, "reserve", 10, "Charge to keep back, in percent")
+	cmd.Flags().Float64SliceVar(&baseLoad, "base-load", nil, "Baseline household draw in kW per half hour from midnight (repeats if shorter than 48)")
+
+	return cmd
+}
+
+func tariffCmd() *cobra.Command {
+	var tariffType, region, file, product, export string
+	var rate, exportRate float64
+
+	cmd := &cobra.Command{
+		Use:   "tariff",
+		Short: "Choose how the household's electricity is priced",
+		Long: `Sets the tariff prices are planned against: "agile" for Octopus Agile
+half-hourly prices in your region, "flat" for one unit rate all day, or "tou"
+for fixed time-of-use bands such as Octopus Go or Economy 7, read from a YAML
+file (see the tariffs directory). --export sets what exported solar earns,
+"agile" for Agile Outgoing or "flat" for a fixed export rate, so planning
+counts solar used at home as export given up. With no flags it shows the
+current tariff.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			household, err := st.GetHousehold("default")
+			if err != nil {
+				return fmt.Errorf("getting household: %w (run 'smart-run init' first)", err)
+			}
+
+			if cmd.Flags().Changed("type") {
+				household.Tariff = engine.TariffType(tariffType)
+			}
+			if cmd.Flags().Changed("region") {
+				household.Region = region
+			}
+			if cmd.Flags().Changed("rate") {
+				household.FlatRatePence = rate
+			}
+			if cmd.Flags().Changed("product") {
+				household.AgileProduct = product
+				if product == "auto" {
+					household.AgileProduct = ""
+				}
+			}
+			if file != "" {
+				definition, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("reading tariff file: %w", err)
+				}
+				household.TariffDefinition = string(definition)
+				if !cmd.Flags().Changed("type") {
+					household.Tariff = engine.TariffTOU
+				}
+			}
+			if cmd.Flags().Changed("export") {
+				household.ExportTariff = engine.TariffType(export)
+				if export == "none" {
+					household.ExportTariff = ""
+				}
+			}
+			if cmd.Flags().Changed("export-rate") {
+				household.ExportRatePence = exportRate
+			}
+			if _, err := prices.ForHousehold(household, nil); err != nil {
+				return err
+			}
+			if _, err := prices.ExportForHousehold(household, nil); err != nil {
+				return err
+			}
+
+			if cmd.Flags().NFlag() > 0 {
+				if err := st.SaveHousehold(household); err != nil {
+					return err
+				}
+			}
+
+			switch household.Tariff {
+			case engine.TariffFlat:
+				fmt.Printf("✓ Tariff: flat rate, %.2fp/kWh\n", household.FlatRatePence)
+			case engine.TariffTOU:
+				tariff, _ := prices.ParseTOUTariff([]byte(household.TariffDefinition))
+				fmt.Printf("✓ Tariff: %s, standard rate %.2fp/kWh\n", tariff.Name, tariff.Rate)
+				for _, b := range tariff.Bands {
+					days := "every day"
+					if len(b.Days) > 0 {
+						days = fmt.Sprintf("days %v", b.Days)
+					}
+					fmt.Printf("  %-14s %s-%s %6.2fp/kWh  %s\n", b.Name, b.Start, b.End, b.Rate, days)
+				}
+			default:
+				client := prices.NewAgileClient(household, st)
+				client.SetLogger(warnings)
+				code, source := client.Product(cmd.Context())
+				fmt.Printf("✓ Tariff: Octopus Agile, region %s, product %s (%s)\n", household.Region, code, source)
+			}
+
+			switch household.ExportTariff {
+			case engine.TariffAgile:
+				client := prices.NewAgileExportClient(household, st)
+				client.SetLogger(warnings)
+				code, source := client.Product(cmd.Context())
+				fmt.Printf("✓ Export: Agile Outgoing, product %s (%s)\n", code, source)
+			case engine.TariffFlat:
+				fmt.Printf("✓ Export: flat rate, %.2fp/kWh\n", household.ExportRatePence)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tariffType, "type", "t", "agile", "Tariff type: agile, flat or tou")
+	cmd.Flags().StringVarP(&region, "region", "r", "C", "Octopus region (A-P)")
+	cmd.Flags().Float64Var(&rate, "rate", 0, "Unit rate in p/kWh for a flat tariff")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "YAML time-of-use tariff definition (implies --type tou)")
+	cmd.Flags().StringVar(&product, "product", "", "Agile product code to pin, or 'auto' to discover the current one")
+	cmd.Flags().StringVar(&export, "export", "", "Export tariff: agile (Agile Outgoing), flat or none")
+	cmd.Flags().Float64Var(&exportRate, "export-rate", 0, "Export rate in p/kWh for a flat export tariff")
+
+	return cmd
+}
+
+func accountCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "account",
+		Short: "Link an Octopus account for its tariff and smart-meter readings",
+	}
+
+	cmd.AddCommand(accountLinkCmd())
+	cmd.AddCommand(accountSyncCmd())
+
+	return cmd
+}
+
+func accountLinkCmd() *cobra.Command {
+	var number, apiKey string
+	var days int
+
+	cmd := &cobra.Command{
+		Use:   "link",
+		Short: "Remember an Octopus account and API key, then sync it",
+		Long: `Links an Octopus Energy account using the API key from the dashboard
+(Personal details > API access). The account's electricity agreement sets the
+household's region and Agile product, and half-hourly smart-meter readings are
+kept so logged runs can be compared with metered use.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			if err := st.SetSetting(prices.AccountNumberSetting, number); err != nil {
+				return err
+			}
+			if err := st.SetSetting(prices.AccountKeySetting, apiKey); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Linked Octopus account %s\n", number)
+
+			return syncAccount(cmd.Context(), st, prices.NewOctopusAccount(apiKey, number), days)
+		},
+	}
+
+	cmd.Flags().StringVar(&number, "account", "", "Octopus account number (A-1234ABCD)")
+	cmd.Flags().StringVar(&apiKey, "api-key", "", "Octopus API key (sk_live_...)")
+	cmd.Flags().IntVar(&days, "days", 30, "Days of meter readings to fetch the first time")
+	cmd.MarkFlagRequired("account")
+	cmd.MarkFlagRequired("api-key")
+
+	return cmd
+}
+
+func accountSyncCmd() *cobra.Command {
+	var days int
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Refresh the tariff and fetch new smart-meter readings",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			number, _, err := st.GetSetting(prices.AccountNumberSetting)
+			if err != nil {
+				return fmt.Errorf("no Octopus account linked (use 'smart-run account link')")
+			}
+			apiKey, _, err := st.GetSetting(prices.AccountKeySetting)
+			if err != nil {
+				return fmt.Errorf("no Octopus API key saved (use 'smart-run account link')")
+			}
+
+			return syncAccount(cmd.Context(), st, prices.NewOctopusAccount(apiKey, number), days)
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 30, "Days of meter readings to fetch when none are held yet")
+
+	return cmd
+}
+
+// syncAccount applies an account's agreements to the household and stores new
+// meter readings, reporting what it found
+func syncAccount(ctx context.Context, st *store.Store, account *prices.OctopusAccount, days int) error {
+	household, err := st.GetHousehold("default")
+	if err != nil {
+		return fmt.Errorf("getting household: %w (run 'smart-run init' first)", err)
+	}
+
+	account.SetLogger(warnings)
+	result, err := account.Sync(ctx, st, household, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return fmt.Errorf("syncing Octopus account: %w", err)
+	}
+	if result.Changed {
+		if err := st.SaveHousehold(household); err != nil {
+			return err
+		}
+	}
+
+	for _, ag := range result.Agreements {
+		direction := "Import"
+		if ag.Export {
+			direction = "Export"
+		}
+		fmt.Printf("✓ %s: %s (MPAN %s, meter %s) since %s\n", direction, ag.TariffCode, ag.MPAN,
+			ag.MeterSerial, ag.ValidFrom.Local().Format("02 Jan 2006"))
+	}
+	if result.Changed {
+		fmt.Printf("✓ Household set to region %s, tariff %s\n", household.Region, household.Tariff)
+	}
+	fmt.Printf("✓ Stored %d half-hourly meter readings\n", result.Readings)
+
+	return nil
+}
+
+func batteryPlanCmd() *cobra.Command {
+	var region string
+	var soc float64
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Work out when to force-charge and discharge the battery",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return fmt.Errorf("opening database: %w", err)
+			}
+			defer st.Close()
+
+			household, err := st.GetHousehold("default")
+			if err != nil {
+				return fmt.Errorf("getting household: %w (run 'smart-run init' first)", err)
+			}
+
+			battery := engine.HouseholdBattery(household, soc)
+			if battery == nil {
+				return fmt.Errorf("no battery configured (use 'smart-run battery set --capacity')")
+			}
+
+			priceSlots, err := fetchPrices(ctx, st, region)
+			if err != nil {
+				return err
+			}
+
+			// Only plan from the slot in progress onwards
+			now := time.Now()
+			upcoming := []engine.PriceSlot{}
+			for _, slot := range priceSlots {
+				if slot.End.After(now) {
+					upcoming = append(upcoming, slot)
+				}
+			}
+
+			schedule, err := engine.OptimiseBattery(upcoming, *battery, household.BaseLoadKW)
+			if err != nil {
+				return fmt.Errorf("planning battery: %w", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "Saves £%.2f against £%.2f without the battery\n",
+				schedule.SavingsGBP, schedule.BaselineCostGBP)
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(schedule)
+		},
+	}
+
+	cmd.Flags().StringVarP(&region, "region", "r", "", "Octopus region (defaults to the household's)")
+	cmd.Flags().Float64Var(&soc, "soc", 50, "Battery state of charge now, in percent")
+
+	return cmd
+}
+
+func runCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Log when appliances actually ran and what it cost",
+	}
+
+	cmd.AddCommand(runStartCmd())
+	cmd.AddCommand(runFinishCmd())
+	cmd.AddCommand(runLogCmd())
+
+	return cmd
+}
+
+func runStartCmd() *cobra.Command {
+	var applianceID string
+	var at, recommended, loaded string
+
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Record that an appliance has started",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			appliance, err := st.GetAppliance(applianceID)
+			if err != nil {
+				return fmt.Errorf("appliance not found: %s", applianceID)
+			}
+
+			start, err := parseRunTime(at, time.Now())
+			if err != nil {
+				return err
+			}
+			run := &engine.Run{ApplianceID: appliance.ID, Start: start}
+
+			// A start logged ahead of time (delay timer) was loaded now
+			if loaded != "" {
+				loadedAt, err := parseRunTime(loaded, start)
+				if err != nil {
+					return err
+				}
+				run.LoadedAt = &loadedAt
+			} else if now := time.Now(); start.After(now) {
+				run.LoadedAt = &now
+			}
+
+			if recommended != "" {
+				recStart, err := parseRunTime(recommended, start)
+				if err != nil {
+					return err
+				}
+				run.RecommendedStart = &recStart
+			}
+
+			if err := st.SaveRun(run); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ %s started at %s\n", appliance.Name, start.Local().Format("Mon 15:04"))
+			fmt.Printf("  Run ID: %d\n", run.ID)
+			if run.RecommendedStart != nil {
+				fmt.Printf("  Recommended start: %s\n", run.RecommendedStart.Local().Format("Mon 15:04"))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&applianceID, "appliance", "a", "", "Appliance ID (required)")
+	cmd.Flags().StringVar(&at, "at", "", "Start time, HH:mm today or RFC3339 (default now)")
+	cmd.Flags().StringVar(&recommended, "recommended", "", "Start of the recommendation followed, HH:mm or RFC3339")
+	cmd.Flags().StringVar(&loaded, "loaded", "", "When it was loaded and could have started, HH:mm or RFC3339 (default now if --at is later)")
+
+	cmd.MarkFlagRequired("appliance")
+
+	return cmd
+}
+
+func runFinishCmd() *cobra.Command {
+	var applianceID string
+	var runID int64
+	var at string
+	var kwh float64
+
+	cmd := &cobra.Command{
+		Use:   "finish",
+		Short: "Record that a run has finished and work out what it cost",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			var run *engine.Run
+			switch {
+			case runID != 0:
+				run, err = st.GetRun(runID)
+			case applianceID != "":
+				run, err = st.GetOpenRun(applianceID)
+			default:
+				return fmt.Errorf("give --id or --appliance")
+			}
+			if err != nil {
+				return fmt.Errorf("no matching run in progress")
+			}
+
+			appliance, err := st.GetAppliance(run.ApplianceID)
+			if err != nil {
+				return fmt.Errorf("appliance not found: %s", run.ApplianceID)
+			}
+
+			end, err := parseRunTime(at, time.Now())
+			if err != nil {
+				return err
+			}
+			if !end.After(run.Start) {
+				return fmt.Errorf("run must end after it started (%s)", run.Start.Local().Format("Mon 15:04"))
+			}
+			run.End = &end
+			run.KWh, run.Measured = appliance.EstKWh, false
+			if cmd.Flags().Changed("kwh") {
+				run.KWh, run.Measured = kwh, true
+			}
+
+			// Cost it from cached prices; the run is still logged without them
+			from, to := run.PriceSpan()
+			source, err := priceSource(st, "")
+			var slots []engine.PriceSlot
+			if err == nil {
+				slots, err = source.Prices(ctx, from, to)
+			}
+			if err == nil {
+				err = engine.PriceRun(run, slots)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not cost run: %v\n", err)
+			}
+
+			if err := st.SaveRun(run); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ %s finished at %s (%.0f min, %.2f kWh)\n",
+				appliance.Name, end.Local().Format("Mon 15:04"), run.Duration().Minutes(), run.KWh)
+			if run.Costed {
+				fmt.Printf("  Cost: £%.2f\n", run.CostGBP)
+				if run.RecommendedStart != nil {
+					fmt.Printf("  At the recommended %s: £%.2f (%+.2f versus the advice)\n",
+						run.RecommendedStart.Local().Format("15:04"), run.RecommendedCostGBP, run.VersusAdviceGBP())
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&applianceID, "appliance", "a", "", "Finish this appliance's run in progress")
+	cmd.Flags().Int64Var(&runID, "id", 0, "Run ID to finish")
+	cmd.Flags().StringVar(&at, "at", "", "End time, HH:mm today or RFC3339 (default now)")
+	cmd.Flags().Float64Var(&kwh, "kwh", 0, "Measured energy in kWh (default the appliance estimate)")
+
+	return cmd
+}
+
+func runLogCmd() *cobra.Command {
+	var applianceID string
+	var days int
+
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "List logged runs and how they compare with the recommendations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			runs, err := st.GetRuns(applianceID, time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				return err
+			}
+			if len(runs) == 0 {
+				fmt.Println("No runs logged")
+				return nil
+			}
+
+			// Compare with smart-meter readings when an account is linked
+			matchMeteredRuns(st, runs, days)
+
+			names := make(map[string]string)
+			if appliances, err := st.GetAppliances("default"); err == nil {
+				for _, a := range appliances {
+					names[a.ID] = a.Name
+				}
+			}
+
+			fmt.Printf("%5s %-20s %-16s %6s %7s %8s %8s %11s %9s\n", "ID", "APPLIANCE", "START", "MIN", "KWH", "METERED", "COST", "RECOMMENDED", "VS ADVICE")
+			fmt.Println("----------------------------------------------------------------------------------------------------")
+
+			var total, versus float64
+			for _, run := range runs {
+				name := names[run.ApplianceID]
+				if name == "" {
+					name = run.ApplianceID
+				}
+				name = name[:min(20, len(name))]
+				start := run.Start.Local().Format("Mon 02 Jan 15:04")
+				if !run.Finished() {
+					fmt.Printf("%5d %-20s %-16s %6s\n", run.ID, name, start, "running")
+					continue
+				}
+
+				metered, cost, recommended, vs := "-", "-", "-", "-"
+				if run.Metered {
+					metered = fmt.Sprintf("%.2f", run.MeteredKWh)
+				}
+				if run.RecommendedStart != nil {
+					recommended = run.RecommendedStart.Local().Format("15:04")
+				}
+				if run.Costed {
+					cost = fmt.Sprintf("£%.2f", run.CostGBP)
+					total += run.CostGBP
+					if run.RecommendedStart != nil {
+						vs = fmt.Sprintf("%+.2f", run.VersusAdviceGBP())
+						versus += run.VersusAdviceGBP()
+					}
+				}
+				fmt.Printf("%5d %-20s %-16s %6.0f %7.2f %8s %8s %11s %9s\n", run.ID, name, start,
+					run.Duration().Minutes(), run.KWh, metered, cost, recommended, vs)
+			}
+
+			fmt.Printf("\nTotal cost £%.2f (%+.2f versus the recommended times)\n", total, versus)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&applianceID, "appliance", "a", "", "Only this appliance's runs")
+	cmd.Flags().IntVar(&days, "days", 30, "How many days back to list")
+
+	return cmd
+}
+
+func reportCmd() *cobra.Command {
+	var baseline, period, format, applianceID string
+	var rate float64
+	var days int
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report what logged runs saved against a flat rate, the price cap or running immediately",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			household, err := st.GetHousehold("default")
+			if err != nil {
+				return fmt.Errorf("getting household: %w (run 'smart-run init' first)", err)
+			}
+			if !cmd.Flags().Changed("rate") {
+				rate = engine.BaselineRate(household, engine.Baseline(baseline))
+				if rate <= 0 && engine.Baseline(baseline) != engine.BaselineImmediate {
+					return fmt.Errorf("no %s unit rate set (use --rate or the web UI settings)", baseline)
+				}
+			}
+
+			runs, err := st.GetRuns(applianceID, time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				return err
+			}
+
+			names := make(map[string]string)
+			if appliances, err := st.GetAppliances("default"); err == nil {
+				for _, a := range appliances {
+					names[a.ID] = a.Name
+				}
+			}
+
+			report, err := engine.BuildSavingsReport(runs, names, engine.Baseline(baseline), rate, engine.ReportPeriod(period))
+			if err != nil {
+				return err
+			}
+
+			switch format {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			case "csv":
+				return writeSavingsCSV(os.Stdout, report)
+			case "table":
+				printSavingsTable(report)
+				return nil
+			default:
+				return fmt.Errorf("unknown format %q (use table, csv or json)", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&baseline, "baseline", string(engine.BaselinePriceCap), "Compare against: flat, price_cap or immediate")
+	cmd.Flags().Float64Var(&rate, "rate", 0, "Unit rate in p/kWh for flat and price_cap (default from household settings)")
+	cmd.Flags().StringVar(&period, "period", string(engine.PeriodWeek), "Group by week or month")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table, csv or json")
+	cmd.Flags().StringVarP(&applianceID, "appliance", "a", "", "Only this appliance's runs")
+	cmd.Flags().IntVar(&days, "days", 90, "How many days back to report on")
+
+	return cmd
+}
+
+// printSavingsTable writes a savings report as an aligned table
+func printSavingsTable(report *engine.SavingsReport) {
+	against := "starting as soon as loaded"
+	if report.Baseline != engine.BaselineImmediate {
+		against = fmt.Sprintf("%s at %.2fp/kWh", report.Baseline, report.RatePence)
+	}
+	fmt.Printf("Savings against %s, by %s\n\n", against, report.Period)
+
+	fmt.Printf("%-10s %-20s %5s %8s %8s %9s %8s\n", "FROM", "APPLIANCE", "RUNS", "KWH", "COST", "BASELINE", "SAVED")
+	fmt.Println("------------------------------------------------------------------------")
+	row := func(from string, r engine.SavingsRow) {
+		fmt.Printf("%-10s %-20s %5d %8.2f %8s %9s %8s\n", from, r.ApplianceName[:min(20, len(r.ApplianceName))],
+			r.Runs, r.KWh, fmt.Sprintf("£%.2f", r.CostGBP), fmt.Sprintf("£%.2f", r.BaselineGBP), fmt.Sprintf("£%.2f", r.SavingsGBP))
+	}
+	for _, r := range report.Rows {
+		row(r.PeriodStart.Format("2006-01-02"), r)
+	}
+	fmt.Println("------------------------------------------------------------------------")
+	row("", report.Total)
+
+	if report.Skipped > 0 {
+		fmt.Printf("\n%d runs left out (still running or not costed)\n", report.Skipped)
+	}
+}
+
+// writeSavingsCSV writes one line per appliance per period
+func writeSavingsCSV(out io.Writer, report *engine.SavingsReport) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"period_start", "appliance_id", "appliance", "runs", "kwh", "cost_gbp", "baseline_gbp", "savings_gbp"})
+	for _, r := range report.Rows {
+		w.Write([]string{
+			r.PeriodStart.Format("2006-01-02"), r.ApplianceID, r.ApplianceName, strconv.Itoa(r.Runs),
+			fmt.Sprintf("%.3f", r.KWh), fmt.Sprintf("%.4f", r.CostGBP),
+			fmt.Sprintf("%.4f", r.BaselineGBP), fmt.Sprintf("%.4f", r.SavingsGBP),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func backtestCmd() *cobra.Command {
+	var region, from, to, file, applianceID, format string
+	var strategies []string
+
+	cmd := &cobra.Command{
+		Use:   "backtest",
+		Short: "Replay historical prices through the planner to compare strategies",
+		Long: `Replays each day of historical Agile prices, from the price cache or an
+imported file, as if every enabled appliance followed the top recommendation,
+and reports total cost, average p/kWh and constraint violations per strategy.
+
+A strategy is "best" (the default planner), "immediate" (earliest feasible
+window) or a comma-separated list of overrides: step=<minutes>,
+penalty=<pence per late minute>, tolerance=<minutes>, mode=best|immediate.
+For example: --strategy best --strategy step=10 --strategy immediate`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := store.NewStore(dbPath)
+			if err != nil {
+				return fmt.Errorf("opening database: %w", err)
+			}
+			defer st.Close()
+
+			household, err := st.GetHousehold("default")
+			if err != nil {
+				return fmt.Errorf("getting household: %w (run 'smart-run init' first)", err)
+			}
+			if region == "" {
+				region = household.Region
+			}
+
+			parsed := []engine.BacktestStrategy{}
+			for _, spec := range strategies {
+				strategy, err := parseStrategy(spec)
+				if err != nil {
+					return err
+				}
+				parsed = append(parsed, strategy)
+			}
+
+			fromDay, err := time.ParseInLocation("2006-01-02", from, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --from date (use YYYY-MM-DD): %w", err)
+			}
+			toDay, err := time.ParseInLocation("2006-01-02", to, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --to date (use YYYY-MM-DD): %w", err)
+			}
+			end := toDay.AddDate(0, 0, 1)
+
+			// Historical prices from a file, or whatever the cache holds
+			var priceSlots []engine.PriceSlot
+			if file != "" {
+				priceSlots, err = readPriceFile(file)
+				if err != nil {
+					return err
+				}
+				inRange := []engine.PriceSlot{}
+				for _, slot := range priceSlots {
+					if !slot.Start.Before(fromDay) && slot.Start.Before(end) {
+						inRange = append(inRange, slot)
+					}
+				}
+				priceSlots = inRange
+			} else {
+				priceSlots, err = st.GetCachedPricesBetween(region, fromDay, end)
+				if err != nil {
+					return fmt.Errorf("reading cached prices: %w", err)
+				}
+			}
+			if len(priceSlots) == 0 {
+				return fmt.Errorf("no prices between %s and %s (fetch them first or use --file)", from, to)
+			}
+			fmt.Fprintf(os.Stderr, "Replaying %d price slots\n", len(priceSlots))
+
+			appliances, err := st.GetAppliances(household.ID)
+			if err != nil {
+				return fmt.Errorf("getting appliances: %w", err)
+			}
+
+			loads := []engine.HouseholdLoad{}
+			for _, a := range appliances {
+				if !a.Enabled || (applianceID != "" && a.ID != applianceID) {
+					continue
+				}
+				// An EV's charge depends on the car's state each day
+				if a.Class == engine.ClassEV {
+					fmt.Fprintf(os.Stderr, "Skipping %s: EVs aren't backtested\n", a.Name)
+					continue
+				}
+
+				constraints := engine.Constraints{
+					Allowed:          a.AllowedWindows,
+					Blocked:          a.BlockedWindows,
+					QuietHours:       household.QuietHours,
+					FinishBy:         a.FinishBy,
+					StartBy:          a.StartBy,
+					PriceCapPence:    a.PriceCapPencePerKWh,
+					NoiseLevel:       a.NoiseLevel,
+					ToleranceMinutes: a.ToleranceMinutes,
+				}
+				engine.ApplyPracticalConstraints(a, household, &constraints)
+
+				opts := engine.Options{
+					EstKWh:           a.EstKWh,
+					PowerProfile:     a.PowerProfile,
+					StartStepMinutes: household.StartStepMinutes,
+					Interruptible:    engine.InterruptibleFor(a),
+					LatePenaltyPence: household.LatePenaltyPence,
+				}
+
+				loads = append(loads, engine.HouseholdLoad{Appliance: a, Constraints: constraints, Options: opts})
+			}
+			if len(loads) == 0 {
+				return fmt.Errorf("no appliances to backtest")
+			}
+
+			results := []engine.BacktestResult{}
+			for _, strategy := range parsed {
+				results = append(results, engine.Backtest(priceSlots, loads, strategy)...)
+			}
+
+			switch format {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(results)
+			case "table":
+				printBacktestTable(results)
+				return nil
+			default:
+				return fmt.Errorf("unknown format %q (use table or json)", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&region, "region", "r", "", "Octopus region of the cached prices (defaults to the household's)")
+	cmd.Flags().StringVar(&from, "from", "", "First day to replay (YYYY-MM-DD, required)")
+	cmd.Flags().StringVar(&to, "to", "", "Last day to replay (YYYY-MM-DD, required)")
+	cmd.Flags().StringVar(&file, "file", "", "Prices to replay instead of the cache: JSON from 'smart-run fetch' or Octopus CSV")
+	cmd.Flags().StringArrayVar(&strategies, "strategy", []string{"best"}, "Strategy to compare (repeatable)")
+	cmd.Flags().StringVarP(&applianceID, "appliance", "a", "", "Only backtest this appliance")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table or json")
+
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+// priceSource returns the price source for the household's tariff, cached in
+// the store; a non-empty region overrides the household's
+func priceSource(st *store.Store, region string) (*prices.CachingProvider, error) {
+	household, err := st.GetHousehold("default")
+	if err != nil {
+		household = &engine.Household{Region: "C"}
+	}
+	if region != "" {
+		household.Region = region
+	}
+	source, err := prices.ForHousehold(household, st)
+	if err != nil {
+		return nil, err
+	}
+	source.SetLogger(warnings)
+	return source, nil
+}
+
+// fetchPrices returns today's and tomorrow's prices through the price cache,
+// warning when only stale cached prices were available
+func fetchPrices(ctx context.Context, st *store.Store, region string) ([]engine.PriceSlot, error) {
+	source, err := priceSource(st, region)
+	if err != nil {
+		return nil, err
+	}
+	priceSlots, stale, err := source.FetchTodayAndTomorrow(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching prices: %w", err)
+	}
+	if stale {
+		fmt.Fprintln(os.Stderr, "Warning: prices unavailable, planning with cached prices")
+	}
+	return priceSlots, nil
+}
+
+// matchMeteredRuns fills in the smart-meter reading for runs when an Octopus
+// account has been synced; without readings runs are left as they are
+func matchMeteredRuns(st *store.Store, runs []*engine.Run, days int) {
+	mpan, _, err := st.GetSetting(prices.MPANSetting)
+	if err != nil {
+		return
+	}
+	consumption, err := st.GetConsumption(mpan, time.Now().AddDate(0, 0, -days-1), time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: meter readings unavailable: %v\n", err)
+		return
+	}
+
+	var baseLoad []float64
+	if household, err := st.GetHousehold("default"); err == nil {
+		baseLoad = household.BaseLoadKW
+	}
+	engine.MatchMeteredRuns(runs, consumption, baseLoad)
+}
+
+// exportPrices returns today's and tomorrow's export prices for the household,
+// or nil when it has no export tariff; a non-empty region overrides its own
+func exportPrices(ctx context.Context, st *store.Store, household *engine.Household, region string) ([]engine.PriceSlot, error) {
+	h := *household
+	if region != "" {
+		h.Region = region
+	}
+	source, err := prices.ExportForHousehold(&h, st)
+	if err != nil || source == nil {
+		return nil, err
+	}
+	source.SetLogger(warnings)
+	slots, _, err := source.FetchTodayAndTomorrow(ctx)
+	return slots, err
+}
+
+// parseStrategy reads a backtest strategy: "best", "immediate", or
+// comma-separated key=value overrides
+func parseStrategy(spec string) (engine.BacktestStrategy, error) {
+	strategy := engine.BacktestStrategy{Name: spec}
+	for _, part := range strings.Split(spec, ",") {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(part), "=")
+		switch {
+		case key == "best" && !hasValue:
+		case key == "immediate" && !hasValue:
+			strategy.Immediate = true
+		case key == "mode" && (value == "best" || value == "immediate"):
+			strategy.Immediate = value == "immediate"
+		case key == "step":
+			step, err := strconv.Atoi(value)
+			if err != nil || step <= 0 {
+				return strategy, fmt.Errorf("strategy %q: step must be a positive number of minutes", spec)
+			}
+			strategy.StartStepMinutes = step
+		case key == "penalty":
+			penalty, err := strconv.ParseFloat(value, 64)
+			if err != nil || penalty <= 0 {
+				return strategy, fmt.Errorf("strategy %q: penalty must be a positive number of pence", spec)
+			}
+			strategy.LatePenaltyPence = penalty
+		case key == "tolerance":
+			tolerance, err := strconv.Atoi(value)
+			if err != nil || tolerance < 0 {
+				return strategy, fmt.Errorf("strategy %q: tolerance must be a number of minutes", spec)
+			}
+			strategy.ToleranceMinutes = &tolerance
+		default:
+			return strategy, fmt.Errorf("strategy %q: unknown setting %q", spec, part)
+		}
+	}
+	return strategy, nil
+}
+
+// readPriceFile loads historical prices from JSON written by 'smart-run fetch'
+// or an Octopus CSV export
+func readPriceFile(path string) ([]engine.PriceSlot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening price file: %w", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return prices.ReadSlotsCSV(f)
+	}
+
+	var slots []engine.PriceSlot
+	if err := json.NewDecoder(f).Decode(&slots); err != nil {
+		return nil, fmt.Errorf("decoding price file: %w", err)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots, nil
+}
+
+// printBacktestTable writes backtest results with a subtotal per strategy
+func printBacktestTable(results []engine.BacktestResult) {
+	fmt.Printf("%-16s %-20s %5s %8s %8s %7s %7s %6s %6s\n",
+		"STRATEGY", "APPLIANCE", "RUNS", "KWH", "COST", "P/KWH", "LATE", "VIOL", "MISSED")
+	fmt.Println("-----------------------------------------------------------------------------------------")
+
+	row := func(r engine.BacktestResult) {
+		fmt.Printf("%-16s %-20s %5d %8.2f %8s %7.2f %6.0fm %6d %6d\n",
+			r.Strategy[:min(16, len(r.Strategy))], r.ApplianceName[:min(20, len(r.ApplianceName))], r.Runs, r.KWh,
+			fmt.Sprintf("£%.2f", r.CostGBP), r.AvgPencePerKWh, r.LateMinutes, r.Violations, r.Missed)
+	}
+
+	for i := 0; i < len(results); {
+		total := engine.BacktestResult{Strategy: results[i].Strategy, ApplianceName: "Total"}
+		for ; i < len(results) && results[i].Strategy == total.Strategy; i++ {
+			r := results[i]
+			row(r)
+			total.Runs += r.Runs
+			total.KWh += r.KWh
+			total.CostGBP += r.CostGBP
+			total.LateMinutes += r.LateMinutes
+			total.Violations += r.Violations
+			total.Missed += r.Missed
+		}
+		if total.KWh > 0 {
+			total.AvgPencePerKWh = total.CostGBP * 100 / total.KWh
+		}
+		row(total)
+		fmt.Println()
+	}
+}
+
+// parseRunTime reads an RFC3339 time, or an HH:mm time on the same day as
+// ref; empty means ref itself
+func parseRunTime(value string, ref time.Time) (time.Time, error) {
+	if value == "" {
+		return ref, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	tod, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use HH:mm or RFC3339)", value)
+	}
+	ref = ref.Local()
+	return time.Date(ref.Year(), ref.Month(), ref.Day(), tod.Hour(), tod.Minute(), 0, 0, ref.Location()), nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
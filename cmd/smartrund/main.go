@@ -6,15 +6,18 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/awaistahir/smart-run/internal/store"
 	"github.com/awaistahir/smart-run/internal/uiapi"
+	"github.com/awaistahir/smart-run/internal/weather"
 	"github.com/spf13/cobra"
 )
 
 func main() {
 	var port int
 	var dbPath string
+	var weatherTTL time.Duration
 
 	rootCmd := &cobra.Command{
 		Use:   "smartrund",
@@ -35,6 +38,7 @@ func main() {
 
 			// Create server
 			srv := uiapi.NewServer(st)
+			srv.SetWeatherTTL(weatherTTL)
 
 			// Start server
 			addr := fmt.Sprintf(":%d", port)
@@ -50,6 +54,7 @@ func main() {
 
 	rootCmd.Flags().IntVarP(&port, "port", "p", 8080, "HTTP port")
 	rootCmd.Flags().StringVar(&dbPath, "db", "", "Database path")
+	rootCmd.Flags().DurationVar(&weatherTTL, "weather-ttl", weather.DefaultForecastTTL, "How long cached weather forecasts are used before refreshing")
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,147 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+const (
+	carbonIntensityAPIBase = "https://api.carbonintensity.org.uk"
+	// Timestamp format used in Carbon Intensity API paths and responses
+	apiTimeFormat = "2006-01-02T15:04Z"
+)
+
+// regionIDs maps Octopus (DNO) region codes to Carbon Intensity API region IDs
+var regionIDs = map[string]int{
+	"P": 1,  // North Scotland
+	"N": 2,  // South Scotland
+	"G": 3,  // North West England
+	"F": 4,  // North East England
+	"M": 5,  // Yorkshire
+	"D": 6,  // North Wales & Merseyside
+	"K": 7,  // South Wales
+	"E": 8,  // West Midlands
+	"B": 9,  // East Midlands
+	"A": 10, // East England
+	"L": 11, // South West England
+	"H": 12, // South England
+	"C": 13, // London
+	"J": 14, // South East England
+}
+
+// IntensityClient fetches half-hourly grid carbon intensity from the GB Carbon Intensity API
+type IntensityClient struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewIntensityClient creates a new client for the Carbon Intensity API
+func NewIntensityClient() *IntensityClient {
+	return &IntensityClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    carbonIntensityAPIBase,
+	}
+}
+
+// regionalResponse represents the regional intensity API response
+type regionalResponse struct {
+	Data struct {
+		RegionID  int             `json:"regionid"`
+		ShortName string          `json:"shortname"`
+		Data      []intensityItem `json:"data"`
+	} `json:"data"`
+}
+
+type intensityItem struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Intensity struct {
+		Forecast float64  `json:"forecast"`
+		Actual   *float64 `json:"actual"`
+		Index    string   `json:"index"`
+	} `json:"intensity"`
+}
+
+// Regional fetches forecast intensity between from and to for an Octopus region code
+func (c *IntensityClient) Regional(ctx context.Context, from, to time.Time, region string) ([]engine.CarbonSlot, error) {
+	regionID, ok := regionIDs[region]
+	if !ok {
+		return nil, fmt.Errorf("unknown region %q", region)
+	}
+
+	fullURL := fmt.Sprintf("%s/regional/intensity/%s/%s/regionid/%d",
+		c.baseURL, from.UTC().Format(apiTimeFormat), to.UTC().Format(apiTimeFormat), regionID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching carbon intensity: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var regResp regionalResponse
+	if err := json.NewDecoder(resp.Body).Decode(&regResp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	slots := make([]engine.CarbonSlot, 0, len(regResp.Data.Data))
+	for _, item := range regResp.Data.Data {
+		start, err := time.Parse(apiTimeFormat, item.From)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(apiTimeFormat, item.To)
+		if err != nil {
+			continue
+		}
+
+		// Prefer measured intensity where the period has already happened
+		grams := item.Intensity.Forecast
+		if item.Intensity.Actual != nil {
+			grams = *item.Intensity.Actual
+		}
+
+		slots = append(slots, engine.CarbonSlot{
+			Start:       start,
+			End:         end,
+			GramsPerKWh: grams,
+		})
+	}
+
+	return slots, nil
+}
+
+// ForPriceSlots fetches intensity covering the same period as a price series
+func (c *IntensityClient) ForPriceSlots(ctx context.Context, slots []engine.PriceSlot, region string) ([]engine.CarbonSlot, error) {
+	if len(slots) == 0 {
+		return nil, nil
+	}
+
+	from, to := slots[0].Start, slots[0].End
+	for _, s := range slots {
+		if s.Start.Before(from) {
+			from = s.Start
+		}
+		if s.End.After(to) {
+			to = s.End
+		}
+	}
+
+	return c.Regional(ctx, from, to, region)
+}
@@ -0,0 +1,62 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIntensityServer serves a canned regional response in the Carbon Intensity API shape
+func fakeIntensityServer(t *testing.T, forecasts []float64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/regional/intensity/") || !strings.HasSuffix(r.URL.Path, "/regionid/13") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+		items := []string{}
+		for i, f := range forecasts {
+			from := base.Add(time.Duration(i) * 30 * time.Minute)
+			to := from.Add(30 * time.Minute)
+			items = append(items, fmt.Sprintf(`{"from":%q,"to":%q,"intensity":{"forecast":%g,"index":"moderate"}}`,
+				from.Format(apiTimeFormat), to.Format(apiTimeFormat), f))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"regionid":13,"shortname":"London","data":[%s]}}`, strings.Join(items, ","))
+	}))
+}
+
+func TestRegional(t *testing.T) {
+	srv := fakeIntensityServer(t, []float64{180, 120, 95})
+	defer srv.Close()
+
+	c := NewIntensityClient()
+	c.baseURL = srv.URL
+
+	from := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots, err := c.Regional(context.Background(), from, from.Add(90*time.Minute), "C")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(slots) != 3 {
+		t.Fatalf("got %d slots, want 3", len(slots))
+	}
+	if !slots[1].Start.Equal(from.Add(30*time.Minute)) || slots[1].GramsPerKWh != 120 {
+		t.Errorf("unexpected second slot: %+v", slots[1])
+	}
+}
+
+func TestRegionalUnknownRegion(t *testing.T) {
+	c := NewIntensityClient()
+	if _, err := c.Regional(context.Background(), time.Now(), time.Now().Add(time.Hour), "Z"); err == nil {
+		t.Errorf("expected error for unknown region")
+	}
+}
@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"time"
+)
+
+// BacktestStrategy is one way of choosing when to run, compared in a backtest
+type BacktestStrategy struct {
+	Name             string
+	Immediate        bool    // Take the earliest feasible window instead of the best
+	StartStepMinutes int     // Overrides the loads' start step when above 0
+	LatePenaltyPence float64 // Overrides the loads' late penalty when above 0
+	ToleranceMinutes *int    // Overrides the appliances' tolerance when set
+}
+
+// BacktestResult is how one strategy fared for one appliance over the period
+type BacktestResult struct {
+	Strategy       string
+	ApplianceID    string
+	ApplianceName  string
+	Runs           int
+	KWh            float64
+	CostGBP        float64
+	AvgPencePerKWh float64
+	LateMinutes    float64 // Soft deadlines overrun, within tolerance
+	Violations     int     // Runs that broke a hard constraint
+	Missed         int     // Days a run was due but no window fitted
+}
+
+// Backtest replays historical prices one local day at a time, as if the user
+// followed the strategy's recommendation for every load each day it was due.
+// A load's FinishBy and StartBy are treated as times of day. Each day's
+// frequency check sees that day's and the next day's prices, as it would
+// live; on-demand loads are run every day. Every chosen run is checked against
+// the load's hard constraints independently of the planner.
+func Backtest(slots []PriceSlot, loads []HouseholdLoad, strategy BacktestStrategy) []BacktestResult {
+	days := slotsByDay(slots)
+
+	results := make([]BacktestResult, 0, len(loads))
+	for _, load := range loads {
+		a := load.Appliance
+		result := BacktestResult{Strategy: strategy.Name, ApplianceID: a.ID, ApplianceName: a.Name}
+		history := []time.Time{}
+
+		for i, day := range days {
+			if a.UsageFrequency != FrequencyOnDemand {
+				known := day.slots
+				if i+1 < len(days) {
+					known = append(append([]PriceSlot{}, day.slots...), days[i+1].slots...)
+				}
+				if !ShouldShowRecommendation(a, history, known, load.Constraints, day.date) {
+					continue
+				}
+			}
+
+			constraints, opts := strategy.apply(load, day.date)
+			rec, err := strategy.choose(day.slots, a.CycleMinutes, constraints, opts)
+			if err != nil {
+				result.Missed++
+				continue
+			}
+
+			history = append(history, rec.Start)
+			result.Runs++
+			result.KWh += runKWh(a, opts)
+			result.CostGBP += rec.CostGBP
+			result.LateMinutes += lateness(constraints, rec.Start, rec.End)
+			if len(ConstraintViolations(rec, constraints, day.slots)) > 0 {
+				result.Violations++
+			}
+		}
+
+		if result.KWh > 0 {
+			result.AvgPencePerKWh = result.CostGBP * 100 / result.KWh
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// apply returns a load's constraints and options for one day under the strategy
+func (s BacktestStrategy) apply(load HouseholdLoad, day time.Time) (Constraints, Options) {
+	constraints, opts := load.Constraints, load.Options
+	constraints.FinishBy = onDay(constraints.FinishBy, day)
+	constraints.StartBy = onDay(constraints.StartBy, day)
+	if s.ToleranceMinutes != nil {
+		constraints.ToleranceMinutes = *s.ToleranceMinutes
+	}
+	if s.StartStepMinutes > 0 {
+		opts.StartStepMinutes = s.StartStepMinutes
+	}
+	if s.LatePenaltyPence > 0 {
+		opts.LatePenaltyPence = s.LatePenaltyPence
+	}
+	return constraints, opts
+}
+
+// choose picks the window the strategy would follow
+func (s BacktestStrategy) choose(slots []PriceSlot, runMinutes int, constraints Constraints, opts Options) (Recommendation, error) {
+	if !s.Immediate {
+		recs, err := BestWindows(slots, runMinutes, constraints, opts, 1)
+		if err != nil {
+			return Recommendation{}, err
+		}
+		return recs[0], nil
+	}
+
+	// Every candidate a day can hold, to find the earliest
+	recs, err := BestWindows(slots, runMinutes, constraints, opts, AllWindows)
+	if err != nil {
+		return Recommendation{}, err
+	}
+	earliest := recs[0]
+	for _, rec := range recs[1:] {
+		if rec.Start.Before(earliest.Start) {
+			earliest = rec
+		}
+	}
+	return earliest, nil
+}
+
+// ConstraintViolations lists the hard constraints a recommended run breaks:
+// running in a slot outside the allowed windows, in a blocked window or quiet
+// hours, in a slot priced above the cap, or past a deadline plus its tolerance
+func ConstraintViolations(rec Recommendation, c Constraints, slots []PriceSlot) []string {
+	violations := []string{}
+	add := func(v string) {
+		for _, seen := range violations {
+			if seen == v {
+				return
+			}
+		}
+		violations = append(violations, v)
+	}
+
+	prices := make(map[int64]float64, len(slots))
+	for _, slot := range slots {
+		prices[slot.Start.Unix()] = slot.PencePerKWh
+	}
+
+	for _, span := range runSpans(rec) {
+		for t := span.Start.Truncate(30 * time.Minute); t.Before(span.End); t = t.Add(30 * time.Minute) {
+			if len(c.Allowed) > 0 && !isInTimeWindows(t, c.Allowed) {
+				add("outside allowed hours")
+			}
+			if isInTimeWindows(t, c.Blocked) {
+				add("in a blocked window")
+			}
+			if c.NoiseLevel >= 3 && isInTimeWindows(t, c.QuietHours) {
+				add("in quiet hours")
+			}
+			if price, ok := prices[t.Unix()]; ok && c.PriceCapPence != nil && price > *c.PriceCapPence {
+				add("above the price cap")
+			}
+		}
+	}
+	if latest := latestStart(c); latest != nil && rec.Start.After(*latest) {
+		add("started too late")
+	}
+	if latest := latestFinish(c); latest != nil && rec.End.After(*latest) {
+		add("finished too late")
+	}
+
+	return violations
+}
+
+// backtestDay is one local day of historical prices
+type backtestDay struct {
+	date  time.Time
+	slots []PriceSlot
+}
+
+// slotsByDay splits time-ordered slots into local days
+func slotsByDay(slots []PriceSlot) []backtestDay {
+	days := []backtestDay{}
+	for _, slot := range slots {
+		date := localDate(slot.Start)
+		if n := len(days); n == 0 || !days[n-1].date.Equal(date) {
+			days = append(days, backtestDay{date: date})
+		}
+		days[len(days)-1].slots = append(days[len(days)-1].slots, slot)
+	}
+	return days
+}
+
+// onDay moves t's time of day onto day, or returns nil if t is unset
+func onDay(t *time.Time, day time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.Local()
+	moved := time.Date(day.Year(), day.Month(), day.Day(), local.Hour(), local.Minute(), 0, 0, day.Location())
+	return &moved
+}
+
+// runKWh returns the energy one run of the appliance uses
+func runKWh(a *Appliance, opts Options) float64 {
+	if opts.EstKWh > 0 {
+		return opts.EstKWh
+	}
+	if len(a.PowerProfile) > 0 {
+		return ProfileKWh(a.PowerProfile)
+	}
+	return a.EstKWh
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBacktest(t *testing.T) {
+	// A week from Monday 1 December 2025: 20p all day but 5p from 03:00 to 05:00
+	start := time.Date(2025, 12, 1, 0, 0, 0, 0, time.Local)
+	prices := make([]float64, 7*48)
+	for i := range prices {
+		prices[i] = 20
+		if h := i % 48; h >= 6 && h < 10 {
+			prices[i] = 5
+		}
+	}
+	slots := makeSlots(start, prices)
+
+	washer := &Appliance{ID: "wm", Name: "Washer", CycleMinutes: 60, EstKWh: 1, UsageFrequency: FrequencyDaily}
+	dishwasher := &Appliance{ID: "dw", Name: "Dishwasher", CycleMinutes: 60, EstKWh: 1, UsageFrequency: FrequencyWeekly}
+	loads := []HouseholdLoad{
+		{Appliance: washer, Options: Options{EstKWh: 1}},
+		{Appliance: dishwasher, Options: Options{EstKWh: 1}},
+	}
+
+	tests := []struct {
+		strategy BacktestStrategy
+		wantRuns []int
+		wantAvg  float64
+	}{
+		{strategy: BacktestStrategy{Name: "best"}, wantRuns: []int{7, 1}, wantAvg: 5},
+		{strategy: BacktestStrategy{Name: "immediate", Immediate: true}, wantRuns: []int{7, 1}, wantAvg: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.Name, func(t *testing.T) {
+			results := Backtest(slots, loads, tt.strategy)
+			if len(results) != len(loads) {
+				t.Fatalf("got %d results, want %d", len(results), len(loads))
+			}
+			for i, r := range results {
+				if r.Runs != tt.wantRuns[i] {
+					t.Errorf("%s: %d runs, want %d", r.ApplianceName, r.Runs, tt.wantRuns[i])
+				}
+				if math.Abs(r.AvgPencePerKWh-tt.wantAvg) > 1e-9 {
+					t.Errorf("%s: average %.2fp/kWh, want %.2fp", r.ApplianceName, r.AvgPencePerKWh, tt.wantAvg)
+				}
+				if r.Violations != 0 || r.Missed != 0 {
+					t.Errorf("%s: %d violations and %d missed days, want none", r.ApplianceName, r.Violations, r.Missed)
+				}
+			}
+		})
+	}
+}
+
+func TestConstraintViolations(t *testing.T) {
+	base := time.Date(2025, 12, 1, 22, 0, 0, 0, time.Local)
+	slots := makeSlots(base, []float64{10, 40, 10, 10})
+	finishBy := base.Add(90 * time.Minute)
+	priceCap := 30.0
+	c := Constraints{
+		QuietHours:    []TimeWindow{{Start: "22:00", End: "22:30"}},
+		NoiseLevel:    4,
+		PriceCapPence: &priceCap,
+		FinishBy:      &finishBy,
+	}
+
+	rec := Recommendation{Start: base, End: base.Add(2 * time.Hour)}
+	got := ConstraintViolations(rec, c, slots)
+	want := []string{"in quiet hours", "above the price cap", "finished too late"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("violation %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	ok := Recommendation{Start: base.Add(time.Hour), End: base.Add(90 * time.Minute)}
+	if v := ConstraintViolations(ok, c, slots); len(v) != 0 {
+		t.Errorf("expected no violations, got %v", v)
+	}
+}
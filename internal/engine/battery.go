@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// batterySteps is how finely the optimiser divides the battery's capacity
+	batterySteps = 100
+	// defaultBatteryEfficiency is the round trip assumed when none is set, as
+	// the store and CLI default to
+	defaultBatteryEfficiency = 0.9
+)
+
+// Battery describes a home battery and its starting charge
+type Battery struct {
+	CapacityKWh    float64
+	SoCPercent     float64 // Charge at the start of the schedule
+	ReservePercent float64 // Never discharged below this
+	MaxChargeKW    float64
+	MaxDischargeKW float64
+	Efficiency     float64 // Round-trip, 0-1; 0 = defaultBatteryEfficiency
+}
+
+// BatteryAction is what the battery does during one slot
+type BatteryAction string
+
+const (
+	BatteryCharge    BatteryAction = "charge"    // Force-charge from the grid
+	BatteryDischarge BatteryAction = "discharge" // Power the house from the battery
+	BatteryHold      BatteryAction = "hold"      // Neither charge nor discharge
+)
+
+// BatterySlot is the battery's plan for one price slot
+type BatterySlot struct {
+	Start       time.Time
+	End         time.Time
+	Action      BatteryAction
+	PencePerKWh float64
+	LoadKWh     float64 // Baseline household consumption
+	GridKWh     float64 // Imported from the grid, including any charging
+	BatteryKWh  float64 // Change in stored energy; negative when discharging
+	SoCPercent  float64 // Charge at the end of the slot
+}
+
+// BatterySchedule is a charge/discharge plan and what it saves
+type BatterySchedule struct {
+	Slots           []BatterySlot
+	CostGBP         float64 // Import cost with the battery
+	BaselineCostGBP float64 // Import cost of the same load without it
+	SavingsGBP      float64
+}
+
+// HouseholdBattery returns the household's battery starting at socPercent,
+// or nil when it has none
+func HouseholdBattery(h *Household, socPercent float64) *Battery {
+	if h == nil || h.BatteryKWh <= 0 {
+		return nil
+	}
+	return &Battery{
+		CapacityKWh:    h.BatteryKWh,
+		SoCPercent:     socPercent,
+		ReservePercent: h.BatteryReservePercent,
+		MaxChargeKW:    h.BatteryChargeKW,
+		MaxDischargeKW: h.BatteryDischargeKW,
+		Efficiency:     h.BatteryEfficiency,
+	}
+}
+
+// OptimiseBattery works out when to force-charge the battery from the grid and
+// when to discharge it into the house so the baseline load costs as little as
+// possible. baseLoadKW is the household's draw by half hour of the day, starting
+// at midnight; an empty profile assumes a flat pvBaseLoadKW. The battery only
+// discharges to cover the house's own load, never to export. Energy left in the
+// battery at the end is valued at the cheapest price seen, so the optimiser
+// neither drains it for nothing nor tops it up beyond what the horizon needs.
+func OptimiseBattery(slots []PriceSlot, battery Battery, baseLoadKW []float64) (*BatterySchedule, error) {
+	if len(slots) == 0 || battery.CapacityKWh <= 0 || battery.MaxChargeKW < 0 || battery.MaxDischargeKW < 0 {
+		return nil, ErrInvalidInput
+	}
+	// A battery can't give back more than it took in
+	if battery.Efficiency < 0 || battery.Efficiency > 1 {
+		return nil, ErrInvalidInput
+	}
+	efficiency := battery.Efficiency
+	if efficiency == 0 {
+		efficiency = defaultBatteryEfficiency
+	}
+	// Losses are split evenly between charging and discharging
+	oneWay := math.Sqrt(efficiency)
+
+	step := battery.CapacityKWh / batterySteps
+	reserve := int(math.Ceil(battery.ReservePercent / 100 * batterySteps))
+	reserve = max(0, min(reserve, batterySteps))
+	startLevel := int(math.Round(battery.SoCPercent / 100 * batterySteps))
+	startLevel = max(0, min(startLevel, batterySteps))
+
+	loads := make([]float64, len(slots))
+	minPrice := math.Inf(1)
+	for i, slot := range slots {
+		hours := slot.End.Sub(slot.Start).Hours()
+		loads[i] = baseLoadAt(baseLoadKW, slot.Start) * hours
+		minPrice = math.Min(minPrice, slot.PencePerKWh)
+	}
+
+	// cost[t][s] is the cheapest import cost from slot t onwards starting at level s
+	levels := batterySteps + 1
+	cost := make([][]float64, len(slots)+1)
+	next := make([][]int, len(slots))
+	cost[len(slots)] = make([]float64, levels)
+	for s := range levels {
+		cost[len(slots)][s] = -float64(s) * step * oneWay * minPrice
+	}
+
+	for t := len(slots) - 1; t >= 0; t-- {
+		slot := slots[t]
+		hours := slot.End.Sub(slot.Start).Hours()
+		maxUp := int(math.Floor(battery.MaxChargeKW * hours * oneWay / step))
+		maxDown := int(math.Floor(battery.MaxDischargeKW * hours / oneWay / step))
+
+		cost[t] = make([]float64, levels)
+		next[t] = make([]int, levels)
+		for s := range levels {
+			cost[t][s] = math.Inf(1)
+			for to := max(0, s-maxDown); to <= min(batterySteps, s+maxUp); to++ {
+				if to < s && to < reserve {
+					continue
+				}
+				grid, ok := slotImport(loads[t], float64(to-s)*step, oneWay)
+				if !ok {
+					continue
+				}
+				c := slot.PencePerKWh*grid + cost[t+1][to]
+				if c < cost[t][s] {
+					cost[t][s] = c
+					next[t][s] = to
+				}
+			}
+		}
+	}
+
+	schedule := &BatterySchedule{Slots: make([]BatterySlot, 0, len(slots))}
+	level := startLevel
+	for t, slot := range slots {
+		to := next[t][level]
+		delta := float64(to-level) * step
+		grid, _ := slotImport(loads[t], delta, oneWay)
+
+		action := BatteryHold
+		if to > level {
+			action = BatteryCharge
+		} else if to < level {
+			action = BatteryDischarge
+		}
+
+		schedule.Slots = append(schedule.Slots, BatterySlot{
+			Start:       slot.Start,
+			End:         slot.End,
+			Action:      action,
+			PencePerKWh: slot.PencePerKWh,
+			LoadKWh:     loads[t],
+			GridKWh:     grid,
+			BatteryKWh:  delta,
+			SoCPercent:  float64(to) / batterySteps * 100,
+		})
+		schedule.CostGBP += slot.PencePerKWh * grid / 100.0
+		schedule.BaselineCostGBP += slot.PencePerKWh * loads[t] / 100.0
+		level = to
+	}
+	schedule.SavingsGBP = schedule.BaselineCostGBP - schedule.CostGBP
+
+	return schedule, nil
+}
+
+// slotImport returns the grid import for one slot when the stored energy
+// changes by delta kWh, and false if discharging would deliver more than the
+// house uses
+func slotImport(loadKWh, delta, oneWay float64) (float64, bool) {
+	if delta >= 0 {
+		return loadKWh + delta/oneWay, true
+	}
+	delivered := -delta * oneWay
+	if delivered > loadKWh+1e-9 {
+		return 0, false
+	}
+	return math.Max(0, loadKWh-delivered), true
+}
+
+// baseLoadAt returns the household's baseline draw in kW at t
+func baseLoadAt(profile []float64, t time.Time) float64 {
+	if len(profile) == 0 {
+		return pvBaseLoadKW
+	}
+	t = t.Local()
+	index := (t.Hour()*60 + t.Minute()) / 30
+	return profile[index%len(profile)]
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOptimiseBattery(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	// Two cheap hours then two dear ones
+	slots := makeSlots(base, []float64{5, 5, 5, 5, 40, 40, 40, 40})
+	battery := Battery{CapacityKWh: 10, MaxChargeKW: 3, MaxDischargeKW: 3, Efficiency: 1}
+	load := []float64{1}
+
+	schedule, err := OptimiseBattery(slots, battery, load)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(schedule.Slots) != len(slots) {
+		t.Fatalf("got %d slots, want %d", len(schedule.Slots), len(slots))
+	}
+
+	// 1 kW for 4 hours: 2 kWh at 5p plus 2 kWh at 40p without the battery
+	if math.Abs(schedule.BaselineCostGBP-0.90) > 1e-6 {
+		t.Errorf("baseline £%.4f, want £0.90", schedule.BaselineCostGBP)
+	}
+	// With it, all 4 kWh are bought at 5p
+	if math.Abs(schedule.CostGBP-0.20) > 1e-6 {
+		t.Errorf("cost £%.4f, want £0.20", schedule.CostGBP)
+	}
+
+	for _, s := range schedule.Slots[:4] {
+		if s.Action == BatteryDischarge {
+			t.Errorf("%s: discharging in a cheap slot", s.Start.Format("15:04"))
+		}
+	}
+	for _, s := range schedule.Slots[4:] {
+		if s.Action != BatteryDischarge || s.GridKWh > 1e-9 {
+			t.Errorf("%s: %s with %.2f kWh import, want discharge covering the load", s.Start.Format("15:04"), s.Action, s.GridKWh)
+		}
+	}
+	if last := schedule.Slots[len(schedule.Slots)-1]; last.SoCPercent > 1e-9 {
+		t.Errorf("battery left at %.0f%%, want it used up", last.SoCPercent)
+	}
+}
+
+func TestOptimiseBatteryReserveAndEfficiency(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{30, 30, 30, 30})
+
+	// Flat prices and losses: charging can never pay for itself
+	battery := Battery{CapacityKWh: 10, SoCPercent: 50, ReservePercent: 40, MaxChargeKW: 3, MaxDischargeKW: 3, Efficiency: 0.8}
+	schedule, err := OptimiseBattery(slots, battery, []float64{2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, s := range schedule.Slots {
+		if s.Action == BatteryCharge {
+			t.Errorf("%s: charging at a flat price with losses", s.Start.Format("15:04"))
+		}
+		if s.SoCPercent < battery.ReservePercent-1e-9 {
+			t.Errorf("%s: discharged to %.0f%%, below the %.0f%% reserve", s.Start.Format("15:04"), s.SoCPercent, battery.ReservePercent)
+		}
+	}
+	if schedule.SavingsGBP <= 0 {
+		t.Errorf("using the stored charge should save money, got £%.2f", schedule.SavingsGBP)
+	}
+}
+
+func TestOptimiseBatteryEfficiencyValidation(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{5, 5, 40, 40})
+
+	// More out than in is a misconfiguration, not a lossless battery
+	battery := Battery{CapacityKWh: 10, MaxChargeKW: 3, MaxDischargeKW: 3, Efficiency: 1.2}
+	if _, err := OptimiseBattery(slots, battery, []float64{1}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("got %v for an efficiency of 1.2, want ErrInvalidInput", err)
+	}
+
+	// Unset means the usual 90%, so costs the same as saying so
+	battery.Efficiency = 0
+	unset, err := OptimiseBattery(slots, battery, []float64{1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	battery.Efficiency = 0.9
+	explicit, _ := OptimiseBattery(slots, battery, []float64{1})
+	if math.Abs(unset.CostGBP-explicit.CostGBP) > 1e-9 {
+		t.Errorf("unset efficiency costs £%.4f, want £%.4f as at 90%%", unset.CostGBP, explicit.CostGBP)
+	}
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// carbonLookup answers "what is the grid intensity at time t" for a forecast series
+type carbonLookup struct {
+	series []CarbonSlot
+	mean   float64
+}
+
+func newCarbonLookup(series []CarbonSlot) carbonLookup {
+	mean := 0.0
+	for _, c := range series {
+		mean += c.GramsPerKWh
+	}
+	if len(series) > 0 {
+		mean /= float64(len(series))
+	}
+	return carbonLookup{series: series, mean: mean}
+}
+
+// available reports whether any carbon data was supplied
+func (l carbonLookup) available() bool {
+	return len(l.series) > 0
+}
+
+// at returns the intensity covering t, falling back to the series mean
+// for periods the forecast doesn't reach
+func (l carbonLookup) at(t time.Time) float64 {
+	for _, c := range l.series {
+		if !t.Before(c.Start) && t.Before(c.End) {
+			return c.GramsPerKWh
+		}
+	}
+	return l.mean
+}
+
+// blendCarbonScores replaces each candidate's cost-only score with a weighted
+// blend of normalised cost and normalised emissions. The result is rescaled
+// onto the candidates' cost range so scores stay in pence and a weight of 0
+// reproduces the plain cost ordering.
+func blendCarbonScores(candidates []Recommendation, weight float64) {
+	if len(candidates) == 0 {
+		return
+	}
+	weight = math.Max(0, math.Min(1, weight))
+
+	minCost, maxCost := math.Inf(1), math.Inf(-1)
+	minKg, maxKg := math.Inf(1), math.Inf(-1)
+	for _, c := range candidates {
+		minCost = math.Min(minCost, c.Score)
+		maxCost = math.Max(maxCost, c.Score)
+		minKg = math.Min(minKg, c.KgCO2)
+		maxKg = math.Max(maxKg, c.KgCO2)
+	}
+
+	costRange := maxCost - minCost
+	if costRange == 0 {
+		costRange = 1 // Equal prices: let carbon alone separate the windows
+	}
+	kgRange := maxKg - minKg
+
+	for i := range candidates {
+		normCost := (candidates[i].Score - minCost) / costRange
+		normKg := 0.0
+		if kgRange > 0 {
+			normKg = (candidates[i].KgCO2 - minKg) / kgRange
+		}
+		candidates[i].Score = minCost + ((1-weight)*normCost+weight*normKg)*costRange
+	}
+}
@@ -13,6 +13,9 @@ var (
 	ErrInvalidInput    = errors.New("invalid input parameters")
 )
 
+// AllWindows asks BestWindows for every feasible window rather than the top N
+const AllWindows = math.MaxInt
+
 // BestWindows finds the top N optimal start windows for an appliance
 // given price slots and constraints
 func BestWindows(slots []PriceSlot, runMinutes int, constraints Constraints, opts Options, topN int) ([]Recommendation, error) {
@@ -26,43 +29,98 @@ func BestWindows(slots []PriceSlot, runMinutes int, constraints Constraints, opt
 		topN = 3
 	}
 
-	// Calculate number of contiguous 30-min slots needed
-	requiredSlots := int(math.Ceil(float64(runMinutes) / 30.0))
-
 	// Filter slots by constraints
 	feasible := filterByConstraints(slots, constraints)
+
+	// Loads that can pause and resume don't need a contiguous window
+	if opts.Interruptible != nil {
+		return bestInterruptibleWindow(slots, feasible, runMinutes, constraints, opts)
+	}
+
+	// Calculate number of contiguous 30-min slots needed
+	requiredSlots := int(math.Ceil(float64(runMinutes) / 30.0))
 	if len(feasible) < requiredSlots {
 		return nil, ErrNoFeasibleSlots
 	}
 
+	// Candidate starts fall on slot boundaries unless a finer step is requested
+	step := time.Duration(opts.StartStepMinutes) * time.Minute
+	if step <= 0 || step > 30*time.Minute {
+		step = 30 * time.Minute
+	}
+	runDuration := time.Duration(runMinutes) * time.Minute
+
 	// Find all valid contiguous windows
+	intensity := newCarbonLookup(opts.CarbonIntensity)
+	pv := newPVLookup(opts.PVForecast, opts.ExportPrices)
 	candidates := []Recommendation{}
-	for i := 0; i+requiredSlots <= len(feasible); i++ {
-		window := feasible[i : i+requiredSlots]
+	for i := range feasible {
+		for start := feasible[i].Start; start.Before(feasible[i].End); start = start.Add(step) {
+			end := start.Add(runDuration)
 
-		// Verify contiguous
-		if !isContiguous(window) {
-			continue
-		}
+			if latest := latestStart(constraints); latest != nil && start.After(*latest) {
+				continue
+			}
+			if latest := latestFinish(constraints); latest != nil && end.After(*latest) {
+				continue
+			}
 
-		// Calculate cost
-		totalPence := 0.0
-		for _, slot := range window {
-			totalPence += slot.PencePerKWh * (opts.EstKWh / float64(requiredSlots))
-		}
-		costGBP := totalPence / 100.0
+			// Slots the run touches, which must all be feasible and back to back
+			j := i
+			for j < len(feasible) && feasible[j].Start.Before(end) {
+				j++
+			}
+			window := feasible[i:j]
+			if window[len(window)-1].End.Before(end) || !isContiguous(window) {
+				continue
+			}
 
-		// Calculate score (lower is better)
-		score := totalPence
+			// Calculate cost and emissions for the minutes actually used
+			wc := costWindow(window, start, end, runMinutes, opts, intensity, pv)
+			costGBP := (wc.gridPence + wc.exportPence) / 100.0
+
+			// Calculate score (lower is better); solar counts at its export
+			// price or is discounted by PVWeight, and overrunning a soft
+			// deadline costs LatePenaltyPence a minute
+			late := lateness(constraints, start, end)
+			penalty := late * opts.LatePenaltyPence
+			score := wc.gridPence + wc.solarScore + penalty
+
+			reason := generateReason(window, wc.gridPence+wc.solarPence, slots)
+			if intensity.available() {
+				reason = fmt.Sprintf("%s; %.0f gCO2/kWh", reason, wc.meanIntensity)
+			}
+			if wc.solarKWh > 0 {
+				reason = solarReason(reason, wc.solarKWh, wc.exportPence)
+			}
+			if late > 0 {
+				reason = fmt.Sprintf("%s; %.0f min past deadline (+%.1fp penalty)", reason, late, penalty)
+			}
+			lowGBP := (wc.gridLowPence + wc.exportPence) / 100.0
+			highGBP := (wc.gridHighPence + wc.exportPence) / 100.0
+			if wc.predicted {
+				reason = predictedReason(reason, lowGBP, highGBP)
+			}
 
-		rec := Recommendation{
-			Start:   window[0].Start,
-			End:     window[len(window)-1].End,
-			CostGBP: costGBP,
-			Score:   score,
-			Reason:  generateReason(window, totalPence, slots),
+			rec := Recommendation{
+				Start:         start,
+				End:           end,
+				CostGBP:       costGBP,
+				KgCO2:         wc.grams / 1000.0,
+				SolarKWh:      wc.solarKWh,
+				LostExportGBP: wc.exportPence / 100.0,
+				Predicted:     wc.predicted,
+				CostLowGBP:    lowGBP,
+				CostHighGBP:   highGBP,
+				Score:         score,
+				Reason:        reason,
+			}
+			candidates = append(candidates, rec)
 		}
-		candidates = append(candidates, rec)
+	}
+
+	if intensity.available() && opts.CarbonWeight > 0 {
+		blendCarbonScores(candidates, opts.CarbonWeight)
 	}
 
 	if len(candidates) == 0 {
@@ -107,13 +165,14 @@ func filterByConstraints(slots []PriceSlot, c Constraints) []PriceSlot {
 			continue
 		}
 
-		// Check startBy constraint
-		if c.StartBy != nil && slot.Start.After(*c.StartBy) {
+		// Check startBy constraint, softened by the tolerance
+		if latest := latestStart(c); latest != nil && slot.Start.After(*latest) {
 			continue
 		}
 
-		// Check finishBy constraint (slot end must be before finishBy)
-		if c.FinishBy != nil && slot.End.After(*c.FinishBy) {
+		// Check finishBy constraint (slot must start before finishBy plus the
+		// tolerance; the run's exact end is checked per candidate)
+		if latest := latestFinish(c); latest != nil && !slot.Start.Before(*latest) {
 			continue
 		}
 
@@ -123,6 +182,37 @@ func filterByConstraints(slots []PriceSlot, c Constraints) []PriceSlot {
 	return result
 }
 
+// latestStart returns StartBy pushed back by the tolerance, or nil if unset
+func latestStart(c Constraints) *time.Time {
+	if c.StartBy == nil {
+		return nil
+	}
+	t := c.StartBy.Add(time.Duration(c.ToleranceMinutes) * time.Minute)
+	return &t
+}
+
+// latestFinish returns FinishBy pushed back by the tolerance, or nil if unset
+func latestFinish(c Constraints) *time.Time {
+	if c.FinishBy == nil {
+		return nil
+	}
+	t := c.FinishBy.Add(time.Duration(c.ToleranceMinutes) * time.Minute)
+	return &t
+}
+
+// lateness returns how many minutes a run over [start, end) overruns its
+// StartBy and FinishBy deadlines
+func lateness(c Constraints, start, end time.Time) float64 {
+	late := 0.0
+	if c.StartBy != nil && start.After(*c.StartBy) {
+		late += start.Sub(*c.StartBy).Minutes()
+	}
+	if c.FinishBy != nil && end.After(*c.FinishBy) {
+		late += end.Sub(*c.FinishBy).Minutes()
+	}
+	return late
+}
+
 // isInTimeWindows checks if a time falls within any of the specified time windows
 func isInTimeWindows(t time.Time, windows []TimeWindow) bool {
 	for _, w := range windows {
@@ -180,6 +270,81 @@ func parseTimeOfDay(s string) (time.Time, error) {
 	return time.Parse("15:04", s)
 }
 
+// windowCost breaks down the price and emissions of one candidate run
+type windowCost struct {
+	gridPence     float64
+	solarPence    float64 // Value of solar energy at the grid price
+	solarScore    float64 // What using the solar energy counts for in the score
+	exportPence   float64 // Export income given up by using the solar energy
+	gridLowPence  float64 // gridPence at the ends of any predicted prices' bands
+	gridHighPence float64
+	predicted     bool // Whether any slot used is predicted
+	solarKWh      float64
+	grams         float64
+	meanIntensity float64
+}
+
+// costWindow charges a run over [start, end) against the slots it touches,
+// billing each slot only for the minutes the run actually overlaps it
+func costWindow(window []PriceSlot, start, end time.Time, runMinutes int, opts Options, intensity carbonLookup, pv pvLookup) windowCost {
+	var wc windowCost
+	for _, slot := range window {
+		from := maxTime(slot.Start, start)
+		to := minTime(slot.End, end)
+		if !from.Before(to) {
+			continue
+		}
+
+		kwh := runEnergy(opts.PowerProfile, opts.EstKWh, runMinutes,
+			from.Sub(start).Minutes(), to.Sub(start).Minutes())
+
+		// Surplus is forecast per slot, so only the overlapped share is usable
+		share := to.Sub(from).Minutes() / slot.End.Sub(slot.Start).Minutes()
+		solar := math.Min(kwh, pv.surplusKWh(slot.Start)*share)
+		grid := kwh - solar
+
+		g := intensity.at(slot.Start)
+		low, high := slot.band()
+		wc.gridPence += slot.PencePerKWh * grid
+		wc.gridLowPence += low * grid
+		wc.gridHighPence += high * grid
+		wc.predicted = wc.predicted || slot.Predicted
+		score, lostExport := pv.solarCost(slot, solar, opts.PVWeight)
+		wc.solarPence += slot.PencePerKWh * solar
+		wc.solarScore += score
+		wc.exportPence += lostExport
+		wc.solarKWh += solar
+		wc.grams += g * grid
+		wc.meanIntensity += g
+	}
+	if len(window) > 0 {
+		wc.meanIntensity /= float64(len(window))
+	}
+	return wc
+}
+
+// solarReason notes the solar energy a run uses, and the export it gives up
+func solarReason(reason string, solarKWh, exportPence float64) string {
+	if exportPence > 0 {
+		return fmt.Sprintf("%s; %.1f kWh from solar (%.1fp export forgone)", reason, solarKWh, exportPence)
+	}
+	return fmt.Sprintf("%s; %.1f kWh from solar", reason, solarKWh)
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
 // isContiguous verifies that slots are continuous 30-minute periods
 func isContiguous(slots []PriceSlot) bool {
 	for i := 1; i < len(slots); i++ {
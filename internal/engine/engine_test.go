@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"math"
+	"strings"
 	"testing"
 	"time"
 )
@@ -229,3 +231,218 @@ func TestIsContiguous(t *testing.T) {
 func ptrFloat(f float64) *float64 {
 	return &f
 }
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func TestBestWindowsCarbonWeight(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := []PriceSlot{}
+	intensity := []CarbonSlot{}
+
+	// Cheapest slot is also the dirtiest
+	prices := []float64{10, 12, 14}
+	grams := []float64{300, 150, 50}
+	for i := range prices {
+		start := base.Add(time.Duration(i) * 30 * time.Minute)
+		end := start.Add(30 * time.Minute)
+		slots = append(slots, PriceSlot{Start: start, End: end, PencePerKWh: prices[i]})
+		intensity = append(intensity, CarbonSlot{Start: start, End: end, GramsPerKWh: grams[i]})
+	}
+
+	tests := []struct {
+		name      string
+		weight    float64
+		wantStart time.Time
+	}{
+		{name: "cost only", weight: 0, wantStart: base},
+		{name: "carbon only", weight: 1, wantStart: base.Add(60 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{EstKWh: 1.0, CarbonWeight: tt.weight, CarbonIntensity: intensity}
+			recs, err := BestWindows(slots, 30, Constraints{}, opts, 3)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !recs[0].Start.Equal(tt.wantStart) {
+				t.Errorf("top start = %s, want %s", recs[0].Start.Format("15:04"), tt.wantStart.Format("15:04"))
+			}
+			if recs[0].KgCO2 <= 0 {
+				t.Errorf("expected KgCO2 to be reported, got %f", recs[0].KgCO2)
+			}
+		})
+	}
+}
+
+func TestBestWindowsPVWeight(t *testing.T) {
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	night := PriceSlot{Start: base.Add(3 * time.Hour), End: base.Add(3*time.Hour + 30*time.Minute), PencePerKWh: 12}
+	noon := PriceSlot{Start: base.Add(12 * time.Hour), End: base.Add(12*time.Hour + 30*time.Minute), PencePerKWh: 20}
+	slots := []PriceSlot{night, noon}
+	forecast := []PVSlot{{Start: noon.Start, End: noon.End, GenerationKW: 3.0}}
+
+	tests := []struct {
+		name      string
+		weight    float64
+		wantStart time.Time
+	}{
+		{name: "solar ignored", weight: 0, wantStart: night.Start},
+		{name: "solar near-free", weight: 1, wantStart: noon.Start},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{EstKWh: 1.0, PVWeight: tt.weight, PVForecast: forecast}
+			recs, err := BestWindows(slots, 30, Constraints{}, opts, 2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !recs[0].Start.Equal(tt.wantStart) {
+				t.Errorf("top start = %s, want %s", recs[0].Start.Format("15:04"), tt.wantStart.Format("15:04"))
+			}
+		})
+	}
+
+	// Surplus covers the whole load, so no grid import is costed at noon
+	recs, _ := BestWindows([]PriceSlot{noon}, 30, Constraints{}, Options{EstKWh: 1.0, PVWeight: 1, PVForecast: forecast}, 1)
+	if recs[0].CostGBP != 0 || recs[0].SolarKWh != 1.0 {
+		t.Errorf("got cost £%.2f and %.2f kWh solar, want £0.00 and 1.00 kWh", recs[0].CostGBP, recs[0].SolarKWh)
+	}
+}
+
+func TestBestWindowsExportPrices(t *testing.T) {
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	night := PriceSlot{Start: base.Add(3 * time.Hour), End: base.Add(3*time.Hour + 30*time.Minute), PencePerKWh: 12}
+	noon := PriceSlot{Start: base.Add(12 * time.Hour), End: base.Add(12*time.Hour + 30*time.Minute), PencePerKWh: 20}
+	slots := []PriceSlot{night, noon}
+	forecast := []PVSlot{{Start: noon.Start, End: noon.End, GenerationKW: 3.0}}
+
+	tests := []struct {
+		name       string
+		export     float64
+		wantStart  time.Time
+		wantCost   float64
+		wantExport float64
+	}{
+		// Selling the noon surplus at 15p beats buying at 12p overnight
+		{name: "export dearer than night import", export: 15, wantStart: night.Start, wantCost: 0.12},
+		{name: "export cheaper than night import", export: 5, wantStart: noon.Start, wantCost: 0.05, wantExport: 0.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export := []PriceSlot{{Start: noon.Start, End: noon.End, PencePerKWh: tt.export}}
+			// PVWeight would make the surplus free; the export price takes precedence
+			opts := Options{EstKWh: 1.0, PVWeight: 1, PVForecast: forecast, ExportPrices: export}
+			recs, err := BestWindows(slots, 30, Constraints{}, opts, 2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !recs[0].Start.Equal(tt.wantStart) {
+				t.Errorf("top start = %s, want %s", recs[0].Start.Format("15:04"), tt.wantStart.Format("15:04"))
+			}
+			if math.Abs(recs[0].CostGBP-tt.wantCost) > 1e-9 || math.Abs(recs[0].LostExportGBP-tt.wantExport) > 1e-9 {
+				t.Errorf("got cost £%.4f, lost export £%.4f; want £%.4f, £%.4f",
+					recs[0].CostGBP, recs[0].LostExportGBP, tt.wantCost, tt.wantExport)
+			}
+
+			// Interruptible loads weigh solar the same way
+			opts.Interruptible = &InterruptibleLoad{PowerKW: 2}
+			recs, err = BestWindows(slots, 30, Constraints{}, opts, 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !recs[0].Start.Equal(tt.wantStart) || math.Abs(recs[0].LostExportGBP-tt.wantExport) > 1e-9 {
+				t.Errorf("interruptible: start %s, lost export £%.4f; want %s, £%.4f", recs[0].Start.Format("15:04"),
+					recs[0].LostExportGBP, tt.wantStart.Format("15:04"), tt.wantExport)
+			}
+		})
+	}
+}
+
+func TestBestWindowsSubSlotStarts(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{40, 10, 5, 20})
+
+	tests := []struct {
+		name      string
+		step      int
+		wantStart *time.Time
+		wantCost  float64
+	}{
+		// Slot boundaries only: 00:30 and 01:00 both cost 30 min at one price and 10 min at the next
+		{name: "slot boundaries", step: 0, wantCost: (0.75*10 + 0.25*5) / 100},
+		// 10-minute steps let the 40-minute cycle straddle into the 5p slot: 10 min at 10p, 30 min at 5p
+		{name: "ten minute steps", step: 10, wantStart: ptrTime(base.Add(50 * time.Minute)), wantCost: (0.25*10 + 0.75*5) / 100},
+		{name: "five minute steps", step: 5, wantStart: ptrTime(base.Add(50 * time.Minute)), wantCost: (0.25*10 + 0.75*5) / 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{EstKWh: 1.0, StartStepMinutes: tt.step}
+			recs, err := BestWindows(slots, 40, Constraints{}, opts, 5)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantStart != nil && !recs[0].Start.Equal(*tt.wantStart) {
+				t.Errorf("top start = %s, want %s", recs[0].Start.Format("15:04"), tt.wantStart.Format("15:04"))
+			}
+			if got := recs[0].End.Sub(recs[0].Start); got != 40*time.Minute {
+				t.Errorf("window length = %v, want 40m (no rounding up to whole slots)", got)
+			}
+			if diff := recs[0].CostGBP - tt.wantCost; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("cost = £%.4f, want £%.4f", recs[0].CostGBP, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestBestWindowsToleranceMinutes(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{20, 20, 20, 20, 1, 1})
+	finishBy := base.Add(2 * time.Hour)
+
+	tests := []struct {
+		name      string
+		tolerance int
+		penalty   float64
+		wantStart *time.Time // nil = any on-time window
+		wantLate  bool
+	}{
+		{name: "hard deadline", tolerance: 0, penalty: 0.1, wantLate: false},
+		// 02:00-03:00 costs 1p plus 60 min x 0.1p, beating 20p on time
+		{name: "overrun worth it", tolerance: 60, penalty: 0.1, wantStart: ptrTime(base.Add(2 * time.Hour)), wantLate: true},
+		// 1p plus 60 min x 1p is dearer than finishing on time
+		{name: "penalty too high", tolerance: 60, penalty: 1, wantLate: false},
+		// Only 30 min of slack: 01:30-02:30 is 10.5p plus 3p
+		{name: "limited by tolerance", tolerance: 30, penalty: 0.1, wantStart: ptrTime(base.Add(90 * time.Minute)), wantLate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraints := Constraints{FinishBy: &finishBy, ToleranceMinutes: tt.tolerance}
+			opts := Options{EstKWh: 1.0, LatePenaltyPence: tt.penalty}
+			recs, err := BestWindows(slots, 60, constraints, opts, 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			rec := recs[0]
+			if tt.wantStart != nil && !rec.Start.Equal(*tt.wantStart) {
+				t.Errorf("start = %s, want %s", rec.Start.Format("15:04"), tt.wantStart.Format("15:04"))
+			}
+			if tt.wantStart == nil && rec.End.After(finishBy) {
+				t.Errorf("run ends %s, want on time", rec.End.Format("15:04"))
+			}
+			if late := strings.Contains(rec.Reason, "past deadline"); late != tt.wantLate {
+				t.Errorf("reason %q: mentions lateness = %v, want %v", rec.Reason, late, tt.wantLate)
+			}
+			if rec.End.After(finishBy.Add(time.Duration(tt.tolerance) * time.Minute)) {
+				t.Errorf("run ends %s, beyond the tolerance", rec.End.Format("15:04"))
+			}
+		})
+	}
+}
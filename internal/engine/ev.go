@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// evChargeEfficiency is the share of energy drawn from the grid that ends up in
+// the battery; the rest is lost in the charger and cabling
+const evChargeEfficiency = 0.9
+
+// EVCharge describes the charge an electric vehicle needs before it leaves
+type EVCharge struct {
+	BatteryKWh    float64   // Usable battery capacity
+	SoCPercent    float64   // Current state of charge
+	TargetPercent float64   // State of charge wanted by ReadyBy
+	ChargerKW     float64   // Charge rate
+	ReadyBy       time.Time // When the car has to be ready
+}
+
+// EVChargePlan is the charging schedule for an EV and what it achieves
+type EVChargePlan struct {
+	Recommendation *Recommendation // Charging blocks and cost; nil when no charge is needed
+	EnergyKWh      float64         // Drawn from the grid
+	ExpectedSoC    float64         // Percent at ReadyBy
+	Reachable      bool            // Whether the target is met by ReadyBy
+	Warning        string
+}
+
+// EVChargeFor builds the charge request for an EV appliance, taking ReadyBy as
+// the next time its ready-by time of day comes round after now. It returns nil
+// for appliances that are not EVs.
+func EVChargeFor(a *Appliance, now time.Time) (*EVCharge, error) {
+	if a == nil || a.Class != ClassEV {
+		return nil, nil
+	}
+	if a.BatteryKWh <= 0 || a.RatedKW <= 0 {
+		return nil, fmt.Errorf("%w: EV %s needs a battery capacity and charger power", ErrInvalidInput, a.Name)
+	}
+
+	readyBy, err := nextTimeOfDay(a.ReadyBy, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: EV %s ready-by %q", ErrInvalidInput, a.Name, a.ReadyBy)
+	}
+
+	return &EVCharge{
+		BatteryKWh:    a.BatteryKWh,
+		SoCPercent:    a.SoCPercent,
+		TargetPercent: a.TargetSoCPercent,
+		ChargerKW:     a.RatedKW,
+		ReadyBy:       readyBy,
+	}, nil
+}
+
+// EnergyNeeded returns the energy to draw from the grid to reach the target
+func (ev EVCharge) EnergyNeeded() float64 {
+	gap := (ev.TargetPercent - ev.SoCPercent) / 100 * ev.BatteryKWh
+	if gap <= 0 {
+		return 0
+	}
+	return gap / evChargeEfficiency
+}
+
+// Apply sets up constraints and options so BestWindows schedules the charge:
+// the car must be charged by ReadyBy, and charging can pause between slots
+func (ev EVCharge) Apply(constraints *Constraints, opts *Options) {
+	if constraints.FinishBy == nil || ev.ReadyBy.Before(*constraints.FinishBy) {
+		readyBy := ev.ReadyBy
+		constraints.FinishBy = &readyBy
+	}
+
+	load := InterruptibleLoad{}
+	if opts.Interruptible != nil {
+		load = *opts.Interruptible
+	}
+	load.PowerKW = ev.ChargerKW
+	opts.Interruptible = &load
+	opts.EstKWh = ev.EnergyNeeded()
+	opts.PowerProfile = nil
+}
+
+// PlanEVCharge picks the cheapest charging slots that bring the EV up to its
+// target before ReadyBy. When the target can't be reached in time it charges
+// in every usable slot instead and says how far short the car will be.
+func PlanEVCharge(slots []PriceSlot, ev EVCharge, constraints Constraints, opts Options) (*EVChargePlan, error) {
+	if ev.BatteryKWh <= 0 || ev.ChargerKW <= 0 {
+		return nil, ErrInvalidInput
+	}
+
+	ev.Apply(&constraints, &opts)
+	plan := &EVChargePlan{
+		EnergyKWh:   opts.EstKWh,
+		ExpectedSoC: math.Max(ev.SoCPercent, ev.TargetPercent),
+		Reachable:   true,
+	}
+	if opts.EstKWh == 0 {
+		return plan, nil
+	}
+
+	if !ev.checkReach(slots, constraints, plan) {
+		if plan.EnergyKWh == 0 {
+			return plan, nil
+		}
+		// Charge flat out in every usable slot, whatever the block settings
+		opts.EstKWh = plan.EnergyKWh
+		opts.Interruptible = &InterruptibleLoad{PowerKW: ev.ChargerKW}
+	}
+
+	recs, err := BestWindows(slots, ev.chargeMinutes(opts.EstKWh), constraints, opts, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := recs[0]
+	rec.Reason = fmt.Sprintf("%s; %.0f%% to %.0f%% by %s",
+		rec.Reason, ev.SoCPercent, plan.ExpectedSoC, ev.ReadyBy.Local().Format("15:04"))
+	plan.Recommendation = &rec
+
+	return plan, nil
+}
+
+// checkReach reports whether the charger can deliver the plan's energy in the
+// usable slots before the deadline in constraints, which the charge must
+// already be applied to. When it can't, the plan is cut to what can be
+// delivered and warns how far short the car will be.
+func (ev EVCharge) checkReach(slots []PriceSlot, constraints Constraints, plan *EVChargePlan) bool {
+	available := 0
+	latest := latestFinish(constraints)
+	for _, slot := range filterByConstraints(slots, constraints) {
+		if latest == nil || !slot.End.After(*latest) {
+			available++
+		}
+	}
+	maxKWh := float64(available) * ev.ChargerKW * 0.5
+	if maxKWh >= plan.EnergyKWh-1e-9 {
+		return true
+	}
+
+	plan.Reachable = false
+	plan.EnergyKWh = maxKWh
+	plan.ExpectedSoC = ev.SoCPercent + maxKWh*evChargeEfficiency/ev.BatteryKWh*100
+	plan.Warning = fmt.Sprintf("target %.0f%% unreachable by %s; expect %.0f%%",
+		ev.TargetPercent, ev.ReadyBy.Local().Format("Mon 15:04"), plan.ExpectedSoC)
+	return false
+}
+
+// chargeMinutes returns how long the charger takes to deliver kwh
+func (ev EVCharge) chargeMinutes(kwh float64) int {
+	return int(math.Ceil(kwh / ev.ChargerKW * 60))
+}
+
+// nextTimeOfDay returns the first time after now that matches an HH:mm time of day
+func nextTimeOfDay(hhmm string, now time.Time) (time.Time, error) {
+	tod, err := parseTimeOfDay(hhmm)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), tod.Hour(), tod.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPlanEVCharge(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{20, 8, 25, 6, 7, 30, 9, 10, 1, 1, 1, 1})
+
+	// 4.5 kWh into the battery is 5 kWh from the grid at 90% efficiency
+	ev := EVCharge{BatteryKWh: 10, SoCPercent: 50, TargetPercent: 95, ChargerKW: 2}
+
+	tests := []struct {
+		name          string
+		readyBy       time.Time
+		soc           float64
+		wantReachable bool
+		wantKWh       float64
+		wantPence     float64
+		wantSoC       float64
+	}{
+		{name: "cheapest slots before ready-by", readyBy: base.Add(4 * time.Hour), soc: 50, wantReachable: true, wantKWh: 5, wantPence: 8 + 6 + 7 + 9 + 10, wantSoC: 95},
+		{name: "target unreachable", readyBy: base.Add(90 * time.Minute), soc: 50, wantReachable: false, wantKWh: 3, wantPence: 20 + 8 + 25, wantSoC: 77},
+		{name: "already charged", readyBy: base.Add(4 * time.Hour), soc: 100, wantReachable: true, wantKWh: 0, wantSoC: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := ev
+			ev.ReadyBy = tt.readyBy
+			ev.SoCPercent = tt.soc
+
+			plan, err := PlanEVCharge(slots, ev, Constraints{}, Options{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if plan.Reachable != tt.wantReachable {
+				t.Errorf("reachable = %v, want %v", plan.Reachable, tt.wantReachable)
+			}
+			if !tt.wantReachable && plan.Warning == "" {
+				t.Errorf("expected a warning when the target is unreachable")
+			}
+			if math.Abs(plan.EnergyKWh-tt.wantKWh) > 1e-6 {
+				t.Errorf("energy %.2f kWh, want %.2f", plan.EnergyKWh, tt.wantKWh)
+			}
+			if math.Abs(plan.ExpectedSoC-tt.wantSoC) > 1e-6 {
+				t.Errorf("expected SoC %.1f%%, want %.1f%%", plan.ExpectedSoC, tt.wantSoC)
+			}
+
+			if tt.wantKWh == 0 {
+				if plan.Recommendation != nil {
+					t.Errorf("no charge needed, got a recommendation")
+				}
+				return
+			}
+			rec := plan.Recommendation
+			if math.Abs(rec.CostGBP*100-tt.wantPence) > 1e-6 {
+				t.Errorf("cost %.2fp, want %.2fp", rec.CostGBP*100, tt.wantPence)
+			}
+			if rec.End.After(tt.readyBy) {
+				t.Errorf("charging ends %s, after ready-by %s", rec.End.Format("15:04"), tt.readyBy.Format("15:04"))
+			}
+		})
+	}
+}
+
+func TestEVChargeFor(t *testing.T) {
+	now := time.Date(2024, 12, 1, 22, 0, 0, 0, time.UTC)
+	a := &Appliance{Name: "Car", Class: ClassEV, BatteryKWh: 60, RatedKW: 7, SoCPercent: 30, TargetSoCPercent: 80, ReadyBy: "07:30"}
+
+	ev, err := EVChargeFor(a, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2024, 12, 2, 7, 30, 0, 0, time.UTC); !ev.ReadyBy.Equal(want) {
+		t.Errorf("ready by %s, want %s", ev.ReadyBy, want)
+	}
+
+	if ev, err := EVChargeFor(&Appliance{Class: ClassStandalone}, now); ev != nil || err != nil {
+		t.Errorf("non-EV appliance should give nil, nil; got %v, %v", ev, err)
+	}
+}
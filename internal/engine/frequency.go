@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// RunsPerWeek returns how many runs a week a usage frequency asks for
+func RunsPerWeek(f UsageFrequency) int {
+	switch f {
+	case FrequencyDaily:
+		return 7
+	case Frequency3xWeek:
+		return 3
+	case FrequencyWeekly:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// WeekStart returns local midnight on the Monday of the week t falls in
+func WeekStart(t time.Time) time.Time {
+	day := localDate(t)
+	offset := (int(day.Weekday()) + 6) % 7 // Days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+// RunsThisWeek counts the runs in history that started on or after Monday of
+// now's week
+func RunsThisWeek(history []time.Time, now time.Time) int {
+	week := WeekStart(now)
+	runs := 0
+	for _, t := range history {
+		if !t.Before(week) {
+			runs++
+		}
+	}
+	return runs
+}
+
+// IsDue reports whether an appliance still owes runs this week (Monday to
+// Sunday) given when it has run, and hasn't already run today
+func IsDue(appliance *Appliance, history []time.Time, now time.Time) bool {
+	target := RunsPerWeek(appliance.UsageFrequency)
+	if target == 0 {
+		return false
+	}
+
+	today := localDate(now)
+	for _, t := range history {
+		if localDate(t).Equal(today) {
+			return false
+		}
+	}
+	return RunsThisWeek(history, now) < target
+}
+
+// ShouldShowRecommendation decides whether to recommend running an appliance
+// today. history is when it has run recently. Daily appliances are shown until
+// they have run; those run a few times a week are held back for the cheapest
+// days of the week, judged on the best window each day in slots offers within
+// the appliance's constraints, so a day it can't use cheaply isn't waited for.
+// Today
+// is picked when it is among the cheapest of the days with known prices for
+// this week's fair share of the runs still owed, or when the runs left can no
+// longer wait for later days.
+func ShouldShowRecommendation(appliance *Appliance, history []time.Time, slots []PriceSlot, constraints Constraints, now time.Time) bool {
+	if !IsDue(appliance, history, now) {
+		return false
+	}
+
+	today := localDate(now)
+	weekEnd := WeekStart(now).AddDate(0, 0, 7)
+	remaining := RunsPerWeek(appliance.UsageFrequency) - RunsThisWeek(history, now)
+	daysLeft := int(math.Round(weekEnd.Sub(today).Hours() / 24))
+	if remaining >= daysLeft {
+		return true
+	}
+
+	costs := dailyCosts(appliance, slots, constraints, now, weekEnd)
+	if len(costs) == 0 {
+		// No prices to compare days on
+		return true
+	}
+	todayCost, ok := costs[today]
+	if !ok {
+		return false
+	}
+
+	// Runs to place in the days we have prices for: at least those that can't
+	// wait for later days, otherwise their share of what's left
+	known := len(costs)
+	pick := max(remaining-(daysLeft-known), int(math.Round(float64(remaining*known)/float64(daysLeft))))
+
+	cheaper := 0
+	for _, cost := range costs {
+		if cost < todayCost {
+			cheaper++
+		}
+	}
+	return cheaper < pick
+}
+
+// dailyCosts returns the cost of the cheapest window for an appliance on each
+// local day from now until end, leaving out days with no window that fits. The
+// deadlines in constraints are for one run, so aren't held against every day.
+func dailyCosts(appliance *Appliance, slots []PriceSlot, constraints Constraints, now, end time.Time) map[time.Time]float64 {
+	byDay := make(map[time.Time][]PriceSlot)
+	for _, slot := range slots {
+		if slot.Start.Before(now) || !slot.Start.Before(end) {
+			continue
+		}
+		day := localDate(slot.Start)
+		byDay[day] = append(byDay[day], slot)
+	}
+
+	constraints.FinishBy, constraints.StartBy = nil, nil
+	opts := Options{EstKWh: appliance.EstKWh, PowerProfile: appliance.PowerProfile}
+	costs := make(map[time.Time]float64)
+	for day, daySlots := range byDay {
+		recs, err := BestWindows(daySlots, appliance.CycleMinutes, constraints, opts, 1)
+		if err != nil {
+			continue
+		}
+		costs[day] = recs[0].CostGBP
+	}
+	return costs
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShouldShowRecommendation(t *testing.T) {
+	// Tuesday 2 December 2025, 06:00
+	now := time.Date(2025, 12, 2, 6, 0, 0, 0, time.Local)
+	today := time.Date(2025, 12, 2, 0, 0, 0, 0, time.Local)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	daySlots := func(day time.Time, price float64) []PriceSlot {
+		prices := make([]float64, 48)
+		for i := range prices {
+			prices[i] = price
+		}
+		return makeSlots(day, prices)
+	}
+	cheapToday := append(daySlots(today, 10), daySlots(tomorrow, 20)...)
+	cheapTomorrow := append(daySlots(today, 20), daySlots(tomorrow, 10)...)
+
+	// Tomorrow is Wednesday, which this appliance can't use
+	notWednesday := Constraints{Blocked: []TimeWindow{{Start: "00:00", End: "23:59", DaysOfWeek: []int{3}}}}
+
+	monday := now.AddDate(0, 0, -1)
+	lastWeek := now.AddDate(0, 0, -5)
+
+	tests := []struct {
+		name        string
+		frequency   UsageFrequency
+		history     []time.Time
+		now         time.Time
+		slots       []PriceSlot
+		constraints Constraints
+		want        bool
+	}{
+		{name: "daily not yet run", frequency: FrequencyDaily, now: now, slots: cheapTomorrow, want: true},
+		{name: "daily already run today", frequency: FrequencyDaily, history: []time.Time{now.Add(-time.Hour)}, now: now, slots: cheapToday, want: false},
+		{name: "on demand", frequency: FrequencyOnDemand, now: now, slots: cheapToday, want: false},
+		{name: "3x week cheaper today", frequency: Frequency3xWeek, now: now, slots: cheapToday, want: true},
+		{name: "3x week cheaper tomorrow", frequency: Frequency3xWeek, now: now, slots: cheapTomorrow, want: false},
+		{name: "3x week cheaper tomorrow but blocked", frequency: Frequency3xWeek, now: now, slots: cheapTomorrow, constraints: notWednesday, want: true},
+		{name: "3x week done", frequency: Frequency3xWeek, history: []time.Time{monday, monday.Add(time.Hour), monday.Add(2 * time.Hour)}, now: now, slots: cheapToday, want: false},
+		{name: "runs last week don't count", frequency: Frequency3xWeek, history: []time.Time{lastWeek, lastWeek, lastWeek}, now: now, slots: cheapToday, want: true},
+		{name: "weekly waits early in the week", frequency: FrequencyWeekly, now: now, slots: cheapToday, want: false},
+		{name: "weekly runs out of days", frequency: FrequencyWeekly, now: now.AddDate(0, 0, 5), slots: nil, want: true},
+		{name: "weekly done", frequency: FrequencyWeekly, history: []time.Time{monday}, now: now.AddDate(0, 0, 5), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Appliance{Name: "Washer", CycleMinutes: 60, EstKWh: 1, UsageFrequency: tt.frequency}
+			if got := ShouldShowRecommendation(a, tt.history, tt.slots, tt.constraints, tt.now); got != tt.want {
+				t.Errorf("ShouldShowRecommendation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
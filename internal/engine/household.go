@@ -0,0 +1,363 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HouseholdLoad is one appliance to be placed in a joint household schedule
+type HouseholdLoad struct {
+	Appliance   *Appliance
+	Constraints Constraints
+	Options     Options
+	EVCharge    *EVCharge // Set for an EV, with the charge applied to Constraints and Options
+}
+
+// PlannedRun is the window allocated to one appliance in a household schedule
+type PlannedRun struct {
+	ApplianceID    string
+	ApplianceName  string
+	Priority       int
+	PowerKW        float64
+	Recommendation Recommendation
+	EVCharge       *EVChargePlan // For an EV, what the charge achieves
+}
+
+// UnscheduledLoad records an appliance the planner could not fit
+type UnscheduledLoad struct {
+	ApplianceID   string
+	ApplianceName string
+	Reason        string
+}
+
+// HouseholdPlan is a single coherent schedule covering every appliance
+type HouseholdPlan struct {
+	Runs         []PlannedRun
+	Unscheduled  []UnscheduledLoad
+	TotalCostGBP float64
+	PeakKW       float64 // Highest combined appliance draw in any slot
+	MaxImportKW  float64 // Limit the plan was built against; 0 = unlimited
+}
+
+// heavyLoadKW is the draw above which an appliance counts as a heavy load for
+// staggering
+const heavyLoadKW = 2.0
+
+// PlanHousehold allocates a window to every load at once so that the combined
+// appliance draw never exceeds the household's MaxImportKW in any price slot,
+// and, when StaggerHeavyLoads is set, heavy appliances are kept at least
+// StaggerGapMinutes apart. Loads are placed highest Priority first (ties keep
+// the order given), each taking its cheapest window that still satisfies both
+// rules, so lower-priority appliances are the ones pushed back or deferred to
+// the next day. Interruptible loads, EV charging among them, are re-planned in
+// whatever slots are left rather than dropped, so a car still charges before
+// its ready-by time; one that can't reach its target in time charges what it
+// can and says how far short it will be, as PlanEVCharge does. Each displaced
+// run's reason names the appliances that took its cheapest window.
+func PlanHousehold(slots []PriceSlot, loads []HouseholdLoad, household *Household) (*HouseholdPlan, error) {
+	if len(slots) == 0 || household == nil {
+		return nil, ErrInvalidInput
+	}
+
+	maxImportKW := household.MaxImportKW
+	gap := time.Duration(household.StaggerGapMinutes) * time.Minute
+
+	plan := &HouseholdPlan{MaxImportKW: maxImportKW}
+	usage := make(map[int64]float64) // slot start (unix) -> allocated kW
+	heavyRuns := []PlannedRun{}
+
+	// Higher-priority appliances get first pick of the cheap slots
+	ordered := make([]HouseholdLoad, len(loads))
+	copy(ordered, loads)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Appliance.Priority > ordered[j].Appliance.Priority
+	})
+
+	for _, load := range ordered {
+		a := load.Appliance
+		kw := loadKW(a)
+		heavy := household.StaggerHeavyLoads && isHeavyLoad(a)
+
+		if maxImportKW > 0 && kw > maxImportKW {
+			plan.Unscheduled = append(plan.Unscheduled, UnscheduledLoad{
+				ApplianceID:   a.ID,
+				ApplianceName: a.Name,
+				Reason:        fmt.Sprintf("draws %.1f kW, above the %.1f kW household limit", kw, maxImportKW),
+			})
+			continue
+		}
+
+		// A car that can't be charged to its target in time gets what it can;
+		// one already at its target needs no run at all
+		runMinutes := a.CycleMinutes
+		shortfall := ""
+		var charge *EVChargePlan
+		if ev := load.EVCharge; ev != nil {
+			if load.Options.EstKWh == 0 {
+				continue
+			}
+			charge = &EVChargePlan{
+				EnergyKWh:   load.Options.EstKWh,
+				ExpectedSoC: math.Max(ev.SoCPercent, ev.TargetPercent),
+				Reachable:   true,
+			}
+			if !ev.checkReach(slots, load.Constraints, charge) {
+				shortfall = charge.Warning
+				if charge.EnergyKWh == 0 {
+					plan.Unscheduled = append(plan.Unscheduled, UnscheduledLoad{
+						ApplianceID:   a.ID,
+						ApplianceName: a.Name,
+						Reason:        shortfall,
+					})
+					continue
+				}
+				// Charge flat out in every usable slot, whatever the block settings
+				load.Options.EstKWh = charge.EnergyKWh
+				load.Options.Interruptible = &InterruptibleLoad{PowerKW: ev.ChargerKW}
+			}
+			runMinutes = ev.chargeMinutes(load.Options.EstKWh)
+		}
+
+		// Every feasible window, cheapest first; with sub-slot starts there are
+		// several per slot
+		candidates, err := BestWindows(slots, runMinutes, load.Constraints, load.Options, AllWindows)
+		if err != nil {
+			plan.Unscheduled = append(plan.Unscheduled, UnscheduledLoad{
+				ApplianceID:   a.ID,
+				ApplianceName: a.Name,
+				Reason:        err.Error(),
+			})
+			continue
+		}
+
+		chosen := -1
+		for i, c := range candidates {
+			if !fitsPowerBudget(usage, c, kw, maxImportKW) {
+				continue
+			}
+			if heavy && clashingRun(heavyRuns, c, gap) != nil {
+				continue
+			}
+			chosen = i
+			break
+		}
+
+		// An interruptible load has only one answer, its cheapest slots; when
+		// those are taken it is re-planned in the slots still free
+		if chosen < 0 && load.Options.Interruptible != nil {
+			taken := heavyRuns
+			if !heavy {
+				taken = nil
+			}
+			free := freeSlots(slots, usage, kw, maxImportKW, taken, gap)
+			replanned, err := BestWindows(free, runMinutes, load.Constraints, load.Options, 1)
+			if err == nil && fitsPowerBudget(usage, replanned[0], kw, maxImportKW) {
+				candidates = append(candidates, replanned[0])
+				chosen = len(candidates) - 1
+			}
+		}
+
+		if chosen < 0 {
+			reason := fmt.Sprintf("no window keeps the household under %.1f kW", maxImportKW)
+			if heavy {
+				reason = "no window leaves enough gap from other heavy loads"
+			}
+			if blockers := displacingRuns(plan, heavyRuns, candidates[0], heavy, gap); blockers != "" {
+				reason = fmt.Sprintf("%s; displaced by %s", reason, blockers)
+			}
+			if shortfall != "" {
+				reason = fmt.Sprintf("%s; %s", shortfall, reason)
+			}
+			plan.Unscheduled = append(plan.Unscheduled, UnscheduledLoad{
+				ApplianceID:   a.ID,
+				ApplianceName: a.Name,
+				Reason:        reason,
+			})
+			continue
+		}
+
+		rec := candidates[chosen]
+		if chosen > 0 {
+			cheapest := candidates[0]
+			extra := rec.CostGBP - cheapest.CostGBP
+			if other := clashingRun(heavyRuns, cheapest, gap); heavy && other != nil {
+				rec.Reason = fmt.Sprintf("%s; staggered from %s at %s (+£%.2f)",
+					rec.Reason, other.ApplianceName, other.Recommendation.Start.Local().Format("15:04"), extra)
+			} else {
+				rec.Reason = fmt.Sprintf("%s; moved from %s to stay under %.1f kW (+£%.2f)",
+					rec.Reason, cheapest.Start.Local().Format("15:04"), maxImportKW, extra)
+			}
+			if blockers := displacingRuns(plan, heavyRuns, cheapest, heavy, gap); blockers != "" {
+				rec.Reason = fmt.Sprintf("%s; displaced by %s", rec.Reason, blockers)
+			}
+			if localDate(rec.Start).After(localDate(cheapest.Start)) {
+				rec.Reason = fmt.Sprintf("%s; deferred to %s", rec.Reason, rec.Start.Local().Format("Monday"))
+			}
+		}
+
+		if ev := load.EVCharge; ev != nil {
+			rec.Reason = fmt.Sprintf("%s; %.0f%% to %.0f%% by %s",
+				rec.Reason, ev.SoCPercent, charge.ExpectedSoC, ev.ReadyBy.Local().Format("15:04"))
+		}
+		if shortfall != "" {
+			rec.Reason = fmt.Sprintf("%s; %s", rec.Reason, shortfall)
+		}
+
+		run := PlannedRun{
+			ApplianceID:    a.ID,
+			ApplianceName:  a.Name,
+			Priority:       a.Priority,
+			PowerKW:        kw,
+			Recommendation: rec,
+		}
+		if charge != nil {
+			charged := rec
+			charge.Recommendation = &charged
+			run.EVCharge = charge
+		}
+
+		allocatePower(usage, rec, kw)
+		if heavy {
+			heavyRuns = append(heavyRuns, run)
+		}
+		plan.Runs = append(plan.Runs, run)
+		plan.TotalCostGBP += rec.CostGBP
+	}
+
+	for _, kw := range usage {
+		if kw > plan.PeakKW {
+			plan.PeakKW = kw
+		}
+	}
+
+	return plan, nil
+}
+
+// displacingRuns names the already-planned runs that kept a load out of its
+// cheapest window: those sharing its slots when there is a power limit, and
+// heavy runs within gap of it when it is staggered
+func displacingRuns(plan *HouseholdPlan, heavyRuns []PlannedRun, want Recommendation, heavy bool, gap time.Duration) string {
+	names := []string{}
+	seen := make(map[string]bool)
+	add := func(r PlannedRun) {
+		if !seen[r.ApplianceID] {
+			seen[r.ApplianceID] = true
+			names = append(names, fmt.Sprintf("%s (priority %d)", r.ApplianceName, r.Priority))
+		}
+	}
+
+	if plan.MaxImportKW > 0 {
+		for _, r := range plan.Runs {
+			if spansOverlap(runSpans(r.Recommendation), runSpans(want)) {
+				add(r)
+			}
+		}
+	}
+	if heavy {
+		for _, r := range heavyRuns {
+			if spansWithin(runSpans(r.Recommendation), runSpans(want), gap) {
+				add(r)
+			}
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// spansOverlap reports whether any span in a shares time with any span in b
+func spansOverlap(a, b []ChargeBlock) bool {
+	return spansWithin(a, b, 0)
+}
+
+// spansWithin reports whether any span in a overlaps or comes within gap of
+// any span in b
+func spansWithin(a, b []ChargeBlock, gap time.Duration) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.Start.Before(y.End.Add(gap)) && y.Start.Before(x.End.Add(gap)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// localDate returns midnight local time on the day t falls on
+func localDate(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// isHeavyLoad reports whether an appliance should be staggered from others,
+// judged by the power it draws rather than the energy a cycle takes
+func isHeavyLoad(a *Appliance) bool {
+	return loadKW(a) >= heavyLoadKW
+}
+
+// clashingRun returns the first run that rec overlaps or comes within gap of,
+// comparing the blocks interruptible runs actually draw power in
+func clashingRun(runs []PlannedRun, rec Recommendation, gap time.Duration) *PlannedRun {
+	for i := range runs {
+		if spansWithin(runSpans(rec), runSpans(runs[i].Recommendation), gap) {
+			return &runs[i]
+		}
+	}
+	return nil
+}
+
+// freeSlots returns the slots a load drawing kw can still use: those with that
+// much power to spare under the limit, and clear by gap of every run in taken
+func freeSlots(slots []PriceSlot, usage map[int64]float64, kw, maxImportKW float64, taken []PlannedRun, gap time.Duration) []PriceSlot {
+	free := []PriceSlot{}
+	for _, slot := range slots {
+		if maxImportKW > 0 && usage[slot.Start.Unix()]+kw > maxImportKW {
+			continue
+		}
+		if clashingRun(taken, Recommendation{Start: slot.Start, End: slot.End}, gap) != nil {
+			continue
+		}
+		free = append(free, slot)
+	}
+	return free
+}
+
+// loadKW returns the power an appliance draws while running, preferring the
+// rated power, then the profile peak, and falling back to the cycle average
+func loadKW(a *Appliance) float64 {
+	if a.RatedKW > 0 {
+		return a.RatedKW
+	}
+	if len(a.PowerProfile) > 0 {
+		return profilePeakKW(a.PowerProfile)
+	}
+	if a.CycleMinutes <= 0 {
+		return 0
+	}
+	return a.EstKWh / (float64(a.CycleMinutes) / 60.0)
+}
+
+// fitsPowerBudget checks whether adding kw while rec runs stays within the limit
+func fitsPowerBudget(usage map[int64]float64, rec Recommendation, kw, maxImportKW float64) bool {
+	if maxImportKW <= 0 {
+		return true
+	}
+	for _, span := range runSpans(rec) {
+		for t := span.Start.Truncate(30 * time.Minute); t.Before(span.End); t = t.Add(30 * time.Minute) {
+			if usage[t.Unix()]+kw > maxImportKW {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// allocatePower records kw as drawn in every slot rec runs in
+func allocatePower(usage map[int64]float64, rec Recommendation, kw float64) {
+	for _, span := range runSpans(rec) {
+		for t := span.Start.Truncate(30 * time.Minute); t.Before(span.End); t = t.Add(30 * time.Minute) {
+			usage[t.Unix()] += kw
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeSlots builds contiguous half-hourly slots starting at base
+func makeSlots(base time.Time, prices []float64) []PriceSlot {
+	slots := make([]PriceSlot, len(prices))
+	for i, p := range prices {
+		slots[i] = PriceSlot{
+			Start:       base.Add(time.Duration(i) * 30 * time.Minute),
+			End:         base.Add(time.Duration(i+1) * 30 * time.Minute),
+			PencePerKWh: p,
+			IncludesVAT: true,
+		}
+	}
+	return slots
+}
+
+func TestPlanHousehold(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{20, 18, 5, 6, 15, 16, 25, 30})
+
+	// 2 kW for an hour each; the 05:00-06:00 pair of slots is cheapest for both
+	dishwasher := &Appliance{ID: "dw", Name: "Dishwasher", CycleMinutes: 60, EstKWh: 2.0}
+	washer := &Appliance{ID: "wm", Name: "Washer", CycleMinutes: 60, EstKWh: 2.0}
+	loads := []HouseholdLoad{
+		{Appliance: dishwasher, Options: Options{EstKWh: dishwasher.EstKWh}},
+		{Appliance: washer, Options: Options{EstKWh: washer.EstKWh}},
+	}
+
+	tests := []struct {
+		name          string
+		maxImportKW   float64
+		wantRuns      int
+		wantOverlap   bool
+		wantPeakAtMax float64
+	}{
+		{name: "unlimited allows overlap", maxImportKW: 0, wantRuns: 2, wantOverlap: true, wantPeakAtMax: 4},
+		{name: "limit separates loads", maxImportKW: 3, wantRuns: 2, wantOverlap: false, wantPeakAtMax: 3},
+		{name: "limit below single load", maxImportKW: 1, wantRuns: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanHousehold(slots, loads, &Household{MaxImportKW: tt.maxImportKW})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(plan.Runs) != tt.wantRuns {
+				t.Fatalf("got %d runs, want %d (unscheduled: %+v)", len(plan.Runs), tt.wantRuns, plan.Unscheduled)
+			}
+			if len(plan.Runs)+len(plan.Unscheduled) != len(loads) {
+				t.Errorf("every load should be either scheduled or unscheduled")
+			}
+			if tt.wantRuns < 2 {
+				return
+			}
+
+			a, b := plan.Runs[0].Recommendation, plan.Runs[1].Recommendation
+			overlap := a.Start.Before(b.End) && b.Start.Before(a.End)
+			if overlap != tt.wantOverlap {
+				t.Errorf("overlap = %v, want %v (%s-%s vs %s-%s)", overlap, tt.wantOverlap,
+					a.Start.Format("15:04"), a.End.Format("15:04"), b.Start.Format("15:04"), b.End.Format("15:04"))
+			}
+			if plan.PeakKW > tt.wantPeakAtMax {
+				t.Errorf("peak %.1f kW exceeds %.1f kW", plan.PeakKW, tt.wantPeakAtMax)
+			}
+		})
+	}
+}
+
+func TestPlanHouseholdStaggersHeavyLoads(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{5, 6, 7, 8, 9, 10, 11, 12})
+
+	kettle := &Appliance{ID: "k", Name: "Kettle", CycleMinutes: 30, EstKWh: 0.2}
+	// No rating, but 2.4 kWh in an hour is a 2.4 kW draw
+	dishwasher := &Appliance{ID: "dw", Name: "Dishwasher", CycleMinutes: 60, EstKWh: 2.4}
+	washer := &Appliance{ID: "wm", Name: "Washer", CycleMinutes: 60, RatedKW: 2.2, EstKWh: 0.9}
+	loads := []HouseholdLoad{
+		{Appliance: dishwasher, Options: Options{EstKWh: dishwasher.EstKWh}},
+		{Appliance: washer, Options: Options{EstKWh: washer.EstKWh}},
+		{Appliance: kettle, Options: Options{EstKWh: kettle.EstKWh}},
+	}
+
+	household := &Household{StaggerHeavyLoads: true, StaggerGapMinutes: 30}
+	plan, err := PlanHousehold(slots, loads, household)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Runs) != 3 {
+		t.Fatalf("got %d runs, want 3 (unscheduled: %+v)", len(plan.Runs), plan.Unscheduled)
+	}
+
+	dw, wm, k := plan.Runs[0].Recommendation, plan.Runs[1].Recommendation, plan.Runs[2].Recommendation
+	if gap := wm.Start.Sub(dw.End); gap < 30*time.Minute {
+		t.Errorf("heavy loads only %v apart, want >= 30m", gap)
+	}
+	if !strings.Contains(wm.Reason, "staggered from Dishwasher") {
+		t.Errorf("reason should explain the stagger, got %q", wm.Reason)
+	}
+	// Light loads are not staggered and keep the cheapest slot
+	if !k.Start.Equal(base) {
+		t.Errorf("kettle moved to %s, want %s", k.Start.Format("15:04"), base.Format("15:04"))
+	}
+}
+
+func TestPlanHouseholdHeavyByPower(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{5, 6, 7, 8, 9, 10, 11, 12})
+
+	// A default 1 kWh, hour-long cycle averages only 1 kW
+	dishwasher := &Appliance{ID: "dw", Name: "Dishwasher", CycleMinutes: 60, EstKWh: 1.0}
+	washer := &Appliance{ID: "wm", Name: "Washer", CycleMinutes: 60, RatedKW: 2.2, EstKWh: 0.9}
+	if isHeavyLoad(dishwasher) {
+		t.Errorf("a 1 kW dishwasher counted as a heavy load")
+	}
+	loads := []HouseholdLoad{
+		{Appliance: dishwasher, Options: Options{EstKWh: dishwasher.EstKWh}},
+		{Appliance: washer, Options: Options{EstKWh: washer.EstKWh}},
+	}
+
+	plan, err := PlanHousehold(slots, loads, &Household{StaggerHeavyLoads: true, StaggerGapMinutes: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Runs) != 2 {
+		t.Fatalf("got %d runs, want 2 (unscheduled: %+v)", len(plan.Runs), plan.Unscheduled)
+	}
+	// Only one heavy load, so nothing to stagger and both take the cheapest hour
+	for _, run := range plan.Runs {
+		if !run.Recommendation.Start.Equal(base) {
+			t.Errorf("%s moved to %s, want %s", run.ApplianceName,
+				run.Recommendation.Start.Format("15:04"), base.Format("15:04"))
+		}
+	}
+}
+
+func TestPlanHouseholdPriority(t *testing.T) {
+	base := time.Date(2024, 12, 1, 22, 0, 0, 0, time.Local)
+	// One cheap hour tonight, dear until tomorrow's cheap hour at 02:00
+	slots := makeSlots(base, []float64{5, 5, 30, 30, 30, 30, 8, 8})
+
+	washer := &Appliance{ID: "wm", Name: "Washer", CycleMinutes: 60, EstKWh: 2.0, Priority: 1}
+	dishwasher := &Appliance{ID: "dw", Name: "Dishwasher", CycleMinutes: 60, EstKWh: 2.0, Priority: 5}
+	// Listed low priority first; the dishwasher must still win tonight's slots
+	loads := []HouseholdLoad{
+		{Appliance: washer, Options: Options{EstKWh: washer.EstKWh}},
+		{Appliance: dishwasher, Options: Options{EstKWh: dishwasher.EstKWh}},
+	}
+
+	plan, err := PlanHousehold(slots, loads, &Household{MaxImportKW: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Runs) != 2 {
+		t.Fatalf("got %d runs, want 2 (unscheduled: %+v)", len(plan.Runs), plan.Unscheduled)
+	}
+
+	dw, wm := plan.Runs[0], plan.Runs[1]
+	if dw.ApplianceID != "dw" || !dw.Recommendation.Start.Equal(base) {
+		t.Errorf("dishwasher should take 22:00 first, got %s at %s", dw.ApplianceName, dw.Recommendation.Start.Format("15:04"))
+	}
+	if want := base.Add(3 * time.Hour); !wm.Recommendation.Start.Equal(want) {
+		t.Errorf("washer at %s, want %s", wm.Recommendation.Start.Format("15:04"), want.Format("15:04"))
+	}
+	for _, want := range []string{"displaced by Dishwasher (priority 5)", "deferred to"} {
+		if !strings.Contains(wm.Recommendation.Reason, want) {
+			t.Errorf("washer reason should contain %q, got %q", want, wm.Recommendation.Reason)
+		}
+	}
+	if strings.Contains(dw.Recommendation.Reason, "displaced") {
+		t.Errorf("dishwasher was not displaced, got %q", dw.Recommendation.Reason)
+	}
+}
+
+func TestPlanHouseholdSubSlotStarts(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{1, 2, 3, 4, 5, 6, 7, 8})
+
+	// Two 3 kW hour-long loads under a 4 kW limit, starting on any 5 minutes.
+	// The dozen starts cheaper than 01:00 all clash with the first load.
+	first := &Appliance{ID: "a", Name: "First", CycleMinutes: 60, RatedKW: 3, EstKWh: 3, Priority: 2}
+	second := &Appliance{ID: "b", Name: "Second", CycleMinutes: 60, RatedKW: 3, EstKWh: 3, Priority: 1}
+	loads := []HouseholdLoad{
+		{Appliance: first, Options: Options{EstKWh: 3, StartStepMinutes: 5}},
+		{Appliance: second, Options: Options{EstKWh: 3, StartStepMinutes: 5}},
+	}
+
+	plan, err := PlanHousehold(slots, loads, &Household{MaxImportKW: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Runs) != 2 {
+		t.Fatalf("got %d runs, want 2 (unscheduled: %+v)", len(plan.Runs), plan.Unscheduled)
+	}
+	if want := base.Add(time.Hour); !plan.Runs[1].Recommendation.Start.Equal(want) {
+		t.Errorf("second load at %s, want %s", plan.Runs[1].Recommendation.Start.Format("15:04"), want.Format("15:04"))
+	}
+}
+
+func TestPlanHouseholdInterruptible(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{1, 2, 9, 9, 3, 4, 9, 9})
+
+	// The washer takes the cheapest hour; the storage heater wants the same two
+	// slots but can pause, so it moves to the next cheapest pair
+	washer := &Appliance{ID: "wm", Name: "Washer", CycleMinutes: 60, RatedKW: 3, EstKWh: 3, Priority: 2}
+	heater := &Appliance{ID: "sh", Name: "Heater", CycleMinutes: 60, RatedKW: 3, EstKWh: 3, Priority: 1, Interruptible: true}
+	loads := []HouseholdLoad{
+		{Appliance: washer, Options: Options{EstKWh: 3}},
+		{Appliance: heater, Options: Options{EstKWh: 3, Interruptible: InterruptibleFor(heater)}},
+	}
+
+	plan, err := PlanHousehold(slots, loads, &Household{MaxImportKW: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Runs) != 2 {
+		t.Fatalf("got %d runs, want 2 (unscheduled: %+v)", len(plan.Runs), plan.Unscheduled)
+	}
+	heat := plan.Runs[1].Recommendation
+	if want := base.Add(2 * time.Hour); !heat.Start.Equal(want) || !heat.End.Equal(want.Add(time.Hour)) {
+		t.Errorf("heater runs %s-%s, want 02:00-03:00", heat.Start.Format("15:04"), heat.End.Format("15:04"))
+	}
+	if plan.PeakKW > 4 {
+		t.Errorf("peak %.1f kW exceeds the 4 kW limit", plan.PeakKW)
+	}
+	if !strings.Contains(heat.Reason, "displaced by Washer (priority 2)") {
+		t.Errorf("heater reason should name the washer, got %q", heat.Reason)
+	}
+}
+
+func TestPlanHouseholdEVCharge(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{1, 2, 9, 9, 3, 4, 9, 9})
+
+	// The dishwasher outranks the car and takes its cheapest hour; with heavy
+	// loads staggered the car must charge in the pair after that by 04:00
+	dishwasher := &Appliance{ID: "dw", Name: "Dishwasher", CycleMinutes: 60, RatedKW: 2.2, EstKWh: 1.2, Priority: 5}
+	car := &Appliance{ID: "ev", Name: "Car", Class: ClassEV, RatedKW: 7, BatteryKWh: 10, Priority: 1}
+	ev := EVCharge{BatteryKWh: 10, SoCPercent: 50, TargetPercent: 85, ChargerKW: 7, ReadyBy: base.Add(4 * time.Hour)}
+	constraints, opts := Constraints{}, Options{}
+	ev.Apply(&constraints, &opts)
+
+	loads := []HouseholdLoad{
+		{Appliance: car, Constraints: constraints, Options: opts, EVCharge: &ev},
+		{Appliance: dishwasher, Options: Options{EstKWh: dishwasher.EstKWh}},
+	}
+	plan, err := PlanHousehold(slots, loads, &Household{StaggerHeavyLoads: true, StaggerGapMinutes: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Runs) != 2 {
+		t.Fatalf("got %d runs, want 2 (unscheduled: %+v)", len(plan.Runs), plan.Unscheduled)
+	}
+
+	charge := plan.Runs[1].Recommendation
+	if want := base.Add(2 * time.Hour); !charge.Start.Equal(want) || !charge.End.Equal(want.Add(time.Hour)) {
+		t.Errorf("car charges %s-%s, want 02:00-03:00", charge.Start.Format("15:04"), charge.End.Format("15:04"))
+	}
+	if charge.End.After(ev.ReadyBy) {
+		t.Errorf("car charges until %s, after its ready-by time", charge.End.Format("15:04"))
+	}
+	if got := plan.Runs[1].EVCharge; got == nil || !got.Reachable || got.ExpectedSoC != 85 {
+		t.Errorf("EV charge %+v, want the 85%% target reached", got)
+	}
+}
+
+func TestPlanHouseholdEVUnreachable(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{1, 2, 9, 9, 3, 4, 9, 9})
+
+	// Two slots before 01:00 give 7 kWh, short of the 10 kWh to go from 20% to 90%
+	car := &Appliance{ID: "ev", Name: "Car", Class: ClassEV, RatedKW: 7, BatteryKWh: 12.86}
+	ev := &EVCharge{BatteryKWh: 12.86, SoCPercent: 20, TargetPercent: 90, ChargerKW: 7, ReadyBy: base.Add(time.Hour)}
+	constraints, opts := Constraints{}, Options{}
+	ev.Apply(&constraints, &opts)
+
+	loads := []HouseholdLoad{{Appliance: car, Constraints: constraints, Options: opts, EVCharge: ev}}
+	plan, err := PlanHousehold(slots, loads, &Household{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Runs) != 1 {
+		t.Fatalf("got %d runs, want 1 (unscheduled: %+v)", len(plan.Runs), plan.Unscheduled)
+	}
+
+	// It charges in both slots and warns as PlanEVCharge would
+	charge := plan.Runs[0].Recommendation
+	if !charge.Start.Equal(base) || !charge.End.Equal(base.Add(time.Hour)) {
+		t.Errorf("car charges %s-%s, want 00:00-01:00", charge.Start.Format("15:04"), charge.End.Format("15:04"))
+	}
+	single, err := PlanEVCharge(slots, *ev, Constraints{}, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if single.Reachable || !strings.Contains(charge.Reason, single.Warning) {
+		t.Errorf("reason %q should carry the warning %q", charge.Reason, single.Warning)
+	}
+	if got := plan.Runs[0].EVCharge; got == nil || got.Reachable || got.Warning != single.Warning ||
+		math.Abs(got.ExpectedSoC-single.ExpectedSoC) > 1e-9 {
+		t.Errorf("EV charge %+v, want it to match %+v", got, single)
+	}
+
+	// With no time to charge at all it is left out with the same warning
+	ev.ReadyBy = base
+	constraints, opts = Constraints{}, Options{}
+	ev.Apply(&constraints, &opts)
+	loads = []HouseholdLoad{{Appliance: car, Constraints: constraints, Options: opts, EVCharge: ev}}
+	plan, _ = PlanHousehold(slots, loads, &Household{})
+	if len(plan.Unscheduled) != 1 || !strings.Contains(plan.Unscheduled[0].Reason, "unreachable") {
+		t.Errorf("unscheduled %+v, want the car left out as unreachable", plan.Unscheduled)
+	}
+}
@@ -0,0 +1,295 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// InterruptibleFor returns the interruptible settings for an appliance, or nil
+// when it has to run as one contiguous cycle
+func InterruptibleFor(a *Appliance) *InterruptibleLoad {
+	if a == nil || !a.Interruptible {
+		return nil
+	}
+	return &InterruptibleLoad{
+		PowerKW:          a.RatedKW,
+		MinBlockMinutes:  a.MinBlockMinutes,
+		MaxInterruptions: a.MaxInterruptions,
+	}
+}
+
+// chargeState is a position in the interruptible planner's search: slots
+// taken so far, blocks opened, and the length of the block currently running
+// (capped at the minimum block length; 0 = not running)
+type chargeState struct {
+	taken, blocks, run int
+}
+
+// bestInterruptibleWindow picks the cheapest set of feasible slots that
+// delivers opts.EstKWh at the load's power before the deadline. Slots need not
+// be adjacent, but every block must last at least MinBlockMinutes and there
+// may be no more than MaxInterruptions pauses. The result is a single
+// recommendation spanning the first to the last block.
+func bestInterruptibleWindow(slots []PriceSlot, feasible []PriceSlot, runMinutes int, constraints Constraints, opts Options) ([]Recommendation, error) {
+	load := opts.Interruptible
+	powerKW := load.PowerKW
+	if powerKW <= 0 {
+		powerKW = opts.EstKWh / (float64(runMinutes) / 60.0)
+	}
+	if opts.EstKWh <= 0 || powerKW <= 0 {
+		return nil, ErrInvalidInput
+	}
+
+	// Every chosen slot has to be over before the deadline, not just start
+	// before it; the tolerance allows some running past it at a penalty
+	if latest := latestFinish(constraints); latest != nil {
+		within := []PriceSlot{}
+		for _, slot := range feasible {
+			if !slot.End.After(*latest) {
+				within = append(within, slot)
+			}
+		}
+		feasible = within
+	}
+
+	// Slots needed at full power; the last one may only be partly used
+	slotKWh := powerKW * 0.5
+	needed := int(math.Ceil(opts.EstKWh/slotKWh - 1e-9))
+	if needed > len(feasible) {
+		return nil, ErrNoFeasibleSlots
+	}
+
+	minRun := int(math.Ceil(float64(load.MinBlockMinutes) / 30.0))
+	minRun = max(1, min(minRun, needed))
+	maxBlocks := 0
+	if load.MaxInterruptions > 0 {
+		maxBlocks = min(load.MaxInterruptions+1, needed)
+	}
+
+	// Score each slot as if charged at full power for its whole length
+	intensity := newCarbonLookup(opts.CarbonIntensity)
+	pv := newPVLookup(opts.PVForecast, opts.ExportPrices)
+	slotScore := make([]float64, len(feasible))
+	perSlot := make([]Recommendation, len(feasible))
+	for i, slot := range feasible {
+		solar := math.Min(slotKWh, pv.surplusKWh(slot.Start))
+		solarScore, _ := pv.solarCost(slot, solar, opts.PVWeight)
+		perSlot[i].Score = slot.PencePerKWh*(slotKWh-solar) + solarScore +
+			lateMinutes(constraints, slot)*opts.LatePenaltyPence
+		perSlot[i].KgCO2 = intensity.at(slot.Start) * (slotKWh - solar) / 1000.0
+	}
+
+	// Weigh emissions against cost per slot just as BestWindows does per window
+	if intensity.available() && opts.CarbonWeight > 0 {
+		blendCarbonScores(perSlot, opts.CarbonWeight)
+	}
+	for i := range perSlot {
+		slotScore[i] = perSlot[i].Score
+	}
+
+	chosen := cheapestBlocks(feasible, slotScore, needed, minRun, maxBlocks)
+	if chosen == nil {
+		return nil, ErrNoFeasibleSlots
+	}
+
+	// Energy per chosen slot, trimming the surplus from the dearest one
+	energy := make([]float64, len(chosen))
+	dearest := 0
+	for k, i := range chosen {
+		energy[k] = slotKWh
+		if feasible[i].PencePerKWh > feasible[chosen[dearest]].PencePerKWh {
+			dearest = k
+		}
+	}
+	energy[dearest] -= float64(needed)*slotKWh - opts.EstKWh
+
+	rec := Recommendation{}
+	window := make([]PriceSlot, 0, len(chosen))
+	var totalPence, exportPence, grams, meanIntensity, late float64
+	for k, i := range chosen {
+		slot := feasible[i]
+		window = append(window, slot)
+
+		solar := math.Min(energy[k], pv.surplusKWh(slot.Start))
+		grid := energy[k] - solar
+		g := intensity.at(slot.Start)
+
+		solarScore, lostExport := pv.solarCost(slot, solar, opts.PVWeight)
+		low, high := slot.band()
+		totalPence += slot.PencePerKWh * energy[k]
+		exportPence += lostExport
+		rec.CostGBP += (slot.PencePerKWh*grid + lostExport) / 100.0
+		rec.CostLowGBP += (low*grid + lostExport) / 100.0
+		rec.CostHighGBP += (high*grid + lostExport) / 100.0
+		rec.Predicted = rec.Predicted || slot.Predicted
+		rec.Score += slot.PencePerKWh*grid + solarScore +
+			lateMinutes(constraints, slot)*opts.LatePenaltyPence
+		late += lateMinutes(constraints, slot)
+		rec.SolarKWh += solar
+		grams += g * grid
+		meanIntensity += g
+
+		if n := len(rec.Blocks); n > 0 && rec.Blocks[n-1].End.Equal(slot.Start) {
+			rec.Blocks[n-1].End = slot.End
+			rec.Blocks[n-1].KWh += energy[k]
+		} else {
+			rec.Blocks = append(rec.Blocks, ChargeBlock{Start: slot.Start, End: slot.End, KWh: energy[k]})
+		}
+	}
+	rec.Start = rec.Blocks[0].Start
+	rec.End = rec.Blocks[len(rec.Blocks)-1].End
+	rec.KgCO2 = grams / 1000.0
+	rec.LostExportGBP = exportPence / 100.0
+
+	reason := generateReason(window, totalPence, slots)
+	if len(rec.Blocks) == 1 {
+		reason = fmt.Sprintf("%s; %.1f kWh in one block", reason, opts.EstKWh)
+	} else {
+		reason = fmt.Sprintf("%s; %.1f kWh across %d blocks (%d interruptions)",
+			reason, opts.EstKWh, len(rec.Blocks), len(rec.Blocks)-1)
+	}
+	if intensity.available() {
+		reason = fmt.Sprintf("%s; %.0f gCO2/kWh", reason, meanIntensity/float64(len(chosen)))
+	}
+	if rec.SolarKWh > 0 {
+		reason = solarReason(reason, rec.SolarKWh, exportPence)
+	}
+	if late > 0 {
+		reason = fmt.Sprintf("%s; %.0f min past deadline (+%.1fp penalty)", reason, late, late*opts.LatePenaltyPence)
+	}
+	if rec.Predicted {
+		reason = predictedReason(reason, rec.CostLowGBP, rec.CostHighGBP)
+	}
+	rec.Reason = reason
+
+	return []Recommendation{rec}, nil
+}
+
+// lateMinutes returns how much of a slot falls after FinishBy
+func lateMinutes(c Constraints, slot PriceSlot) float64 {
+	if c.FinishBy == nil || !slot.End.After(*c.FinishBy) {
+		return 0
+	}
+	return slot.End.Sub(maxTime(slot.Start, *c.FinishBy)).Minutes()
+}
+
+// cheapestBlocks chooses needed slots (indexes into slots, in time order) with
+// the lowest total score, such that every run of back-to-back chosen slots is
+// at least minRun long and, when maxBlocks is above 0, there are no more than
+// maxBlocks runs. It returns nil when no such choice exists.
+func cheapestBlocks(slots []PriceSlot, score []float64, needed, minRun, maxBlocks int) []int {
+	blockDim := 1
+	if maxBlocks > 0 {
+		blockDim = maxBlocks + 1
+	}
+	index := func(s chargeState) int {
+		return (s.taken*blockDim+s.blocks)*(minRun+1) + s.run
+	}
+	size := (needed + 1) * blockDim * (minRun + 1)
+
+	// parent[i][state] records how state was reached after slot i:
+	// the previous state index, doubled, plus one if slot i was taken
+	cost := make([]float64, size)
+	for k := range cost {
+		cost[k] = math.Inf(1)
+	}
+	cost[index(chargeState{})] = 0
+	parent := make([][]int32, len(slots))
+
+	for i := range slots {
+		next := make([]float64, size)
+		for k := range next {
+			next[k] = math.Inf(1)
+		}
+		from := make([]int32, size)
+		relax := func(s chargeState, c float64, prev int, took bool) {
+			k := index(s)
+			if c < next[k] {
+				next[k] = c
+				from[k] = int32(prev * 2)
+				if took {
+					from[k]++
+				}
+			}
+		}
+
+		adjacent := i > 0 && slots[i].Start.Equal(slots[i-1].End)
+		for taken := 0; taken <= needed; taken++ {
+			for blocks := 0; blocks < blockDim; blocks++ {
+				for run := 0; run <= minRun; run++ {
+					s := chargeState{taken, blocks, run}
+					prev := index(s)
+					c := cost[prev]
+					if math.IsInf(c, 1) {
+						continue
+					}
+					finished := run == 0 || run >= minRun
+
+					// Leave the slot idle, which ends any block that has run long enough
+					if finished {
+						relax(chargeState{taken, blocks, 0}, c, prev, false)
+					}
+
+					if taken == needed {
+						continue
+					}
+					if run > 0 && adjacent {
+						// Carry on the current block
+						relax(chargeState{taken + 1, blocks, min(run+1, minRun)}, c+score[i], prev, true)
+					} else if finished {
+						// Start a new block
+						b := blocks
+						if maxBlocks > 0 {
+							b++
+							if b > maxBlocks {
+								continue
+							}
+						}
+						relax(chargeState{taken + 1, b, min(1, minRun)}, c+score[i], prev, true)
+					}
+				}
+			}
+		}
+
+		cost = next
+		parent[i] = from
+	}
+
+	// Cheapest way to take every slot needed with the last block long enough
+	best := -1
+	for blocks := 0; blocks < blockDim; blocks++ {
+		for run := 0; run <= minRun; run++ {
+			if run != 0 && run < minRun {
+				continue
+			}
+			k := index(chargeState{needed, blocks, run})
+			if !math.IsInf(cost[k], 1) && (best < 0 || cost[k] < cost[best]) {
+				best = k
+			}
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	chosen := make([]int, 0, needed)
+	for i := len(slots) - 1; i >= 0; i-- {
+		step := parent[i][best]
+		if step%2 == 1 {
+			chosen = append(chosen, i)
+		}
+		best = int(step / 2)
+	}
+	for l, r := 0, len(chosen)-1; l < r; l, r = l+1, r-1 {
+		chosen[l], chosen[r] = chosen[r], chosen[l]
+	}
+	return chosen
+}
+
+// runSpans returns the stretches of time a recommendation actually draws power
+func runSpans(rec Recommendation) []ChargeBlock {
+	if len(rec.Blocks) > 0 {
+		return rec.Blocks
+	}
+	return []ChargeBlock{{Start: rec.Start, End: rec.End}}
+}
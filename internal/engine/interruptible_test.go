@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBestWindowsInterruptible(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	// Cheap slots at 00:30, 01:30 and 02:30 with dear ones between them
+	slots := makeSlots(base, []float64{30, 5, 30, 6, 30, 7, 30, 30})
+	deadline := base.Add(150 * time.Minute)
+
+	tests := []struct {
+		name      string
+		estKWh    float64
+		load      InterruptibleLoad
+		finishBy  *time.Time
+		wantPence float64
+		maxBlocks int
+	}{
+		{name: "cheapest slots anywhere", estKWh: 3, load: InterruptibleLoad{PowerKW: 2}, wantPence: 18, maxBlocks: 3},
+		{name: "partial last slot", estKWh: 2.5, load: InterruptibleLoad{PowerKW: 2}, wantPence: 14.5, maxBlocks: 3},
+		{name: "max one interruption", estKWh: 3, load: InterruptibleLoad{PowerKW: 2, MaxInterruptions: 1}, wantPence: 41, maxBlocks: 2},
+		{name: "minimum block length", estKWh: 3, load: InterruptibleLoad{PowerKW: 2, MinBlockMinutes: 60}, wantPence: 41, maxBlocks: 1},
+		{name: "deadline", estKWh: 3, load: InterruptibleLoad{PowerKW: 2}, finishBy: &deadline, wantPence: 41, maxBlocks: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			load := tt.load
+			opts := Options{EstKWh: tt.estKWh, Interruptible: &load}
+			recs, err := BestWindows(slots, 90, Constraints{FinishBy: tt.finishBy}, opts, 3)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(recs) != 1 {
+				t.Fatalf("got %d recommendations, want 1", len(recs))
+			}
+
+			rec := recs[0]
+			if math.Abs(rec.CostGBP*100-tt.wantPence) > 1e-6 {
+				t.Errorf("cost %.2fp, want %.2fp", rec.CostGBP*100, tt.wantPence)
+			}
+			if len(rec.Blocks) == 0 || len(rec.Blocks) > tt.maxBlocks {
+				t.Errorf("got %d blocks, want 1-%d", len(rec.Blocks), tt.maxBlocks)
+			}
+
+			kwh := 0.0
+			for _, b := range rec.Blocks {
+				kwh += b.KWh
+				if b.End.Sub(b.Start) < time.Duration(load.MinBlockMinutes)*time.Minute {
+					t.Errorf("block %s-%s shorter than %d minutes", b.Start.Format("15:04"), b.End.Format("15:04"), load.MinBlockMinutes)
+				}
+				if tt.finishBy != nil && b.End.After(*tt.finishBy) {
+					t.Errorf("block ends %s after the deadline", b.End.Format("15:04"))
+				}
+			}
+			if math.Abs(kwh-tt.estKWh) > 1e-6 {
+				t.Errorf("blocks deliver %.2f kWh, want %.2f", kwh, tt.estKWh)
+			}
+		})
+	}
+}
+
+func TestBestWindowsInterruptibleInfeasible(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{10, 10, 10, 10})
+	deadline := base.Add(60 * time.Minute)
+
+	opts := Options{EstKWh: 6, Interruptible: &InterruptibleLoad{PowerKW: 2}}
+	if _, err := BestWindows(slots, 180, Constraints{FinishBy: &deadline}, opts, 1); err != ErrNoFeasibleSlots {
+		t.Errorf("got %v, want ErrNoFeasibleSlots", err)
+	}
+}
+
+func TestBestWindowsInterruptibleCarbonWeight(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	// The two cheapest slots are the dirtiest
+	slots := makeSlots(base, []float64{10, 11, 14, 15})
+	intensity := []CarbonSlot{}
+	for i, g := range []float64{300, 280, 60, 50} {
+		start := base.Add(time.Duration(i) * 30 * time.Minute)
+		intensity = append(intensity, CarbonSlot{Start: start, End: start.Add(30 * time.Minute), GramsPerKWh: g})
+	}
+
+	tests := []struct {
+		name      string
+		weight    float64
+		wantStart time.Time
+	}{
+		{name: "cost only", weight: 0, wantStart: base},
+		{name: "carbon only", weight: 1, wantStart: base.Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{EstKWh: 2, CarbonWeight: tt.weight, CarbonIntensity: intensity,
+				Interruptible: &InterruptibleLoad{PowerKW: 2}}
+			recs, err := BestWindows(slots, 60, Constraints{}, opts, 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !recs[0].Start.Equal(tt.wantStart) {
+				t.Errorf("charges from %s, want %s", recs[0].Start.Format("15:04"), tt.wantStart.Format("15:04"))
+			}
+		})
+	}
+}
@@ -21,29 +21,3 @@ func ApplyPracticalConstraints(appliance *Appliance, household *Household, const
 	// For SMART appliances: can run anytime (user loads it, automation starts it)
 	// No additional constraints needed - keep existing allowed windows
 }
-
-// ShouldShowRecommendation determines if we should show a recommendation today
-// based on usage frequency
-func ShouldShowRecommendation(appliance *Appliance, lastRunDate string, currentDate string) bool {
-	switch appliance.UsageFrequency {
-	case FrequencyDaily:
-		return true // Show every day
-
-	case Frequency3xWeek:
-		// TODO: Track last 3 runs in database
-		// For now, show on Mon/Wed/Fri
-		// This is a simplified implementation
-		return true // Placeholder
-
-	case FrequencyWeekly:
-		// TODO: Track last run
-		// For now, show on Mondays
-		return true // Placeholder
-
-	case FrequencyOnDemand:
-		return false // Never show automatically
-
-	default:
-		return false
-	}
-}
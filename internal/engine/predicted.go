@@ -0,0 +1,37 @@
+package engine
+
+import "fmt"
+
+// band returns the range a slot's price may fall in: the confidence band of a
+// predicted price, or the published price at both ends
+func (s PriceSlot) band() (low, high float64) {
+	if !s.Predicted {
+		return s.PencePerKWh, s.PencePerKWh
+	}
+	return s.LowPence, s.HighPence
+}
+
+// costRange returns the range a recommendation's cost may fall in, which is
+// just its cost unless it was priced on predictions
+func (r Recommendation) costRange() (low, high float64) {
+	if !r.Predicted {
+		return r.CostGBP, r.CostGBP
+	}
+	return r.CostLowGBP, r.CostHighGBP
+}
+
+// planningCost is what an option is judged on when choosing between days: a
+// predicted day has to beat the others even at the top of its band, so a
+// published price isn't given up for a guess
+func (o RecommendationOption) planningCost() float64 {
+	if o.Predicted {
+		return o.CostHighGBP
+	}
+	return o.TotalCostGBP
+}
+
+// predictedReason notes that a run is priced on predictions, and how far its
+// cost could be out
+func predictedReason(reason string, lowGBP, highGBP float64) string {
+	return fmt.Sprintf("%s; predicted prices, £%.2f-£%.2f", reason, lowGBP, highGBP)
+}
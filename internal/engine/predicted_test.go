@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// daySlots returns a local day's 48 slots at one price, predicted with a band
+// when low and high differ
+func daySlots(day time.Time, pence, low, high float64) []PriceSlot {
+	slots := []PriceSlot{}
+	for start := day; start.Before(day.AddDate(0, 0, 1)); start = start.Add(30 * time.Minute) {
+		slot := PriceSlot{Start: start, End: start.Add(30 * time.Minute), PencePerKWh: pence}
+		if low != high {
+			slot.Predicted, slot.LowPence, slot.HighPence = true, low, high
+		}
+		slots = append(slots, slot)
+	}
+	return slots
+}
+
+func TestCoupledRecommendationPredictedDays(t *testing.T) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	washer := &Appliance{Name: "Washer", Class: ClassCoupled, CycleMinutes: 60, EstKWh: 1, CanWaitDays: 2}
+	dryer := &Appliance{Name: "Dryer", CycleMinutes: 60, EstKWh: 2}
+
+	tests := []struct {
+		name      string
+		low, high float64
+		wantDay   string
+	}{
+		// Tomorrow looks cheaper, but could well be dearer than today's known price
+		{name: "wide band", low: 5, high: 40, wantDay: "Today"},
+		// Even at the top of its band tomorrow beats today
+		{name: "narrow band", low: 14, high: 18, wantDay: "Tomorrow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pricesByDay := map[string][]PriceSlot{
+				today.Format("2006-01-02"):                  daySlots(today, 20, 20, 20),
+				today.AddDate(0, 0, 1).Format("2006-01-02"): daySlots(today.AddDate(0, 0, 1), 15, tt.low, tt.high),
+			}
+			rec, err := GenerateSmartRecommendations(washer, dryer, pricesByDay, nil, &Household{}, Constraints{}, Options{EstKWh: 1})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(rec.Options) != 2 {
+				t.Fatalf("got %d options, want today and tomorrow", len(rec.Options))
+			}
+
+			predicted := rec.Options[1]
+			if !predicted.Predicted || rec.Options[0].Predicted {
+				t.Errorf("only tomorrow should be marked predicted")
+			}
+			// Washer 1 kWh plus dryer 2 kWh
+			if math.Abs(predicted.TotalCostGBP-0.45) > 1e-9 || math.Abs(predicted.CostLowGBP-3*tt.low/100) > 1e-9 ||
+				math.Abs(predicted.CostHighGBP-3*tt.high/100) > 1e-9 {
+				t.Errorf("tomorrow costs £%.2f (£%.2f-£%.2f), want £0.45 within the band",
+					predicted.TotalCostGBP, predicted.CostLowGBP, predicted.CostHighGBP)
+			}
+			if !strings.Contains(predicted.PrimarySlot.Reason, "predicted prices") {
+				t.Errorf("reason %q doesn't say the prices are predicted", predicted.PrimarySlot.Reason)
+			}
+
+			if got := rec.Options[rec.BestOptionIndex].Day; got != tt.wantDay {
+				t.Errorf("best option is %s, want %s", got, tt.wantDay)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProfileSegment is one step of an appliance power profile
+type ProfileSegment struct {
+	Minutes int     // Length of the segment
+	KW      float64 // Average draw during the segment
+}
+
+var ErrInvalidProfile = errors.New("invalid power profile")
+
+// profileEnergy returns the energy a profile draws between minute from and
+// minute to of a run, measured from the start of the cycle
+func profileEnergy(profile []ProfileSegment, from, to float64) float64 {
+	kwh := 0.0
+	offset := 0.0
+	for _, seg := range profile {
+		segEnd := offset + float64(seg.Minutes)
+		overlap := math.Min(segEnd, to) - math.Max(offset, from)
+		if overlap > 0 {
+			kwh += seg.KW * overlap / 60.0
+		}
+		offset = segEnd
+		if offset >= to {
+			break
+		}
+	}
+	return kwh
+}
+
+// ProfileKWh returns the total energy of a power profile
+func ProfileKWh(profile []ProfileSegment) float64 {
+	return profileEnergy(profile, 0, math.Inf(1))
+}
+
+// ProfileMinutes returns the total duration of a power profile
+func ProfileMinutes(profile []ProfileSegment) int {
+	total := 0
+	for _, seg := range profile {
+		total += seg.Minutes
+	}
+	return total
+}
+
+// profilePeakKW returns the highest draw in a power profile
+func profilePeakKW(profile []ProfileSegment) float64 {
+	peak := 0.0
+	for _, seg := range profile {
+		peak = math.Max(peak, seg.KW)
+	}
+	return peak
+}
+
+// runEnergy returns the energy a run draws between minute from and minute to of
+// its cycle. With a power profile the profile is used directly; otherwise
+// estKWh is spread evenly over runMinutes.
+func runEnergy(profile []ProfileSegment, estKWh float64, runMinutes int, from, to float64) float64 {
+	if len(profile) > 0 {
+		return profileEnergy(profile, from, to)
+	}
+	if runMinutes <= 0 {
+		return 0
+	}
+	overlap := math.Min(to, float64(runMinutes)) - math.Max(from, 0)
+	if overlap <= 0 {
+		return 0
+	}
+	return estKWh * overlap / float64(runMinutes)
+}
+
+// profileReading is one smart-plug sample
+type profileReading struct {
+	at time.Time
+	kw float64
+}
+
+// ParsePowerProfileCSV builds a power profile from smart-plug readings. The CSV
+// needs a header row with a timestamp column ("timestamp" or "time") and a power
+// column ("power_w"/"watts" in watts, or "power_kw"/"kw" in kilowatts). Each
+// reading is held until the next one, and the result is averaged into segments
+// of segmentMinutes.
+func ParsePowerProfileCSV(r io.Reader, segmentMinutes int) ([]ProfileSegment, error) {
+	if segmentMinutes <= 0 {
+		return nil, fmt.Errorf("%w: segment length must be positive", ErrInvalidProfile)
+	}
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	timeCol, powerCol := -1, -1
+	scale := 1.0
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "timestamp", "time":
+			timeCol = i
+		case "power_w", "watts", "power":
+			powerCol, scale = i, 0.001
+		case "power_kw", "kw":
+			powerCol, scale = i, 1.0
+		}
+	}
+	if timeCol < 0 || powerCol < 0 {
+		return nil, fmt.Errorf("%w: need timestamp and power columns", ErrInvalidProfile)
+	}
+
+	readings := []profileReading{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+
+		at, err := parseReadingTime(record[timeCol])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+		}
+		power, err := strconv.ParseFloat(strings.TrimSpace(record[powerCol]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad power value %q", ErrInvalidProfile, record[powerCol])
+		}
+		readings = append(readings, profileReading{at: at, kw: power * scale})
+	}
+
+	if len(readings) < 2 {
+		return nil, fmt.Errorf("%w: need at least two readings", ErrInvalidProfile)
+	}
+
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].at.Before(readings[j].at)
+	})
+
+	// Integrate energy into fixed-length segments, holding each reading until the next
+	start := readings[0].at
+	totalMinutes := readings[len(readings)-1].at.Sub(start).Minutes()
+	segments := int(math.Ceil(totalMinutes / float64(segmentMinutes)))
+	if segments == 0 {
+		return nil, fmt.Errorf("%w: readings must span some time", ErrInvalidProfile)
+	}
+	energy := make([]float64, segments)
+
+	for i := 0; i+1 < len(readings); i++ {
+		from := readings[i].at.Sub(start).Minutes()
+		to := readings[i+1].at.Sub(start).Minutes()
+		for k := int(from) / segmentMinutes; k < segments; k++ {
+			segStart := float64(k * segmentMinutes)
+			segEnd := segStart + float64(segmentMinutes)
+			overlap := math.Min(segEnd, to) - math.Max(segStart, from)
+			if overlap <= 0 {
+				if segStart >= to {
+					break
+				}
+				continue
+			}
+			energy[k] += readings[i].kw * overlap / 60.0
+		}
+	}
+
+	profile := make([]ProfileSegment, segments)
+	for k := range energy {
+		profile[k] = ProfileSegment{
+			Minutes: segmentMinutes,
+			KW:      energy[k] / (float64(segmentMinutes) / 60.0),
+		}
+	}
+
+	return profile, nil
+}
+
+// parseReadingTime accepts RFC3339 timestamps or a plain "2006-01-02 15:04:05"
+func parseReadingTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad timestamp %q", s)
+}
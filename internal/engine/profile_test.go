@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePowerProfileCSV(t *testing.T) {
+	// 2 kW heating for 10 minutes, then 0.2 kW for 20 minutes
+	csvData := `timestamp,power_w
+2024-12-01T10:00:00Z,2000
+2024-12-01T10:05:00Z,2000
+2024-12-01T10:10:00Z,200
+2024-12-01T10:20:00Z,200
+2024-12-01T10:30:00Z,0
+`
+	profile, err := ParsePowerProfileCSV(strings.NewReader(csvData), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(profile) != 3 {
+		t.Fatalf("got %d segments, want 3", len(profile))
+	}
+	if math.Abs(profile[0].KW-2.0) > 1e-9 || math.Abs(profile[1].KW-0.2) > 1e-9 {
+		t.Errorf("unexpected profile: %+v", profile)
+	}
+	if got := ProfileKWh(profile); math.Abs(got-(2.0/6+0.2/3)) > 1e-9 {
+		t.Errorf("ProfileKWh() = %.3f", got)
+	}
+
+	if _, err := ParsePowerProfileCSV(strings.NewReader("when,watts\n"), 5); err == nil {
+		t.Errorf("expected error for missing timestamp column")
+	}
+	// Readings all taken at once describe no cycle at all
+	sameTime := "timestamp,power_w\n2024-12-01T10:00:00Z,2000\n2024-12-01T10:00:00Z,1500\n"
+	if _, err := ParsePowerProfileCSV(strings.NewReader(sameTime), 5); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("got %v for readings sharing one timestamp, want ErrInvalidProfile", err)
+	}
+}
+
+func TestBestWindowsPowerProfile(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{30, 10, 10, 30})
+
+	// Front-loaded 60 minute cycle: 1.0 kWh in the first half hour, 0.1 kWh in the second
+	profile := []ProfileSegment{{Minutes: 30, KW: 2.0}, {Minutes: 30, KW: 0.2}}
+	opts := Options{EstKWh: 1.1, PowerProfile: profile}
+
+	recs, err := BestWindows(slots, 60, Constraints{}, opts, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Starting at 00:30 puts the heavy half hour in a 10p slot
+	if !recs[0].Start.Equal(base.Add(30 * time.Minute)) {
+		t.Errorf("top start = %s, want 00:30", recs[0].Start.Format("15:04"))
+	}
+	if want := (1.0*10 + 0.1*10) / 100; math.Abs(recs[0].CostGBP-want) > 1e-9 {
+		t.Errorf("cost = £%.4f, want £%.4f", recs[0].CostGBP, want)
+	}
+
+	// 01:00 start is costed by profile, not evenly: heavy part at 10p, tail at 30p
+	for _, r := range recs {
+		if r.Start.Equal(base.Add(60 * time.Minute)) {
+			if want := (1.0*10 + 0.1*30) / 100; math.Abs(r.CostGBP-want) > 1e-9 {
+				t.Errorf("01:00 cost = £%.4f, want £%.4f", r.CostGBP, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Baseline is what a savings report compares the real cost of runs against
+type Baseline string
+
+const (
+	BaselineFlat      Baseline = "flat"      // A fixed unit rate tariff
+	BaselinePriceCap  Baseline = "price_cap" // The Ofgem price cap unit rate
+	BaselineImmediate Baseline = "immediate" // Agile, but started as soon as loaded
+)
+
+// ReportPeriod is how a savings report groups runs
+type ReportPeriod string
+
+const (
+	PeriodWeek  ReportPeriod = "week"  // Monday to Sunday
+	PeriodMonth ReportPeriod = "month" // Calendar month
+)
+
+// SavingsRow totals the runs of one appliance in one period
+type SavingsRow struct {
+	ApplianceID   string
+	ApplianceName string
+	PeriodStart   time.Time
+	Runs          int
+	KWh           float64
+	CostGBP       float64 // What the runs actually cost
+	BaselineGBP   float64 // What they would have cost on the baseline
+	SavingsGBP    float64 // BaselineGBP less CostGBP
+}
+
+// SavingsReport compares what logged runs cost with a baseline
+type SavingsReport struct {
+	Baseline  Baseline
+	RatePence float64 // Unit rate for flat and price-cap baselines
+	Period    ReportPeriod
+	Rows      []SavingsRow // By period, then appliance name
+	Total     SavingsRow
+	Skipped   int // Runs left out because they are unfinished or uncosted
+}
+
+// BaselineRate returns the household's unit rate for a flat or price-cap
+// baseline, or 0 for baselines that don't use one
+func BaselineRate(household *Household, baseline Baseline) float64 {
+	switch baseline {
+	case BaselineFlat:
+		return household.FlatRatePence
+	case BaselinePriceCap:
+		return household.PriceCapPence
+	default:
+		return 0
+	}
+}
+
+// PeriodStart returns local midnight at the start of the week or month t falls in
+func PeriodStart(t time.Time, period ReportPeriod) time.Time {
+	if period == PeriodMonth {
+		day := localDate(t)
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return WeekStart(t)
+}
+
+// BuildSavingsReport totals costed runs per appliance per period and compares
+// them with the baseline. names maps appliance IDs to display names. Flat and
+// price-cap baselines price each run's energy at ratePence; the immediate
+// baseline uses the cost worked out for each run when it was priced.
+func BuildSavingsReport(runs []*Run, names map[string]string, baseline Baseline, ratePence float64, period ReportPeriod) (*SavingsReport, error) {
+	switch baseline {
+	case BaselineFlat, BaselinePriceCap:
+		if ratePence <= 0 {
+			return nil, fmt.Errorf("%w: %s baseline needs a unit rate", ErrInvalidInput, baseline)
+		}
+	case BaselineImmediate:
+		ratePence = 0
+	default:
+		return nil, fmt.Errorf("%w: unknown baseline %q", ErrInvalidInput, baseline)
+	}
+	if period != PeriodWeek && period != PeriodMonth {
+		return nil, fmt.Errorf("%w: unknown period %q", ErrInvalidInput, period)
+	}
+
+	report := &SavingsReport{Baseline: baseline, RatePence: ratePence, Period: period}
+
+	type rowKey struct {
+		applianceID string
+		period      time.Time
+	}
+	rows := make(map[rowKey]*SavingsRow)
+	for _, run := range runs {
+		if !run.Finished() || !run.Costed {
+			report.Skipped++
+			continue
+		}
+
+		baselineGBP := run.ImmediateCostGBP
+		if baseline != BaselineImmediate {
+			baselineGBP = run.KWh * ratePence / 100.0
+		}
+
+		key := rowKey{run.ApplianceID, PeriodStart(run.Start, period)}
+		row, ok := rows[key]
+		if !ok {
+			name := names[run.ApplianceID]
+			if name == "" {
+				name = run.ApplianceID
+			}
+			row = &SavingsRow{ApplianceID: run.ApplianceID, ApplianceName: name, PeriodStart: key.period}
+			rows[key] = row
+		}
+		for _, r := range []*SavingsRow{row, &report.Total} {
+			r.Runs++
+			r.KWh += run.KWh
+			r.CostGBP += run.CostGBP
+			r.BaselineGBP += baselineGBP
+			r.SavingsGBP += baselineGBP - run.CostGBP
+		}
+	}
+
+	report.Rows = make([]SavingsRow, 0, len(rows))
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if !a.PeriodStart.Equal(b.PeriodStart) {
+			return a.PeriodStart.Before(b.PeriodStart)
+		}
+		return a.ApplianceName < b.ApplianceName
+	})
+	report.Total.ApplianceName = "Total"
+
+	return report, nil
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBuildSavingsReport(t *testing.T) {
+	// Monday 1 and Tuesday 2 December, then Monday 8 December 2025
+	mon := time.Date(2025, 12, 1, 2, 0, 0, 0, time.Local)
+	run := func(applianceID string, start time.Time, kwh, cost, immediate float64) *Run {
+		end := start.Add(time.Hour)
+		return &Run{ApplianceID: applianceID, Start: start, End: &end, KWh: kwh,
+			CostGBP: cost, ImmediateCostGBP: immediate, Costed: true}
+	}
+	unfinished := &Run{ApplianceID: "wm", Start: mon}
+	runs := []*Run{
+		run("wm", mon, 1, 0.10, 0.30),
+		run("wm", mon.AddDate(0, 0, 1), 2, 0.20, 0.50),
+		run("dw", mon, 1, 0.05, 0.25),
+		run("wm", mon.AddDate(0, 0, 7), 1, 0.10, 0.10),
+		unfinished,
+	}
+	names := map[string]string{"wm": "Washer", "dw": "Dishwasher"}
+
+	tests := []struct {
+		name        string
+		baseline    Baseline
+		rate        float64
+		period      ReportPeriod
+		wantRows    int
+		wantSavings float64
+	}{
+		{name: "price cap by week", baseline: BaselinePriceCap, rate: 25, period: PeriodWeek, wantRows: 3, wantSavings: 5*0.25 - 0.45},
+		{name: "flat by month", baseline: BaselineFlat, rate: 20, period: PeriodMonth, wantRows: 2, wantSavings: 5*0.20 - 0.45},
+		{name: "run immediately", baseline: BaselineImmediate, period: PeriodWeek, wantRows: 3, wantSavings: 1.15 - 0.45},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := BuildSavingsReport(runs, names, tt.baseline, tt.rate, tt.period)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(report.Rows) != tt.wantRows {
+				t.Errorf("got %d rows, want %d", len(report.Rows), tt.wantRows)
+			}
+			if report.Skipped != 1 {
+				t.Errorf("skipped %d runs, want 1", report.Skipped)
+			}
+			if report.Total.Runs != 4 {
+				t.Errorf("total of %d runs, want 4", report.Total.Runs)
+			}
+			if math.Abs(report.Total.SavingsGBP-tt.wantSavings) > 1e-9 {
+				t.Errorf("saved £%.2f, want £%.2f", report.Total.SavingsGBP, tt.wantSavings)
+			}
+			if first := report.Rows[0]; first.ApplianceName != "Dishwasher" || !first.PeriodStart.Equal(PeriodStart(mon, tt.period)) {
+				t.Errorf("first row %s from %s, want Dishwasher from the first period", first.ApplianceName, first.PeriodStart)
+			}
+		})
+	}
+
+	if _, err := BuildSavingsReport(runs, names, BaselineFlat, 0, PeriodWeek); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("flat baseline without a rate: got %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrMissingPrices is returned when the prices to hand don't cover a run
+var ErrMissingPrices = errors.New("no prices cover the run")
+
+// Run is one time an appliance actually ran, as logged by the user
+type Run struct {
+	ID                 int64
+	ApplianceID        string
+	LoadedAt           *time.Time // When it was ready to go, if earlier than Start
+	Start              time.Time
+	End                *time.Time // nil while the run is in progress
+	KWh                float64
+	Measured           bool       // KWh read from a meter rather than estimated
+	RecommendedStart   *time.Time // Start of the recommendation followed, if any
+	RecommendedCostGBP float64    // Same energy over the recommended window
+	CostGBP            float64    // At the prices in force while it ran
+	ImmediateCostGBP   float64    // Had it started as soon as it was loaded
+	Costed             bool       // Whether prices were found to cost it
+	MeteredKWh         float64    // Smart-meter import while it ran, less the baseline; see MatchMeteredRuns
+	Metered            bool       // Whether meter readings covered the run
+}
+
+// Finished reports whether the run has ended
+func (r Run) Finished() bool {
+	return r.End != nil
+}
+
+// Duration returns how long the run lasted, or 0 while it is in progress
+func (r Run) Duration() time.Duration {
+	if r.End == nil {
+		return 0
+	}
+	return r.End.Sub(r.Start)
+}
+
+// RecommendedEnd returns when the recommended window would have finished for
+// a run of the same length
+func (r Run) RecommendedEnd() *time.Time {
+	if r.RecommendedStart == nil {
+		return nil
+	}
+	end := r.RecommendedStart.Add(r.Duration())
+	return &end
+}
+
+// PriceSpan returns the stretch of time prices are needed for to cost a
+// finished run, the recommendation it followed, and starting it straight away
+func (r Run) PriceSpan() (from, to time.Time) {
+	from, to = r.Start, r.Start.Add(r.Duration())
+	if end := r.RecommendedEnd(); end != nil {
+		from, to = minTime(from, *r.RecommendedStart), maxTime(to, *end)
+	}
+	if r.LoadedAt != nil {
+		from = minTime(from, *r.LoadedAt)
+	}
+	return from, to
+}
+
+// VersusAdviceGBP returns how much more the run cost than the recommended
+// window would have; negative when it did better than the advice
+func (r Run) VersusAdviceGBP() float64 {
+	if r.RecommendedStart == nil {
+		return 0
+	}
+	return r.CostGBP - r.RecommendedCostGBP
+}
+
+// RunCost prices kwh drawn evenly between start and end at the slot prices
+func RunCost(slots []PriceSlot, start, end time.Time, kwh float64) (float64, error) {
+	if !end.After(start) || kwh < 0 {
+		return 0, ErrInvalidInput
+	}
+
+	perMinute := kwh / end.Sub(start).Minutes()
+	covered := 0.0
+	pence := 0.0
+	for _, slot := range slots {
+		from, to := maxTime(slot.Start, start), minTime(slot.End, end)
+		if !to.After(from) {
+			continue
+		}
+		minutes := to.Sub(from).Minutes()
+		covered += minutes
+		pence += slot.PencePerKWh * perMinute * minutes
+	}
+	if covered < end.Sub(start).Minutes()-1e-6 {
+		return 0, fmt.Errorf("%w: %s to %s", ErrMissingPrices,
+			start.Local().Format("Mon 15:04"), end.Local().Format("Mon 15:04"))
+	}
+
+	return pence / 100.0, nil
+}
+
+// PriceRun works out what a finished run cost, what it would have cost
+// started as soon as it was loaded, and, when it followed a recommendation,
+// what the same energy would have cost over that window
+func PriceRun(run *Run, slots []PriceSlot) error {
+	if !run.Finished() {
+		return ErrInvalidInput
+	}
+
+	cost, err := RunCost(slots, run.Start, *run.End, run.KWh)
+	if err != nil {
+		return err
+	}
+	run.CostGBP = cost
+
+	run.ImmediateCostGBP = cost
+	if run.LoadedAt != nil && run.LoadedAt.Before(run.Start) {
+		immediate, err := RunCost(slots, *run.LoadedAt, run.LoadedAt.Add(run.Duration()), run.KWh)
+		if err != nil {
+			return err
+		}
+		run.ImmediateCostGBP = immediate
+	}
+
+	if run.RecommendedStart != nil {
+		recCost, err := RunCost(slots, *run.RecommendedStart, *run.RecommendedEnd(), run.KWh)
+		if err != nil {
+			return err
+		}
+		run.RecommendedCostGBP = recCost
+	}
+	run.Costed = true
+	return nil
+}
+
+// MeteredKWh estimates what a finished run drew from the smart meter's
+// half-hourly readings: the whole-house import while it ran, pro rata for
+// half hours it only partly covered, less the household's baseline load over
+// the same time. It returns false when the readings don't cover the run.
+func MeteredKWh(run Run, consumption []ConsumptionSlot, baseLoadKW []float64) (float64, bool) {
+	if !run.Finished() || !run.End.After(run.Start) {
+		return 0, false
+	}
+
+	covered, kwh := 0.0, 0.0
+	for _, slot := range consumption {
+		from, to := maxTime(slot.Start, run.Start), minTime(slot.End, *run.End)
+		if !to.After(from) {
+			continue
+		}
+		share := to.Sub(from).Minutes() / slot.End.Sub(slot.Start).Minutes()
+		covered += to.Sub(from).Minutes()
+		kwh += slot.KWh*share - baseLoadAt(baseLoadKW, from)*to.Sub(from).Hours()
+	}
+	if covered < run.Duration().Minutes()-1e-6 {
+		return 0, false
+	}
+	return math.Max(0, kwh), true
+}
+
+// MatchMeteredRuns fills in MeteredKWh for the finished runs the meter
+// readings cover
+func MatchMeteredRuns(runs []*Run, consumption []ConsumptionSlot, baseLoadKW []float64) {
+	for _, run := range runs {
+		run.MeteredKWh, run.Metered = MeteredKWh(*run, consumption, baseLoadKW)
+	}
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPriceRun(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots := makeSlots(base, []float64{10, 20, 30, 40})
+	ptr := func(d time.Duration) *time.Time {
+		t := base.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name          string
+		run           Run
+		wantCost      float64
+		wantRecCost   float64
+		wantImmediate float64
+		wantErr       error
+	}{
+		{name: "whole slots", run: Run{Start: base, End: ptr(time.Hour), KWh: 2}, wantCost: 0.30, wantImmediate: 0.30},
+		{name: "partial slots", run: Run{Start: base.Add(15 * time.Minute), End: ptr(45 * time.Minute), KWh: 1}, wantCost: 0.15, wantImmediate: 0.15},
+		{name: "against recommendation", run: Run{Start: base.Add(time.Hour), End: ptr(2 * time.Hour), KWh: 2, RecommendedStart: ptr(0)}, wantCost: 0.70, wantRecCost: 0.30, wantImmediate: 0.70},
+		{name: "delayed after loading", run: Run{LoadedAt: ptr(0), Start: base.Add(time.Hour), End: ptr(2 * time.Hour), KWh: 2}, wantCost: 0.70, wantImmediate: 0.30},
+		{name: "beyond cached prices", run: Run{Start: base.Add(90 * time.Minute), End: ptr(3 * time.Hour), KWh: 1}, wantErr: ErrMissingPrices},
+		{name: "still running", run: Run{Start: base}, wantErr: ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := tt.run
+			err := PriceRun(&run, slots)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(run.CostGBP-tt.wantCost) > 1e-9 {
+				t.Errorf("cost £%.4f, want £%.4f", run.CostGBP, tt.wantCost)
+			}
+			if math.Abs(run.RecommendedCostGBP-tt.wantRecCost) > 1e-9 {
+				t.Errorf("recommended cost £%.4f, want £%.4f", run.RecommendedCostGBP, tt.wantRecCost)
+			}
+			if math.Abs(run.ImmediateCostGBP-tt.wantImmediate) > 1e-9 {
+				t.Errorf("immediate cost £%.4f, want £%.4f", run.ImmediateCostGBP, tt.wantImmediate)
+			}
+			if !run.Costed {
+				t.Errorf("run should be marked costed")
+			}
+			if got, want := run.VersusAdviceGBP(), tt.wantCost-tt.wantRecCost; run.RecommendedStart != nil && math.Abs(got-want) > 1e-9 {
+				t.Errorf("versus advice £%.4f, want £%.4f", got, want)
+			}
+		})
+	}
+}
+
+func TestMatchMeteredRuns(t *testing.T) {
+	base := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	consumption := []ConsumptionSlot{
+		{Start: base, End: base.Add(30 * time.Minute), KWh: 1.2},
+		{Start: base.Add(30 * time.Minute), End: base.Add(time.Hour), KWh: 1.0},
+		{Start: base.Add(time.Hour), End: base.Add(90 * time.Minute), KWh: 0.1},
+	}
+	end := func(d time.Duration) *time.Time {
+		t := base.Add(d)
+		return &t
+	}
+
+	runs := []*Run{
+		// Half of the first reading and all of the second, less 45 min at 0.2 kW
+		{Start: base.Add(15 * time.Minute), End: end(time.Hour)},
+		// Runs on past the last reading
+		{Start: base.Add(time.Hour), End: end(2 * time.Hour)},
+		// Still running
+		{Start: base},
+		// Draws no more than the baseline
+		{Start: base.Add(time.Hour), End: end(90 * time.Minute)},
+	}
+	MatchMeteredRuns(runs, consumption, []float64{0.2})
+
+	if !runs[0].Metered || math.Abs(runs[0].MeteredKWh-1.45) > 1e-9 {
+		t.Errorf("metered %.3f kWh (%v), want 1.450", runs[0].MeteredKWh, runs[0].Metered)
+	}
+	if runs[1].Metered || runs[2].Metered {
+		t.Errorf("runs without full readings should not be metered")
+	}
+	if !runs[3].Metered || runs[3].MeteredKWh != 0 {
+		t.Errorf("metered %.3f kWh, want 0 when below the baseline", runs[3].MeteredKWh)
+	}
+}
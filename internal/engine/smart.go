@@ -113,19 +113,20 @@ func generateCoupledRecommendation(
 			dryerEnd := dryerStart.Add(time.Duration(dryer.CycleMinutes) * time.Minute)
 
 			// Find price for dryer slot
-			dryerCost := estimateCost(prices, dryerStart, dryerEnd, dryer.EstKWh)
+			dryerSlot := estimateRun(prices, dryerStart, dryerEnd, dryer.EstKWh, dryer.PowerProfile)
 
-			totalCost := washerSlot.CostGBP + dryerCost
+			totalCost := washerSlot.CostGBP + dryerSlot.CostGBP
 
 			option := RecommendationOption{
 				Day:          dayName,
 				Date:         checkDate,
 				PrimarySlot:  washerSlot,
-				CoupledSlot:  &Recommendation{Start: dryerStart, End: dryerEnd, CostGBP: dryerCost},
+				CoupledSlot:  &dryerSlot,
 				TotalCostGBP: totalCost,
 				Weather:      weather,
 				UsesNaturalDry: false,
 			}
+			setCostBand(&option, washerSlot, dryerSlot)
 
 			if len(options) > 0 {
 				option.SavingsVsToday = options[0].TotalCostGBP - totalCost
@@ -134,6 +135,9 @@ func generateCoupledRecommendation(
 			option.Recommendation = fmt.Sprintf("Start wash at %s, finishes at %s. Then tumble dry until %s (£%.2f total)",
 				washerSlot.Start.Local().Format("15:04"), washerSlot.End.Local().Format("15:04"),
 				dryerEnd.Local().Format("15:04"), totalCost)
+			if option.Predicted {
+				option.Recommendation += fmt.Sprintf(". Prices are predicted: £%.2f-£%.2f", option.CostLowGBP, option.CostHighGBP)
+			}
 
 			options = append(options, option)
 		}
@@ -149,6 +153,7 @@ func generateCoupledRecommendation(
 				Weather:        weather,
 				UsesNaturalDry: true,
 			}
+			setCostBand(&option, washerSlot)
 
 			if len(options) > 0 {
 				option.SavingsVsToday = options[0].TotalCostGBP - washerSlot.CostGBP
@@ -156,6 +161,9 @@ func generateCoupledRecommendation(
 
 			option.Recommendation = fmt.Sprintf("Start wash at %s, finishes at %s. Then hang outside to dry in sunshine (£%.2f, save £%.2f!)",
 				washerSlot.Start.Local().Format("15:04"), washerSlot.End.Local().Format("15:04"), washerSlot.CostGBP, option.SavingsVsToday)
+			if option.Predicted {
+				option.Recommendation += fmt.Sprintf(" Prices are predicted: £%.2f-£%.2f", option.CostLowGBP, option.CostHighGBP)
+			}
 
 			options = append(options, option)
 		}
@@ -165,10 +173,10 @@ func generateCoupledRecommendation(
 		return nil, fmt.Errorf("no feasible options found")
 	}
 
-	// Find best option (lowest cost)
+	// Find best option (lowest cost, predicted days at the top of their band)
 	bestIdx := 0
 	for i := 1; i < len(options); i++ {
-		if options[i].TotalCostGBP < options[bestIdx].TotalCostGBP {
+		if options[i].planningCost() < options[bestIdx].planningCost() {
 			bestIdx = i
 		}
 	}
@@ -191,28 +199,42 @@ func getDayName(dayOffset int) string {
 	}
 }
 
-func estimateCost(prices []PriceSlot, start, end time.Time, kwh float64) float64 {
-	totalCostPence := 0.0
-	slots := 0
+// setCostBand marks an option as predicted when any of its runs are, with the
+// band its total cost falls in
+func setCostBand(option *RecommendationOption, runs ...Recommendation) {
+	for _, run := range runs {
+		low, high := run.costRange()
+		option.Predicted = option.Predicted || run.Predicted
+		option.CostLowGBP += low
+		option.CostHighGBP += high
+	}
+}
+
+// estimateRun prices a run over [start, end) that follows another, so has no
+// choice of start
+func estimateRun(prices []PriceSlot, start, end time.Time, kwh float64, profile []ProfileSegment) Recommendation {
+	rec := Recommendation{Start: start, End: end}
 
-	// Count matching slots
+	// Slots the run overlaps, charged only for the minutes actually used
+	window := []PriceSlot{}
 	for _, p := range prices {
-		if (p.Start.Equal(start) || p.Start.After(start)) && p.Start.Before(end) {
-			slots++
+		if p.Start.Before(end) && p.End.After(start) {
+			window = append(window, p)
 		}
 	}
 
-	if slots == 0 {
-		return 0
+	if len(window) == 0 {
+		return rec
 	}
 
-	// Split energy evenly across slots and calculate cost for each
-	kwhPerSlot := kwh / float64(slots)
-	for _, p := range prices {
-		if (p.Start.Equal(start) || p.Start.After(start)) && p.Start.Before(end) {
-			totalCostPence += p.PencePerKWh * kwhPerSlot
-		}
-	}
+	runMinutes := int(end.Sub(start).Minutes())
+	opts := Options{EstKWh: kwh, PowerProfile: profile}
+	wc := costWindow(window, start, end, runMinutes, opts, newCarbonLookup(nil), newPVLookup(nil, nil))
 
-	return totalCostPence / 100.0 // Convert pence to pounds
+	// Convert pence to pounds
+	rec.CostGBP = wc.gridPence / 100.0
+	rec.Predicted = wc.predicted
+	rec.CostLowGBP = wc.gridLowPence / 100.0
+	rec.CostHighGBP = wc.gridHighPence / 100.0
+	return rec
 }
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// Typical household background draw (fridge, standby, router) that solar
+// generation covers before any surplus is available to appliances
+const pvBaseLoadKW = 0.3
+
+// pvLookup answers "how much surplus solar energy is there at time t" and
+// "what would it earn if exported instead"
+type pvLookup struct {
+	series []PVSlot
+	export []PriceSlot
+}
+
+func newPVLookup(series []PVSlot, export []PriceSlot) pvLookup {
+	return pvLookup{series: series, export: export}
+}
+
+// surplusKWh returns generation left over after the base load during the
+// 30-minute slot starting at t
+func (l pvLookup) surplusKWh(t time.Time) float64 {
+	for _, p := range l.series {
+		if !t.Before(p.Start) && t.Before(p.End) {
+			return math.Max(0, p.GenerationKW-pvBaseLoadKW) * 0.5
+		}
+	}
+	return 0
+}
+
+// exportPence returns what a kWh exported during the slot starting at t would
+// earn, and false when no export price covers t
+func (l pvLookup) exportPence(t time.Time) (float64, bool) {
+	for _, p := range l.export {
+		if !t.Before(p.Start) && t.Before(p.End) {
+			return p.PencePerKWh, true
+		}
+	}
+	return 0, false
+}
+
+// solarCost returns what using kwh of surplus solar in a slot counts for in
+// the score, and the export income given up by using it. With an export price
+// the solar is worth exactly what it would have sold for; without one it is
+// valued at the import price discounted by the PV weight.
+func (l pvLookup) solarCost(slot PriceSlot, kwh, pvWeight float64) (score, lostExport float64) {
+	if kwh <= 0 {
+		return 0, 0
+	}
+	if price, ok := l.exportPence(slot.Start); ok {
+		return price * kwh, price * kwh
+	}
+	return slot.PencePerKWh * kwh * (1 - pvWeight), 0
+}
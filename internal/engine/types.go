@@ -4,10 +4,35 @@ import "time"
 
 // PriceSlot represents a 30-minute electricity pricing period
 type PriceSlot struct {
+	Start       time.Time
+	End         time.Time
+	PencePerKWh float64
+	IncludesVAT bool
+	Predicted   bool    // Forecast from past prices, not yet published; PencePerKWh is the central estimate
+	LowPence    float64 // Confidence band on a predicted price
+	HighPence   float64
+}
+
+// CarbonSlot represents the grid carbon intensity for a 30-minute period
+type CarbonSlot struct {
+	Start       time.Time
+	End         time.Time
+	GramsPerKWh float64 // gCO2/kWh, forecast where actuals aren't yet known
+}
+
+// PVSlot represents forecast solar generation for a 30-minute period
+type PVSlot struct {
 	Start        time.Time
 	End          time.Time
-	PencePerKWh  float64
-	IncludesVAT  bool
+	GenerationKW float64 // Average array output over the period
+}
+
+// ConsumptionSlot is the whole-house import a smart meter recorded for a
+// 30-minute period
+type ConsumptionSlot struct {
+	Start time.Time
+	End   time.Time
+	KWh   float64
 }
 
 // WeatherSlot represents weather conditions at a point in time
@@ -45,22 +70,53 @@ type Constraints struct {
 	StartBy       *time.Time
 	PriceCapPence *float64
 	NoiseLevel    int // 1-5, affects quiet hours filtering
+
+	ToleranceMinutes int // How far FinishBy/StartBy may be overrun, at a penalty
 }
 
 // Options contains parameters for the optimization algorithm
 type Options struct {
-	EstKWh       float64 // Estimated energy consumption
-	CarbonWeight float64 // 0-1, weight for carbon optimization
-	PVWeight     float64 // 0-1, weight for PV self-consumption
+	EstKWh           float64            // Estimated energy consumption
+	CarbonWeight     float64            // 0-1, weight for carbon optimization
+	PVWeight         float64            // 0-1, weight for PV self-consumption
+	CarbonIntensity  []CarbonSlot       // Grid intensity forecast; optional
+	PVForecast       []PVSlot           // Solar generation forecast; optional
+	ExportPrices     []PriceSlot        // What exported solar earns; values used solar at this instead of PVWeight
+	PowerProfile     []ProfileSegment   // Draw over the cycle; overrides EstKWh when set
+	StartStepMinutes int                // Candidate start granularity; 0 = slot boundaries
+	Interruptible    *InterruptibleLoad // Set for loads that can pause and resume; nil = one contiguous run
+	LatePenaltyPence float64            // Score penalty per minute a deadline is overrun
+}
+
+// InterruptibleLoad describes a load that can be split across non-adjacent slots
+type InterruptibleLoad struct {
+	PowerKW          float64 // Draw while running; energy to deliver comes from Options.EstKWh
+	MinBlockMinutes  int     // Shortest run once started; 0 = any single slot
+	MaxInterruptions int     // Most pauses allowed; 0 = unlimited
+}
+
+// ChargeBlock is one uninterrupted stretch of an interruptible run
+type ChargeBlock struct {
+	Start time.Time
+	End   time.Time
+	KWh   float64
 }
 
 // Recommendation represents a suggested start window for an appliance
 type Recommendation struct {
-	Start   time.Time
-	End     time.Time
-	CostGBP float64
-	Reason  string
-	Score   float64
+	Start    time.Time
+	End      time.Time
+	CostGBP  float64
+	KgCO2    float64 // Estimated emissions; 0 when no carbon data was supplied
+	SolarKWh float64 // Energy expected to come from surplus solar generation
+	Reason   string
+	Score    float64
+	Blocks   []ChargeBlock // Stretches of an interruptible run; empty for contiguous runs
+
+	LostExportGBP float64 // Export income given up to use solar; included in CostGBP
+	Predicted     bool    // Priced at least partly on predicted prices
+	CostLowGBP    float64 // Confidence band on CostGBP when Predicted
+	CostHighGBP   float64
 }
 
 // SmartRecommendation represents an intelligent recommendation that considers weather, coupling, and multi-day options
@@ -72,15 +128,18 @@ type SmartRecommendation struct {
 
 // RecommendationOption represents one possible scheduling option
 type RecommendationOption struct {
-	Day              string               // "Today", "Tomorrow", "Wednesday"
-	Date             time.Time
-	PrimarySlot      Recommendation       // Main appliance time
-	CoupledSlot      *Recommendation      // Coupled appliance time (e.g., dryer after washer)
-	TotalCostGBP     float64              // Combined cost
-	Weather          *WeatherForecast     // Weather conditions for this day
-	UsesNaturalDry   bool                 // If true, skips tumble dryer and line-dries
-	SavingsVsToday   float64              // Money saved vs running today (negative if more expensive)
-	Recommendation   string               // Human-readable recommendation
+	Day            string // "Today", "Tomorrow", "Wednesday"
+	Date           time.Time
+	PrimarySlot    Recommendation   // Main appliance time
+	CoupledSlot    *Recommendation  // Coupled appliance time (e.g., dryer after washer)
+	TotalCostGBP   float64          // Combined cost
+	Weather        *WeatherForecast // Weather conditions for this day
+	UsesNaturalDry bool             // If true, skips tumble dryer and line-dries
+	SavingsVsToday float64          // Money saved vs running today (negative if more expensive)
+	Predicted      bool             // Priced at least partly on predicted prices
+	CostLowGBP     float64          // Confidence band on TotalCostGBP when Predicted
+	CostHighGBP    float64
+	Recommendation string // Human-readable recommendation
 }
 
 // ControlType defines how an appliance is controlled
@@ -108,6 +167,16 @@ const (
 	ClassStandalone       ApplianceClass = "standalone"        // Runs independently (dishwasher, EV)
 	ClassCoupled          ApplianceClass = "coupled"           // Requires another appliance after (washing machine → dryer)
 	ClassWeatherDependent ApplianceClass = "weather_dependent" // Can be replaced by natural conditions (dryer → sun)
+	ClassEV               ApplianceClass = "ev"                // Electric vehicle charged to a state-of-charge target
+)
+
+// TariffType is how the household's electricity is priced
+type TariffType string
+
+const (
+	TariffAgile TariffType = "agile" // Octopus Agile, half-hourly prices published a day ahead
+	TariffFlat  TariffType = "flat"  // One unit rate all day (Household.FlatRatePence)
+	TariffTOU   TariffType = "tou"   // Fixed time-of-use bands (Household.TariffDefinition)
 )
 
 // Appliance represents a household appliance to be scheduled
@@ -125,23 +194,55 @@ type Appliance struct {
 	Priority            int
 	EstKWh              float64
 	Enabled             bool
-	ControlType         ControlType    // manual or smart
-	UsageFrequency      UsageFrequency // how often to run
-	Class               ApplianceClass // standalone, coupled, or weather_dependent
-	CoupledApplianceID  string         // ID of appliance that runs after this one
-	CanWaitDays         int            // How many days user can wait for better conditions (0 = must run today)
+	ControlType         ControlType      // manual or smart
+	UsageFrequency      UsageFrequency   // how often to run
+	Class               ApplianceClass   // standalone, coupled, or weather_dependent
+	CoupledApplianceID  string           // ID of appliance that runs after this one
+	CanWaitDays         int              // How many days user can wait for better conditions (0 = must run today)
+	RatedKW             float64          // Peak power draw in kW; 0 = derive from EstKWh and CycleMinutes
+	PowerProfile        []ProfileSegment // Measured draw over the cycle; empty = EstKWh spread evenly
+	Interruptible       bool             // Can pause and resume (EV charger, storage heater)
+	MinBlockMinutes     int              // Shortest run once started when interruptible
+	MaxInterruptions    int              // Most pauses allowed when interruptible; 0 = unlimited
+	BatteryKWh          float64          // EV battery capacity; RatedKW is the charger power
+	SoCPercent          float64          // EV state of charge now
+	TargetSoCPercent    float64          // EV state of charge wanted by ReadyBy
+	ReadyBy             string           // EV ready-by time of day, HH:mm
 }
 
 // Household represents household-level preferences and constraints
 type Household struct {
 	ID                string
 	Name              string
-	Region            string // Octopus region code (A-P)
-	Latitude          float64 // For weather forecasts
-	Longitude         float64 // For weather forecasts
+	Region            string     // Octopus region code (A-P)
+	Tariff            TariffType // How electricity is priced; empty = agile
+	TariffDefinition  string     // YAML bands of a time-of-use tariff
+	AgileProduct      string     // Pinned Octopus Agile product code; empty = discover the current one
+	Latitude          float64    // For weather forecasts
+	Longitude         float64    // For weather forecasts
 	QuietHours        []TimeWindow
 	BlockedWindows    []TimeWindow
 	AvailableHours    []TimeWindow // When you're home to start manual appliances
 	StaggerHeavyLoads bool
+	StaggerGapMinutes int // Minimum gap between heavy loads when staggering
 	CarbonWeight      float64
+	MaxImportKW       float64 // Supply limit for the whole house; 0 = unlimited
+	PVKWp             float64 // Rooftop solar peak rating; 0 = no panels
+	PVTiltDeg         float64 // Panel tilt from horizontal
+	PVAzimuthDeg      float64 // Compass bearing panels face; 180 = south
+	PVWeight          float64 // 0-1, how close to free surplus solar is treated
+	StartStepMinutes  int     // Granularity of candidate start times; 0 = every 30 min
+	LatePenaltyPence  float64 // Pence per minute an appliance overruns its deadline
+	FlatRatePence     float64 // Unit rate of a fixed tariff to compare against; 0 = not set
+	PriceCapPence     float64 // Ofgem price cap unit rate, for savings reports
+
+	ExportTariff    TariffType // Paid for exported solar: agile (Agile Outgoing), flat, or empty for none
+	ExportRatePence float64    // Flat export rate, e.g. a Smart Export Guarantee
+
+	BatteryKWh            float64   // Home battery capacity; 0 = no battery
+	BatteryChargeKW       float64   // Max charge rate
+	BatteryDischargeKW    float64   // Max discharge rate
+	BatteryEfficiency     float64   // Round-trip, 0-1
+	BatteryReservePercent float64   // Charge kept back for outages
+	BaseLoadKW            []float64 // Baseline draw per half hour from midnight; empty = flat estimate
 }
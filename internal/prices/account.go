@@ -0,0 +1,272 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// Settings a linked Octopus account is kept under
+const (
+	AccountNumberSetting = "octopus_account"
+	AccountKeySetting    = "octopus_api_key"
+	MPANSetting          = "octopus_mpan"
+	MeterSerialSetting   = "octopus_meter_serial"
+)
+
+// ErrUnauthorized is returned when Octopus rejects the API key or the key
+// can't see the account
+var ErrUnauthorized = errors.New("octopus rejected the API key for this account")
+
+// OctopusAccount reads a customer's account with their API key, found on the
+// Octopus dashboard under Personal details > API access
+type OctopusAccount struct {
+	octopusAPI
+	number string
+}
+
+// NewOctopusAccount creates a client for account number (A-1234ABCD)
+func NewOctopusAccount(apiKey, number string) *OctopusAccount {
+	return &OctopusAccount{
+		octopusAPI: newOctopusAPI(apiKey),
+		number:     number,
+	}
+}
+
+// ElectricityAgreement is the tariff an electricity meter point is on
+type ElectricityAgreement struct {
+	TariffCode  string // E-1R-AGILE-24-10-01-C
+	Product     string // AGILE-24-10-01
+	Region      string // C
+	MPAN        string
+	MeterSerial string
+	Export      bool
+	ValidFrom   time.Time
+	ValidTo     *time.Time // nil while open-ended
+}
+
+// accountResponse is the part of /accounts/{number}/ that matters here
+type accountResponse struct {
+	Properties []struct {
+		MovedOutAt             *time.Time `json:"moved_out_at"`
+		ElectricityMeterPoints []struct {
+			MPAN     string `json:"mpan"`
+			IsExport bool   `json:"is_export"`
+			Meters   []struct {
+				SerialNumber string `json:"serial_number"`
+			} `json:"meters"`
+			Agreements []struct {
+				TariffCode string     `json:"tariff_code"`
+				ValidFrom  time.Time  `json:"valid_from"`
+				ValidTo    *time.Time `json:"valid_to"`
+			} `json:"agreements"`
+		} `json:"electricity_meter_points"`
+	} `json:"properties"`
+}
+
+// consumptionResponse is a page of half-hourly meter readings
+type consumptionResponse struct {
+	Next    *string `json:"next"`
+	Results []struct {
+		Consumption   float64   `json:"consumption"`
+		IntervalStart time.Time `json:"interval_start"`
+		IntervalEnd   time.Time `json:"interval_end"`
+	} `json:"results"`
+}
+
+// Agreements returns the electricity agreements in force at a moment for the
+// property the customer still lives at, import first
+func (a *OctopusAccount) Agreements(ctx context.Context, at time.Time) ([]ElectricityAgreement, error) {
+	var account accountResponse
+	if err := a.get(ctx, fmt.Sprintf("%s/accounts/%s/", a.baseURL, url.PathEscape(a.number)), &account); err != nil {
+		return nil, err
+	}
+
+	agreements := []ElectricityAgreement{}
+	for _, property := range account.Properties {
+		if property.MovedOutAt != nil && !property.MovedOutAt.After(at) {
+			continue
+		}
+		for _, point := range property.ElectricityMeterPoints {
+			serial := ""
+			if n := len(point.Meters); n > 0 {
+				// Replaced meters stay listed; the newest comes last
+				serial = point.Meters[n-1].SerialNumber
+			}
+			for _, ag := range point.Agreements {
+				if ag.ValidFrom.After(at) || (ag.ValidTo != nil && !ag.ValidTo.After(at)) {
+					continue
+				}
+				product, region, err := ParseTariffCode(ag.TariffCode)
+				if err != nil {
+					return nil, err
+				}
+				agreement := ElectricityAgreement{
+					TariffCode:  ag.TariffCode,
+					Product:     product,
+					Region:      region,
+					MPAN:        point.MPAN,
+					MeterSerial: serial,
+					Export:      point.IsExport,
+					ValidFrom:   ag.ValidFrom,
+					ValidTo:     ag.ValidTo,
+				}
+				if agreement.Export {
+					agreements = append(agreements, agreement)
+				} else {
+					agreements = append([]ElectricityAgreement{agreement}, agreements...)
+				}
+			}
+		}
+	}
+
+	if len(agreements) == 0 {
+		return nil, fmt.Errorf("account %s has no electricity agreement in force", a.number)
+	}
+	return agreements, nil
+}
+
+// Consumption returns the half-hourly import a meter recorded over [from, to),
+// following every page, in time order
+func (a *OctopusAccount) Consumption(ctx context.Context, mpan, serial string, from, to time.Time) ([]engine.ConsumptionSlot, error) {
+	params := url.Values{}
+	params.Add("period_from", from.UTC().Format(time.RFC3339))
+	params.Add("period_to", to.UTC().Format(time.RFC3339))
+	params.Add("order_by", "period")
+	params.Add("page_size", "1500")
+	next := fmt.Sprintf("%s/electricity-meter-points/%s/meters/%s/consumption/?%s",
+		a.baseURL, url.PathEscape(mpan), url.PathEscape(serial), params.Encode())
+
+	slots := []engine.ConsumptionSlot{}
+	for next != "" {
+		var page consumptionResponse
+		if err := a.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Results {
+			slots = append(slots, engine.ConsumptionSlot{
+				Start: r.IntervalStart.UTC(),
+				End:   r.IntervalEnd.UTC(),
+				KWh:   r.Consumption,
+			})
+		}
+
+		next = ""
+		if page.Next != nil {
+			next = *page.Next
+		}
+	}
+
+	return slots, nil
+}
+
+// get fetches an authenticated endpoint, turning a refused key into
+// ErrUnauthorized
+func (a *OctopusAccount) get(ctx context.Context, endpoint string, v any) error {
+	err := a.getJSON(ctx, endpoint, v)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+		return ErrUnauthorized
+	}
+	return err
+}
+
+// AccountStore keeps what an account sync finds; *store.Store satisfies it
+type AccountStore interface {
+	ProductSettings
+	SaveConsumption(mpan string, slots []engine.ConsumptionSlot) error
+	LatestConsumption(mpan string) (time.Time, error)
+}
+
+// AccountSync is what Sync found
+type AccountSync struct {
+	Agreements []ElectricityAgreement
+	Changed    bool // Whether the household's region or tariff was updated
+	Readings   int  // Half-hourly readings stored
+}
+
+// Sync reads the account's agreements into the household, remembers the
+// import meter, and stores its consumption since the last reading held, or
+// over the backfill period when there are none. The caller saves the
+// household when Changed is set.
+func (a *OctopusAccount) Sync(ctx context.Context, st AccountStore, h *engine.Household, backfill time.Duration) (*AccountSync, error) {
+	now := time.Now()
+	agreements, err := a.Agreements(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountSync{Agreements: agreements, Changed: ApplyAgreements(h, agreements)}
+
+	meter := agreements[0]
+	if meter.Export {
+		return result, nil
+	}
+	if err := st.SetSetting(MPANSetting, meter.MPAN); err != nil {
+		return nil, err
+	}
+	if err := st.SetSetting(MeterSerialSetting, meter.MeterSerial); err != nil {
+		return nil, err
+	}
+
+	from, err := st.LatestConsumption(meter.MPAN)
+	if err != nil {
+		return nil, err
+	}
+	if from.IsZero() {
+		from = now.Add(-backfill).UTC().Truncate(30 * time.Minute)
+	}
+	readings, err := a.Consumption(ctx, meter.MPAN, meter.MeterSerial, from, now)
+	if err != nil {
+		return nil, fmt.Errorf("reading consumption: %w", err)
+	}
+	if err := st.SaveConsumption(meter.MPAN, readings); err != nil {
+		return nil, err
+	}
+	result.Readings = len(readings)
+
+	return result, nil
+}
+
+// ParseTariffCode splits an electricity tariff code such as
+// E-1R-AGILE-24-10-01-C into its product and region
+func ParseTariffCode(code string) (product, region string, err error) {
+	parts := strings.Split(code, "-")
+	if len(parts) < 4 || parts[0] != "E" {
+		return "", "", fmt.Errorf("%q is not an electricity tariff code", code)
+	}
+	region = parts[len(parts)-1]
+	if len(region) != 1 || region[0] < 'A' || region[0] > 'P' {
+		return "", "", fmt.Errorf("tariff code %q has no region", code)
+	}
+	return strings.Join(parts[2:len(parts)-1], "-"), region, nil
+}
+
+// ApplyAgreements sets a household's region and tariff from its account: an
+// Agile import agreement pins that product, and an Agile Outgoing export
+// agreement turns on export pricing. Other tariffs keep the household's own
+// pricing, but still give it the region. It reports whether anything changed.
+func ApplyAgreements(h *engine.Household, agreements []ElectricityAgreement) bool {
+	before := *h
+	for _, ag := range agreements {
+		isAgile := strings.HasPrefix(ag.Product, "AGILE-")
+		if ag.Export {
+			if isAgile {
+				h.ExportTariff = engine.TariffAgile
+			}
+			continue
+		}
+		h.Region = ag.Region
+		if isAgile {
+			h.Tariff = engine.TariffAgile
+			h.AgileProduct = ag.Product
+		}
+	}
+	return h.Region != before.Region || h.Tariff != before.Tariff ||
+		h.AgileProduct != before.AgileProduct || h.ExportTariff != before.ExportTariff
+}
@@ -0,0 +1,148 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// memoryAccountStore is an in-memory AccountStore
+type memoryAccountStore struct {
+	memorySettings
+	readings map[string][]engine.ConsumptionSlot
+}
+
+func (m *memoryAccountStore) SaveConsumption(mpan string, slots []engine.ConsumptionSlot) error {
+	m.readings[mpan] = append(m.readings[mpan], slots...)
+	return nil
+}
+
+func (m *memoryAccountStore) LatestConsumption(mpan string) (time.Time, error) {
+	var latest time.Time
+	for _, slot := range m.readings[mpan] {
+		if slot.End.After(latest) {
+			latest = slot.End
+		}
+	}
+	return latest, nil
+}
+
+// fakeAccountServer serves an account with an Agile import meter, an Agile
+// Outgoing export meter and a property moved out of, plus two pages of readings
+func fakeAccountServer(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); !ok || user != "sk_test" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/accounts/A-1234ABCD/":
+			fmt.Fprint(w, `{"number":"A-1234ABCD","properties":[
+				{"moved_out_at":"2023-01-01T00:00:00Z","electricity_meter_points":[
+					{"mpan":"1900000000001","meters":[{"serial_number":"OLD"}],
+					 "agreements":[{"tariff_code":"E-1R-VAR-22-11-01-A","valid_from":"2022-11-01T00:00:00Z","valid_to":null}]}]},
+				{"moved_out_at":null,"electricity_meter_points":[
+					{"mpan":"2000000000002","is_export":true,"meters":[{"serial_number":"21L1111111"}],
+					 "agreements":[{"tariff_code":"E-1R-AGILE-OUTGOING-19-05-13-C","valid_from":"2024-01-01T00:00:00Z","valid_to":null}]},
+					{"mpan":"2000000000001","is_export":false,"meters":[{"serial_number":"19L0000000"},{"serial_number":"21L1111111"}],
+					 "agreements":[
+						{"tariff_code":"E-1R-VAR-22-11-01-C","valid_from":"2023-01-01T00:00:00Z","valid_to":"2024-10-01T00:00:00+01:00"},
+						{"tariff_code":"E-1R-AGILE-24-10-01-C","valid_from":"2024-10-01T00:00:00+01:00","valid_to":null}
+					 ]}]}
+			]}`)
+		case "/electricity-meter-points/2000000000001/meters/21L1111111/consumption/":
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `{"next":null,"results":[
+					{"consumption":0.4,"interval_start":"2024-12-01T01:00:00Z","interval_end":"2024-12-01T01:30:00Z"}
+				]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"next":%q,"results":[
+				{"consumption":0.2,"interval_start":"2024-12-01T00:00:00Z","interval_end":"2024-12-01T00:30:00Z"},
+				{"consumption":0.3,"interval_start":"2024-12-01T00:30:00Z","interval_end":"2024-12-01T01:00:00Z"}
+			]}`, srv.URL+r.URL.Path+"?page=2")
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	return srv
+}
+
+func TestAccountSync(t *testing.T) {
+	srv := fakeAccountServer(t)
+	defer srv.Close()
+
+	account := NewOctopusAccount("sk_test", "A-1234ABCD")
+	account.baseURL = srv.URL
+	st := &memoryAccountStore{memorySettings: memorySettings{}, readings: map[string][]engine.ConsumptionSlot{}}
+	household := &engine.Household{Region: "A", Tariff: engine.TariffFlat, FlatRatePence: 24.5}
+
+	result, err := account.Sync(context.Background(), st, household, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Agreements) != 2 || result.Agreements[0].TariffCode != "E-1R-AGILE-24-10-01-C" || !result.Agreements[1].Export {
+		t.Fatalf("agreements = %+v, want the Agile import then the export", result.Agreements)
+	}
+	if !result.Changed || household.Region != "C" || household.Tariff != engine.TariffAgile ||
+		household.AgileProduct != "AGILE-24-10-01" || household.ExportTariff != engine.TariffAgile {
+		t.Errorf("household = %+v, want region C on Agile AGILE-24-10-01 with Agile export", household)
+	}
+	if st.memorySettings[MPANSetting] != "2000000000001" || st.memorySettings[MeterSerialSetting] != "21L1111111" {
+		t.Errorf("settings = %v, want the import MPAN and its newest meter", st.memorySettings)
+	}
+	if result.Readings != 3 || len(st.readings["2000000000001"]) != 3 {
+		t.Errorf("stored %d readings, want 3 across both pages", result.Readings)
+	}
+
+	// Syncing again asks only for readings after the last one held
+	latest, _ := st.LatestConsumption("2000000000001")
+	if want := time.Date(2024, 12, 1, 1, 30, 0, 0, time.UTC); !latest.Equal(want) {
+		t.Errorf("latest reading ends %v, want %v", latest, want)
+	}
+}
+
+func TestAccountUnauthorized(t *testing.T) {
+	srv := fakeAccountServer(t)
+	defer srv.Close()
+
+	account := NewOctopusAccount("sk_wrong", "A-1234ABCD")
+	account.baseURL = srv.URL
+	if _, err := account.Agreements(context.Background(), time.Now()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestParseTariffCode(t *testing.T) {
+	tests := []struct {
+		code, product, region string
+		wantErr               bool
+	}{
+		{code: "E-1R-AGILE-24-10-01-C", product: "AGILE-24-10-01", region: "C"},
+		{code: "E-1R-AGILE-OUTGOING-19-05-13-P", product: "AGILE-OUTGOING-19-05-13", region: "P"},
+		{code: "E-2R-VAR-22-11-01-A", product: "VAR-22-11-01", region: "A"},
+		{code: "G-1R-VAR-22-11-01-C", wantErr: true},
+		{code: "E-1R-AGILE-24-10-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		product, region, err := ParseTariffCode(tt.code)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.code, err, tt.wantErr)
+			continue
+		}
+		if product != tt.product || region != tt.region {
+			t.Errorf("%s: got %s in %s, want %s in %s", tt.code, product, region, tt.product, tt.region)
+		}
+	}
+}
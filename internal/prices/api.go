@@ -0,0 +1,153 @@
+package prices
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxAttempts is how many times a request is tried before giving up
+	maxAttempts = 4
+	// defaultRetryWait is the first backoff; it doubles with each retry
+	defaultRetryWait = time.Second
+	// maxRetryWait caps any single wait, including one asked for by Retry-After
+	maxRetryWait = time.Minute
+)
+
+// ErrNotPublished is returned when Octopus has no prices yet for a period,
+// as for tomorrow before the afternoon's publication
+var ErrNotPublished = errors.New("prices not yet published")
+
+// APIError is a request the Octopus API refused or failed, after any retries
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// GapError is returned when the slots Octopus sent skip part of the period
+// asked for, rather than stopping where publication has got to
+type GapError struct {
+	From, To time.Time // The first missing stretch
+}
+
+func (e *GapError) Error() string {
+	return fmt.Sprintf("prices missing from %s to %s", e.From.Format(time.RFC3339), e.To.Format(time.RFC3339))
+}
+
+// octopusAPI is the HTTP side shared by the Octopus clients: JSON GETs that
+// retry rate limiting and server errors with exponential backoff
+type octopusAPI struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string        // Basic auth user name; empty for public endpoints
+	retryWait  time.Duration // First backoff
+	logger     *log.Logger   // Told of retries and fallbacks; nil = silent
+}
+
+// SetLogger has problems the client works around rather than returns, such
+// as retried requests, reported to logger. Nothing is reported by default.
+func (a *octopusAPI) SetLogger(logger *log.Logger) {
+	a.logger = logger
+}
+
+// logf reports a problem worked around, when there is a logger to tell
+func (a *octopusAPI) logf(format string, v ...any) {
+	if a.logger != nil {
+		a.logger.Printf(format, v...)
+	}
+}
+
+func newOctopusAPI(apiKey string) octopusAPI {
+	return octopusAPI{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    octopusAPIBase,
+		apiKey:     apiKey,
+		retryWait:  defaultRetryWait,
+	}
+}
+
+// getJSON fetches endpoint and decodes its JSON into v. 429 and 5xx responses
+// are retried, waiting as long as Retry-After says when it is given; other
+// refusals come back as an *APIError straight away.
+func (a *octopusAPI) getJSON(ctx context.Context, endpoint string, v any) error {
+	backoff := a.retryWait
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := a.tryGet(ctx, endpoint, v)
+		if err == nil || retryAfter < 0 || attempt == maxAttempts {
+			return err
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		wait = min(wait, maxRetryWait)
+		a.logf("retrying Octopus request in %s: %v", wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// tryGet makes one request. When it fails, retryAfter is negative if the
+// request is not worth retrying, or the wait the server asked for (0 = none).
+func (a *octopusAPI) tryGet(ctx context.Context, endpoint string, v any) (retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return -1, fmt.Errorf("creating request: %w", err)
+	}
+	if a.apiKey != "" {
+		// The API key is the basic auth user name, with no password
+		req.SetBasicAuth(a.apiKey, "")
+	}
+
+	// Network failures aren't retried, so working offline falls back quickly
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return -1, fmt.Errorf("requesting %s: %w", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		err := &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), err
+		}
+		return -1, err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return -1, fmt.Errorf("decoding response: %w", err)
+	}
+	return 0, nil
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as an HTTP
+// date; anything else, or a time already past, means no particular wait
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
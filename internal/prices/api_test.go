@@ -0,0 +1,162 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// halfHours renders n Agile results starting at from, skipping the indexes in skip
+func halfHours(from time.Time, n int, skip ...int) string {
+	items := []string{}
+	for i := 0; i < n; i++ {
+		if slices.Contains(skip, i) {
+			continue
+		}
+		start := from.Add(time.Duration(i) * 30 * time.Minute)
+		items = append(items, fmt.Sprintf(`{"value_inc_vat":%d,"valid_from":%q,"valid_to":%q}`,
+			i, start.Format(time.RFC3339), start.Add(30*time.Minute).Format(time.RFC3339)))
+	}
+	return strings.Join(items, ",")
+}
+
+func testClient(url string) *OctopusClient {
+	client := NewOctopusClient("C")
+	client.baseURL = url
+	client.retryWait = time.Millisecond
+	client.PinProduct("AGILE-TEST")
+	return client
+}
+
+func TestHalfHourlyPagination(t *testing.T) {
+	day := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Newest first, as Octopus sends them, split over two pages
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprintf(w, `{"next":null,"results":[%s]}`, halfHours(day, 20))
+			return
+		}
+		fmt.Fprintf(w, `{"next":%q,"results":[%s]}`, srv.URL+r.URL.Path+"?page=2", halfHours(day.Add(10*time.Hour), 28))
+	}))
+	defer srv.Close()
+
+	slots, err := testClient(srv.URL).HalfHourly(context.Background(), day, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(slots) != 48 || !slots[0].Start.Equal(day) || !slots[47].End.Equal(day.Add(24*time.Hour)) {
+		t.Errorf("got %d slots, want the whole day from both pages in order", len(slots))
+	}
+}
+
+func TestHalfHourlyRetries(t *testing.T) {
+	day := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	responses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := responses[min(requests, len(responses)-1)]
+		requests++
+		if status != http.StatusOK {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "busy", status)
+			return
+		}
+		fmt.Fprintf(w, `{"results":[%s]}`, halfHours(day, 48))
+	}))
+	defer srv.Close()
+
+	slots, err := testClient(srv.URL).HalfHourly(context.Background(), day, "")
+	if err != nil || len(slots) != 48 {
+		t.Fatalf("got %d slots, err %v; want 48 after retrying", len(slots), err)
+	}
+	if requests != 3 {
+		t.Errorf("made %d requests, want 3", requests)
+	}
+
+	// A server that keeps failing is given up on after maxAttempts
+	requests = 0
+	responses = []int{http.StatusInternalServerError}
+	_, err = testClient(srv.URL).HalfHourly(context.Background(), day, "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got %v, want an APIError with status 500", err)
+	}
+	if requests != maxAttempts {
+		t.Errorf("made %d requests, want %d", requests, maxAttempts)
+	}
+
+	// Client errors aren't retried
+	requests = 0
+	responses = []int{http.StatusNotFound}
+	if _, err := testClient(srv.URL).HalfHourly(context.Background(), day, ""); !errors.As(err, &apiErr) || requests != 1 {
+		t.Errorf("got %v after %d requests, want a 404 APIError after 1", err, requests)
+	}
+}
+
+func TestHalfHourlyCoverage(t *testing.T) {
+	day := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		results   string
+		wantSlots int
+		wantErr   error
+		wantGap   bool
+	}{
+		{name: "whole day", results: halfHours(day, 48), wantSlots: 48},
+		{name: "published up to 22:00", results: halfHours(day, 44), wantSlots: 44},
+		{name: "nothing published", results: "", wantErr: ErrNotPublished},
+		{name: "hole in the day", results: halfHours(day, 48, 10, 11), wantGap: true},
+		{name: "missing start", results: halfHours(day, 48, 0), wantGap: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"results":[%s]}`, tt.results)
+			}))
+			defer srv.Close()
+
+			slots, err := testClient(srv.URL).HalfHourly(context.Background(), day, "")
+			var gap *GapError
+			switch {
+			case tt.wantGap:
+				if !errors.As(err, &gap) {
+					t.Errorf("got %v, want a GapError", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got %v, want %v", err, tt.wantErr)
+				}
+			case err != nil || len(slots) != tt.wantSlots:
+				t.Errorf("got %d slots, err %v; want %d", len(slots), err, tt.wantSlots)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{value: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
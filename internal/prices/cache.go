@@ -0,0 +1,168 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// PriceCache keeps fetched prices by key (the Octopus region for Agile) and
+// UTC day; *store.Store satisfies it
+type PriceCache interface {
+	CachePrices(region string, date time.Time, slots []engine.PriceSlot) error
+	GetCachedPrices(region string, date time.Time) ([]engine.PriceSlot, error)
+}
+
+// CachingProvider puts a price cache in front of a price source. Spans the
+// cache holds in full are served without a request; the rest of a span, past
+// whatever is cached, is fetched and cached. When the source can't be reached,
+// whatever the cache has is served instead and flagged as stale. A nil cache
+// passes every request straight to the source.
+type CachingProvider struct {
+	source PriceSource
+	cache  PriceCache
+	key    string
+	logger *log.Logger // Told of fallbacks and cache failures; nil = silent
+}
+
+// NewCachingProvider wraps source with cache, storing its prices under key
+func NewCachingProvider(source PriceSource, cache PriceCache, key string) *CachingProvider {
+	return &CachingProvider{source: source, cache: cache, key: key}
+}
+
+// SetLogger has problems the provider works around rather than returns, such
+// as prices it couldn't cache or a failed fetch served from the cache,
+// reported to logger, and passes it on to the source when that takes one too.
+// Nothing is reported by default.
+func (p *CachingProvider) SetLogger(logger *log.Logger) {
+	p.logger = logger
+	if source, ok := p.source.(interface{ SetLogger(*log.Logger) }); ok {
+		source.SetLogger(logger)
+	}
+}
+
+// logf reports a problem worked around, when there is a logger to tell
+func (p *CachingProvider) logf(format string, v ...any) {
+	if p.logger != nil {
+		p.logger.Printf(format, v...)
+	}
+}
+
+// HalfHourly returns the prices for a UTC day. stale is true when the day
+// couldn't be refreshed and only cached prices, possibly incomplete, were left.
+func (p *CachingProvider) HalfHourly(ctx context.Context, day time.Time) (slots []engine.PriceSlot, stale bool, err error) {
+	day = day.UTC()
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return p.Range(ctx, day, day.Add(24*time.Hour))
+}
+
+// FetchTodayAndTomorrow returns today's prices and tomorrow's when published,
+// the days running midnight to midnight UK time
+func (p *CachingProvider) FetchTodayAndTomorrow(ctx context.Context) ([]engine.PriceSlot, bool, error) {
+	from, to := LocalDays(time.Now(), 2)
+	slots, stale, err := p.Range(ctx, from, to)
+	if err != nil {
+		return nil, false, fmt.Errorf("fetching today's prices: %w", err)
+	}
+	return slots, stale, nil
+}
+
+// Range returns the slots overlapping [from, to), sorted and de-duplicated.
+// Whatever the cache holds from the start of the range onwards is used, and
+// the rest is fetched in one request and cached by UTC day. A range whose
+// end isn't published yet is returned as far as it goes. stale is true when
+// the source couldn't be reached and only cached prices were left.
+func (p *CachingProvider) Range(ctx context.Context, from, to time.Time) (slots []engine.PriceSlot, stale bool, err error) {
+	from = from.UTC().Truncate(30 * time.Minute)
+	to = to.UTC()
+	if p.cache == nil {
+		slots, err = p.source.Prices(ctx, from, to)
+		return trimSlots(normaliseSlots(slots), from, to), false, err
+	}
+
+	cached := p.cachedBetween(from, to)
+	fetchFrom := coveredUntil(cached, from)
+	if !fetchFrom.Before(to) {
+		return cached, false, nil
+	}
+
+	fetched, err := p.source.Prices(ctx, fetchFrom, to)
+	switch {
+	case errors.Is(err, ErrNotPublished) && len(cached) > 0:
+		// Everything published so far is already cached
+		return cached, false, nil
+	case err != nil && len(cached) > 0:
+		p.logf("serving cached prices from %s: %v", from.Format(time.RFC3339), err)
+		return cached, true, nil
+	case err != nil:
+		return nil, false, err
+	}
+
+	// Part-published days are cached too so they can be served offline; they
+	// are fetched again until complete
+	p.store(fetched)
+
+	return trimSlots(normaliseSlots(append(cached, fetched...)), from, to), false, nil
+}
+
+// Prices returns the slots overlapping [from, to) through the cache. Stale
+// prices are used as they are.
+func (p *CachingProvider) Prices(ctx context.Context, from, to time.Time) ([]engine.PriceSlot, error) {
+	slots, _, err := p.Range(ctx, from, to)
+	return slots, err
+}
+
+// cachedBetween returns the cached slots overlapping [from, to) in time order
+func (p *CachingProvider) cachedBetween(from, to time.Time) []engine.PriceSlot {
+	slots := []engine.PriceSlot{}
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC); day.Before(to); day = day.Add(24 * time.Hour) {
+		daySlots, _ := p.cache.GetCachedPrices(p.key, day)
+		slots = append(slots, daySlots...)
+	}
+	return trimSlots(normaliseSlots(slots), from, to)
+}
+
+// store adds fetched slots to the cache, merged into each UTC day's entry
+func (p *CachingProvider) store(fetched []engine.PriceSlot) {
+	byDay := make(map[time.Time][]engine.PriceSlot)
+	for _, slot := range fetched {
+		day := slot.Start.UTC().Truncate(24 * time.Hour)
+		byDay[day] = append(byDay[day], slot)
+	}
+	for day, slots := range byDay {
+		cached, _ := p.cache.GetCachedPrices(p.key, day)
+		merged := normaliseSlots(append(append([]engine.PriceSlot{}, cached...), slots...))
+		if err := p.cache.CachePrices(p.key, day, merged); err != nil {
+			p.logf("caching prices for %s: %v", day.Format("2006-01-02"), err)
+		}
+	}
+}
+
+// coveredUntil returns how far slots, in time order, run unbroken from from
+func coveredUntil(slots []engine.PriceSlot, from time.Time) time.Time {
+	covered := from
+	for _, slot := range slots {
+		if slot.Start.After(covered) {
+			break
+		}
+		if slot.End.After(covered) {
+			covered = slot.End
+		}
+	}
+	return covered
+}
+
+// trimSlots keeps the slots overlapping [from, to)
+func trimSlots(slots []engine.PriceSlot, from, to time.Time) []engine.PriceSlot {
+	trimmed := []engine.PriceSlot{}
+	for _, slot := range slots {
+		if slot.End.After(from) && slot.Start.Before(to) {
+			trimmed = append(trimmed, slot)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,119 @@
+package prices
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// memoryCache is an in-memory PriceCache
+type memoryCache map[string][]engine.PriceSlot
+
+func (m memoryCache) CachePrices(region string, date time.Time, slots []engine.PriceSlot) error {
+	m[region+date.Format("2006-01-02")] = slots
+	return nil
+}
+
+func (m memoryCache) GetCachedPrices(region string, date time.Time) ([]engine.PriceSlot, error) {
+	return m[region+date.Format("2006-01-02")], nil
+}
+
+// fakeOctopusServer serves whatever part of the requested period falls in the
+// first n half hours of its first day, or fails when n is negative
+func fakeOctopusServer(t *testing.T, n *int, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if *n < 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		from, err := time.Parse(time.RFC3339, r.URL.Query().Get("period_from"))
+		if err != nil {
+			t.Errorf("bad period_from: %v", err)
+		}
+		to, err := time.Parse(time.RFC3339, r.URL.Query().Get("period_to"))
+		if err != nil {
+			t.Errorf("bad period_to: %v", err)
+		}
+		day := from.Truncate(24 * time.Hour)
+		items := []string{}
+		for i := *n - 1; i >= 0; i-- {
+			start := day.Add(time.Duration(i) * 30 * time.Minute)
+			if start.Before(from) || !start.Before(to) {
+				continue
+			}
+			items = append(items, fmt.Sprintf(`{"value_exc_vat":%d,"value_inc_vat":%d,"valid_from":%q,"valid_to":%q}`,
+				i, i, start.Format(time.RFC3339), start.Add(30*time.Minute).Format(time.RFC3339)))
+		}
+		fmt.Fprintf(w, `{"count":%d,"results":[%s]}`, *n, strings.Join(items, ","))
+	}))
+}
+
+func TestCachingProvider(t *testing.T) {
+	available := 48
+	var requests int32
+	srv := fakeOctopusServer(t, &available, &requests)
+	defer srv.Close()
+
+	client := NewOctopusClient("C")
+	client.baseURL = srv.URL
+	client.retryWait = time.Millisecond
+	client.PinProduct("AGILE-TEST")
+	cache := memoryCache{}
+	provider := NewCachingProvider(client, cache, "C")
+	ctx := context.Background()
+	day := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	nextDay := day.Add(24 * time.Hour)
+
+	// A whole day is fetched once, then served from the cache
+	for i := 0; i < 2; i++ {
+		slots, stale, err := provider.HalfHourly(ctx, day)
+		if err != nil || stale || len(slots) != 48 {
+			t.Fatalf("got %d slots, stale %v, err %v; want 48 fresh", len(slots), stale, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests for a cached day, want 1", requests)
+	}
+
+	// A part-published day is fetched again until it is complete
+	available = 20
+	if slots, _, _ := provider.HalfHourly(ctx, nextDay); len(slots) != 20 {
+		t.Fatalf("got %d slots, want 20", len(slots))
+	}
+	available = 48
+	if slots, _, _ := provider.HalfHourly(ctx, nextDay); len(slots) != 48 {
+		t.Errorf("incomplete day not refreshed: got %d slots", len(slots))
+	}
+	if requests != 3 {
+		t.Errorf("made %d requests, want 3", requests)
+	}
+
+	// Offline, incomplete cached days are served as stale, and the retries and
+	// fallback are reported to the caller's logger rather than the log package's
+	var logged bytes.Buffer
+	provider.SetLogger(log.New(&logged, "", 0))
+	complete, _ := cache.GetCachedPrices("C", nextDay)
+	cache.CachePrices("C", nextDay, complete[:20])
+	available = -1
+	slots, stale, err := provider.HalfHourly(ctx, nextDay)
+	if err != nil || !stale || len(slots) != 20 {
+		t.Errorf("offline: got %d slots, stale %v, err %v; want 20 stale", len(slots), stale, err)
+	}
+	if !strings.Contains(logged.String(), "retrying Octopus request") || !strings.Contains(logged.String(), "serving cached prices") {
+		t.Errorf("logged %q, want the retries and the fallback", logged.String())
+	}
+	if _, _, err := provider.HalfHourly(ctx, nextDay.Add(24*time.Hour)); err == nil {
+		t.Errorf("expected an error offline with nothing cached")
+	}
+}
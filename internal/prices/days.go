@@ -0,0 +1,32 @@
+package prices
+
+import (
+	"time"
+	_ "time/tzdata" // Day boundaries are in UK time whatever the host's zone database
+)
+
+// UKTime is the time zone Octopus tariffs, and the households using them,
+// keep: Europe/London, so a day has 46 or 50 half hours when the clocks change
+var UKTime = mustLoadLocation("Europe/London")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
+
+// LocalDays returns the span of a number of UK days starting with the one t
+// falls in, from midnight to midnight local time
+func LocalDays(t time.Time, days int) (from, to time.Time) {
+	local := t.In(UKTime)
+	from = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, UKTime)
+	to = time.Date(local.Year(), local.Month(), local.Day()+days, 0, 0, 0, 0, UKTime)
+	return from.UTC(), to.UTC()
+}
+
+// LocalDate returns the UK calendar date of t as YYYY-MM-DD
+func LocalDate(t time.Time) string {
+	return t.In(UKTime).Format("2006-01-02")
+}
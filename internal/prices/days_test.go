@@ -0,0 +1,125 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalDays(t *testing.T) {
+	tests := []struct {
+		name     string
+		at       time.Time
+		days     int
+		wantFrom time.Time
+		wantHalf int // Half hours in the span
+	}{
+		{name: "winter", at: time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC), days: 1,
+			wantFrom: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), wantHalf: 48},
+		{name: "summer starts at 23:00 UTC", at: time.Date(2024, 7, 1, 23, 30, 0, 0, time.UTC), days: 1,
+			wantFrom: time.Date(2024, 7, 1, 23, 0, 0, 0, time.UTC), wantHalf: 48},
+		{name: "clocks go forward", at: time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC), days: 1,
+			wantFrom: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), wantHalf: 46},
+		{name: "clocks go back", at: time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC), days: 1,
+			wantFrom: time.Date(2024, 10, 26, 23, 0, 0, 0, time.UTC), wantHalf: 50},
+		{name: "two days over the change", at: time.Date(2024, 10, 26, 9, 0, 0, 0, time.UTC), days: 2,
+			wantFrom: time.Date(2024, 10, 25, 23, 0, 0, 0, time.UTC), wantHalf: 98},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := LocalDays(tt.at, tt.days)
+			if !from.Equal(tt.wantFrom) {
+				t.Errorf("from = %v, want %v", from, tt.wantFrom)
+			}
+			if got := int(to.Sub(from) / (30 * time.Minute)); got != tt.wantHalf {
+				t.Errorf("span has %d half hours, want %d", got, tt.wantHalf)
+			}
+		})
+	}
+}
+
+// fakeRangeServer serves every half hour of the requested period, newest
+// first, in pages of 40 that each repeat the last slot of the page before
+func fakeRangeServer(t *testing.T, requests *int) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		query := r.URL.Query()
+		from, err := time.Parse(time.RFC3339, query.Get("period_from"))
+		if err != nil {
+			t.Errorf("bad period_from: %v", err)
+		}
+		to, err := time.Parse(time.RFC3339, query.Get("period_to"))
+		if err != nil {
+			t.Errorf("bad period_to: %v", err)
+		}
+
+		items := []string{}
+		for start := to.Add(-30 * time.Minute); !start.Before(from); start = start.Add(-30 * time.Minute) {
+			items = append(items, fmt.Sprintf(`{"value_inc_vat":%d,"valid_from":%q,"valid_to":%q}`,
+				start.Hour(), start.Format(time.RFC3339), start.Add(30*time.Minute).Format(time.RFC3339)))
+		}
+
+		page, _ := strconv.Atoi(query.Get("page"))
+		first := max(0, page*40-1)
+		last := min(len(items), (page+1)*40)
+		next := "null"
+		if last < len(items) {
+			query.Set("page", strconv.Itoa(page+1))
+			next = strconv.Quote(srv.URL + r.URL.Path + "?" + query.Encode())
+		}
+		fmt.Fprintf(w, `{"next":%s,"results":[%s]}`, next, strings.Join(items[first:last], ","))
+	}))
+	return srv
+}
+
+func TestRangeAcrossClockChange(t *testing.T) {
+	requests := 0
+	srv := fakeRangeServer(t, &requests)
+	defer srv.Close()
+
+	client := NewOctopusClient("C")
+	client.baseURL = srv.URL
+	client.PinProduct("AGILE-TEST")
+	cache := memoryCache{}
+	provider := NewCachingProvider(client, cache, "C")
+
+	// Saturday and the Sunday the clocks go back: 48 + 50 half hours
+	from, to := LocalDays(time.Date(2024, 10, 26, 9, 0, 0, 0, time.UTC), 2)
+	slots, stale, err := provider.Range(context.Background(), from, to)
+	if err != nil || stale {
+		t.Fatalf("unexpected error %v (stale %v)", err, stale)
+	}
+	if len(slots) != 98 {
+		t.Fatalf("got %d slots, want 98", len(slots))
+	}
+	for i := 1; i < len(slots); i++ {
+		if !slots[i].Start.Equal(slots[i-1].End) {
+			t.Fatalf("slot %d starts %v, want %v: not sorted and de-duplicated", i, slots[i].Start, slots[i-1].End)
+		}
+	}
+	// Three pages, but one request for the range
+	if requests != 3 {
+		t.Errorf("made %d requests, want 3 pages of one range", requests)
+	}
+
+	// The whole range is cached now
+	if again, _, _ := provider.Range(context.Background(), from, to); len(again) != 98 || requests != 3 {
+		t.Errorf("got %d slots after %d requests, want 98 from the cache", len(again), requests)
+	}
+
+	// Extending it fetches only what is missing
+	_, further := LocalDays(from, 3)
+	if more, _, _ := provider.Range(context.Background(), from, further); len(more) != 146 {
+		t.Errorf("got %d slots over three days, want 146", len(more))
+	}
+	if requests != 5 {
+		t.Errorf("made %d requests, want two more pages for the third day", requests)
+	}
+}
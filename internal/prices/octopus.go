@@ -2,11 +2,13 @@ package prices
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/csv"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/awaistahir/smart-run/internal/engine"
@@ -14,22 +16,27 @@ import (
 
 const (
 	octopusAPIBase = "https://api.octopus.energy/v1"
-	// Current Agile product code - update as needed
+	// Agile product used when discovery fails and none was found before
 	defaultAgileProduct = "AGILE-24-10-01"
+	// Agile Outgoing product used when discovery fails likewise
+	defaultAgileExportProduct = "AGILE-OUTGOING-19-05-13"
 )
 
 // OctopusClient fetches electricity prices from Octopus Energy Agile tariff
 type OctopusClient struct {
-	httpClient *http.Client
-	product    string
-	region     string
+	octopusAPI
+	product       string // Empty until pinned or discovered
+	productSource ProductSource
+	settings      ProductSettings
+	region        string
+	export        bool // Agile Outgoing export rates rather than import
 }
 
-// NewOctopusClient creates a new client for the Octopus Agile API
+// NewOctopusClient creates a new client for the Octopus Agile API. The Agile
+// product is discovered on first use unless pinned with PinProduct.
 func NewOctopusClient(region string) *OctopusClient {
 	return &OctopusClient{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
-		product:    defaultAgileProduct,
+		octopusAPI: newOctopusAPI(""),
 		region:     region,
 	}
 }
@@ -50,100 +57,170 @@ type resultItem struct {
 	PaymentMethod *string  `json:"payment_method"`
 }
 
-// HalfHourly fetches half-hourly prices for a specific day and region
+// HalfHourly fetches half-hourly prices for a specific UTC day and region.
+// Export clients return what Octopus pays per kWh exported. A day with no
+// prices yet gives ErrNotPublished, and one whose prices skip a stretch other
+// than the unpublished end of the day a *GapError.
 func (c *OctopusClient) HalfHourly(ctx context.Context, day time.Time, region string) ([]engine.PriceSlot, error) {
+	startOfDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return c.fetchRange(ctx, region, startOfDay, startOfDay.Add(24*time.Hour))
+}
+
+// Prices fetches the slots overlapping [from, to) for the client's region in
+// a single request, however many days it spans, checked like HalfHourly
+func (c *OctopusClient) Prices(ctx context.Context, from, to time.Time) ([]engine.PriceSlot, error) {
+	return c.fetchRange(ctx, c.region, from.UTC().Truncate(30*time.Minute), to.UTC())
+}
+
+// fetchRange requests the unit rates for [from, to), following every page of
+// results, and returns them sorted and de-duplicated
+func (c *OctopusClient) fetchRange(ctx context.Context, region string, from, to time.Time) ([]engine.PriceSlot, error) {
 	if region == "" {
 		region = c.region
 	}
 
 	// Construct tariff code: E-1R-{PRODUCT}-{REGION}
-	tariffCode := fmt.Sprintf("E-1R-%s-%s", c.product, region)
+	product, _ := c.Product(ctx)
+	tariffCode := fmt.Sprintf("E-1R-%s-%s", product, region)
 
 	// Build URL
 	endpoint := fmt.Sprintf("%s/products/%s/electricity-tariffs/%s/standard-unit-rates/",
-		octopusAPIBase, c.product, tariffCode)
-
-	// Set period for the full day in UTC
-	startOfDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
-	endOfDay := startOfDay.Add(24 * time.Hour)
+		c.baseURL, product, tariffCode)
 
 	// Build query params
 	params := url.Values{}
-	params.Add("period_from", startOfDay.Format(time.RFC3339))
-	params.Add("period_to", endOfDay.Format(time.RFC3339))
-
-	fullURL := fmt.Sprintf("%s?%s", endpoint, params.Encode())
+	params.Add("period_from", from.Format(time.RFC3339))
+	params.Add("period_to", to.Format(time.RFC3339))
+	params.Add("page_size", "1500")
+
+	// Follow the pages until there is no next one
+	slots := []engine.PriceSlot{}
+	next := fmt.Sprintf("%s?%s", endpoint, params.Encode())
+	for next != "" {
+		var octResp octopusResponse
+		if err := c.getJSON(ctx, next, &octResp); err != nil {
+			return nil, fmt.Errorf("fetching prices: %w", err)
+		}
 
-	// Make request
-	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
+		for _, r := range octResp.Results {
+			slots = append(slots, engine.PriceSlot{
+				Start:       r.ValidFrom.UTC(),
+				End:         r.ValidTo.UTC(),
+				PencePerKWh: r.ValueIncVAT,
+				IncludesVAT: true,
+			})
+		}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("fetching prices: %w", err)
+		next = ""
+		if octResp.Next != nil {
+			next = *octResp.Next
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
+	// API returns newest first, and pages can overlap if prices are
+	// published between requests
+	slots = normaliseSlots(slots)
 
-	// Parse response
-	var octResp octopusResponse
-	if err := json.NewDecoder(resp.Body).Decode(&octResp); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("%s from %s: %w", tariffCode, from.Format(time.RFC3339), ErrNotPublished)
 	}
-
-	// Convert to PriceSlots
-	slots := make([]engine.PriceSlot, 0, len(octResp.Results))
-	for _, r := range octResp.Results {
-		slots = append(slots, engine.PriceSlot{
-			Start:        r.ValidFrom,
-			End:          r.ValidTo,
-			PencePerKWh:  r.ValueIncVAT,
-			IncludesVAT:  true,
-		})
+	if err := checkCoverage(slots, from); err != nil {
+		return nil, fmt.Errorf("%s: %w", tariffCode, err)
 	}
 
-	// Sort by start time (API returns in reverse chronological order)
-	sortSlotsByTime(slots)
-
 	return slots, nil
 }
 
+// checkCoverage returns a *GapError unless slots, in time order, run without
+// a break from the start of the period. Prices are published in one run
+// forwards, so ending early just means the rest isn't out yet.
+func checkCoverage(slots []engine.PriceSlot, from time.Time) error {
+	covered := from
+	for _, slot := range slots {
+		if slot.Start.After(covered) {
+			return &GapError{From: covered, To: slot.Start}
+		}
+		if slot.End.After(covered) {
+			covered = slot.End
+		}
+	}
+	return nil
+}
+
 // sortSlotsByTime sorts price slots in ascending time order
 func sortSlotsByTime(slots []engine.PriceSlot) {
-	// Simple bubble sort since we're dealing with max 48 slots
-	for i := 0; i < len(slots)-1; i++ {
-		for j := 0; j < len(slots)-i-1; j++ {
-			if slots[j].Start.After(slots[j+1].Start) {
-				slots[j], slots[j+1] = slots[j+1], slots[j]
-			}
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+}
+
+// normaliseSlots sorts slots into time order and keeps one per start time,
+// the last given, so later data replaces earlier
+func normaliseSlots(slots []engine.PriceSlot) []engine.PriceSlot {
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+	unique := slots[:0]
+	for _, slot := range slots {
+		if n := len(unique); n > 0 && unique[n-1].Start.Equal(slot.Start) {
+			unique[n-1] = slot
+			continue
 		}
+		unique = append(unique, slot)
 	}
+	return unique
 }
 
-// FetchTodayAndTomorrow fetches prices for today and tomorrow (if available)
+// FetchTodayAndTomorrow fetches prices for today and tomorrow (if available),
+// the days running midnight to midnight UK time
 func (c *OctopusClient) FetchTodayAndTomorrow(ctx context.Context, region string) ([]engine.PriceSlot, error) {
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-	tomorrow := today.Add(24 * time.Hour)
-
-	// Fetch today
-	todaySlots, err := c.HalfHourly(ctx, today, region)
+	from, to := LocalDays(time.Now(), 2)
+	slots, err := c.fetchRange(ctx, region, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("fetching today's prices: %w", err)
 	}
+	return slots, nil
+}
 
-	// Fetch tomorrow (may fail if not yet published)
-	tomorrowSlots, err := c.HalfHourly(ctx, tomorrow, region)
+// ReadSlotsCSV reads prices exported from the Octopus API or dashboard: a
+// header row naming valid_from, valid_to and value_inc_vat columns (in any
+// order), then one RFC3339 half hour per row. Slots come back in time order.
+func ReadSlotsCSV(r io.Reader) ([]engine.PriceSlot, error) {
+	records, err := csv.NewReader(r).ReadAll()
 	if err != nil {
-		// Tomorrow not available yet, that's okay
-		return todaySlots, nil
+		return nil, fmt.Errorf("reading CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty CSV")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"valid_from", "valid_to", "value_inc_vat"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV has no %s column", name)
+		}
+	}
+
+	slots := make([]engine.PriceSlot, 0, len(records)-1)
+	for line, record := range records[1:] {
+		from, err := time.Parse(time.RFC3339, record[columns["valid_from"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: valid_from: %w", line+2, err)
+		}
+		to, err := time.Parse(time.RFC3339, record[columns["valid_to"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: valid_to: %w", line+2, err)
+		}
+		pence, err := strconv.ParseFloat(record[columns["value_inc_vat"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: value_inc_vat: %w", line+2, err)
+		}
+		slots = append(slots, engine.PriceSlot{Start: from, End: to, PencePerKWh: pence, IncludesVAT: true})
 	}
 
-	return append(todaySlots, tomorrowSlots...), nil
+	sortSlotsByTime(slots)
+	return slots, nil
 }
@@ -0,0 +1,182 @@
+package prices
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+const (
+	// predictHistoryDays of cached prices are what predictions are built from
+	predictHistoryDays = 28
+	// minHistoryDays with cached prices are needed before predicting at all
+	minHistoryDays = 7
+	// trendDays is the recent stretch whose level shifts the whole profile
+	trendDays = 7
+	// agileCapPence is the most an Agile unit can cost, VAT included
+	agileCapPence = 100.0
+	// bandZ turns the spread of past prices into an 80% confidence band
+	bandZ = 1.28
+	// bandGrowth widens the band by this share for each further day ahead
+	bandGrowth = 0.25
+	// typicalWindMps is the 100 m wind speed the history is taken to reflect;
+	// each m/s above it is assumed to take windPencePerMps off prices, to at
+	// most maxWindPence either way
+	typicalWindMps  = 8.0
+	windPencePerMps = 0.8
+	maxWindPence    = 8.0
+)
+
+// ErrTooLittleHistory is returned when too few days of past prices are cached
+// to predict from
+var ErrTooLittleHistory = errors.New("not enough cached prices to predict from")
+
+// slotStats accumulates prices for one half hour of the day
+type slotStats struct {
+	n, sum, sumSq float64
+}
+
+func (s *slotStats) add(v float64) {
+	s.n++
+	s.sum += v
+	s.sumSq += v * v
+}
+
+func (s slotStats) mean() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return s.sum / s.n
+}
+
+func (s slotStats) stdDev() float64 {
+	if s.n < 2 {
+		return 0
+	}
+	mean := s.mean()
+	return math.Sqrt(math.Max(0, s.sumSq/s.n-mean*mean))
+}
+
+// PredictPrices forecasts the half hours of [from, to) from past prices. Each
+// half hour of the UK day starts at its average over the history, shifted by
+// how that day of the week runs compared with the rest and by how the last
+// week ran compared with the whole history. A wind forecast, when given,
+// lowers prices on windy days and raises them on calm ones. Slots come back
+// marked Predicted with an 80% band from how far that half hour has strayed
+// from the model, widening for each day further ahead.
+func PredictPrices(history []engine.PriceSlot, from, to time.Time, wind []engine.WeatherSlot) ([]engine.PriceSlot, error) {
+	days := map[string]bool{}
+	var latest time.Time
+	for _, slot := range history {
+		days[LocalDate(slot.Start)] = true
+		if slot.Start.After(latest) {
+			latest = slot.Start
+		}
+	}
+	if len(days) < minHistoryDays {
+		return nil, ErrTooLittleHistory
+	}
+
+	// Time-of-day profile, then each weekday's offset from it
+	profile := map[int]*slotStats{}
+	for _, slot := range history {
+		statsFor(profile, halfHourOfDay(slot.Start)).add(slot.PencePerKWh)
+	}
+	weekday := map[time.Weekday]*slotStats{}
+	for _, slot := range history {
+		t := slot.Start.In(UKTime)
+		statsFor(weekday, t.Weekday()).add(slot.PencePerKWh - profile[halfHourOfDay(t)].mean())
+	}
+
+	// What the profile misses: its spread per half hour, and the recent level
+	residuals := map[int]*slotStats{}
+	var all, recent slotStats
+	recentFrom := latest.Add(-trendDays * 24 * time.Hour)
+	for _, slot := range history {
+		t := slot.Start.In(UKTime)
+		r := slot.PencePerKWh - profile[halfHourOfDay(t)].mean() - weekday[t.Weekday()].mean()
+		statsFor(residuals, halfHourOfDay(t)).add(r)
+		all.add(r)
+		if slot.Start.After(recentFrom) {
+			recent.add(r)
+		}
+	}
+	trend := recent.mean() - all.mean()
+
+	slots := []engine.PriceSlot{}
+	lastDay, _ := LocalDays(latest, 1)
+	for start := from.UTC().Truncate(30 * time.Minute); start.Before(to); start = start.Add(30 * time.Minute) {
+		t := start.In(UKTime)
+		centre := all.mean() + trend
+		spread := all.stdDev()
+		if p, ok := profile[halfHourOfDay(t)]; ok {
+			centre += p.mean()
+			spread = residuals[halfHourOfDay(t)].stdDev()
+		}
+		// A weekday missing from the history is taken to run like the rest
+		if w, ok := weekday[t.Weekday()]; ok {
+			centre += w.mean()
+		}
+		if speed, ok := windAt(wind, start); ok {
+			centre -= math.Max(-maxWindPence, math.Min(maxWindPence, (speed-typicalWindMps)*windPencePerMps))
+		}
+
+		// Days ahead of the last known price, from 1 for the day after it
+		day, _ := LocalDays(start, 1)
+		ahead := math.Max(1, math.Round(day.Sub(lastDay).Hours()/24))
+		half := bandZ * spread * (1 + bandGrowth*(ahead-1))
+
+		slots = append(slots, engine.PriceSlot{
+			Start:       start,
+			End:         start.Add(30 * time.Minute),
+			PencePerKWh: roundPence(math.Min(centre, agileCapPence)),
+			IncludesVAT: true,
+			Predicted:   true,
+			LowPence:    roundPence(math.Min(centre-half, agileCapPence)),
+			HighPence:   roundPence(math.Min(centre+half, agileCapPence)),
+		})
+	}
+	return slots, nil
+}
+
+// Predict forecasts [from, to) from the prices cached over the four weeks
+// before it. Tariffs worked out locally are never missing prices, so a
+// provider without a cache has nothing to predict.
+func (p *CachingProvider) Predict(from, to time.Time, wind []engine.WeatherSlot) ([]engine.PriceSlot, error) {
+	if p.cache == nil || !from.Before(to) {
+		return nil, nil
+	}
+	history := p.cachedBetween(from.Add(-predictHistoryDays*24*time.Hour), from)
+	return PredictPrices(history, from, to, wind)
+}
+
+// statsFor returns the stats kept under key, adding them if new
+func statsFor[K comparable](stats map[K]*slotStats, key K) *slotStats {
+	if stats[key] == nil {
+		stats[key] = &slotStats{}
+	}
+	return stats[key]
+}
+
+// halfHourOfDay returns which half hour of the UK day t falls in, by clock
+// time, so the repeated hour when the clocks go back shares its profile
+func halfHourOfDay(t time.Time) int {
+	local := t.In(UKTime)
+	return local.Hour()*2 + local.Minute()/30
+}
+
+// windAt returns the hourly wind speed forecast covering t
+func windAt(wind []engine.WeatherSlot, t time.Time) (float64, bool) {
+	for _, w := range wind {
+		if !t.Before(w.Time) && t.Before(w.Time.Add(time.Hour)) {
+			return w.WindMps, true
+		}
+	}
+	return 0, false
+}
+
+func roundPence(p float64) float64 {
+	return math.Round(p*100) / 100
+}
@@ -0,0 +1,170 @@
+package prices
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// pastPrices returns every half hour of the UK days before until, priced by
+// price
+func pastPrices(until time.Time, days int, price func(t time.Time) float64) []engine.PriceSlot {
+	from, _ := LocalDays(until.AddDate(0, 0, -days), 1)
+	slots := []engine.PriceSlot{}
+	for start := from; start.Before(until); start = start.Add(30 * time.Minute) {
+		slots = append(slots, engine.PriceSlot{Start: start, End: start.Add(30 * time.Minute), PencePerKWh: price(start)})
+	}
+	return slots
+}
+
+// agileShape is 12p overnight, 35p over the 16:00-19:00 peak and 22p
+// otherwise, 5p less at weekends
+func agileShape(t time.Time) float64 {
+	local := t.In(UKTime)
+	price := 22.0
+	switch {
+	case local.Hour() < 7:
+		price = 12
+	case local.Hour() >= 16 && local.Hour() < 19:
+		price = 35
+	}
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		price -= 5
+	}
+	// A little day-to-day noise so there is a band to speak of
+	return price + float64(local.Day()%3) - 1
+}
+
+// at returns the predicted slot starting at a UK wall-clock time on day
+func at(slots []engine.PriceSlot, day time.Time, hour, minute int) engine.PriceSlot {
+	local := day.In(UKTime)
+	want := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, UKTime)
+	for _, slot := range slots {
+		if slot.Start.Equal(want) {
+			return slot
+		}
+	}
+	return engine.PriceSlot{}
+}
+
+func TestPredictPrices(t *testing.T) {
+	// Predict Thursday 5 and Friday 6 December 2024 from the four weeks before
+	from := time.Date(2024, 12, 5, 0, 0, 0, 0, time.UTC)
+	history := pastPrices(from, 28, agileShape)
+
+	slots, err := PredictPrices(history, from, from.AddDate(0, 0, 2), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(slots) != 96 {
+		t.Fatalf("got %d slots, want two days", len(slots))
+	}
+	for _, slot := range slots {
+		if !slot.Predicted || slot.LowPence > slot.PencePerKWh || slot.HighPence < slot.PencePerKWh {
+			t.Fatalf("slot %v = %+v, want predicted within its band", slot.Start, slot)
+		}
+	}
+
+	night, peak := at(slots, from, 3, 0), at(slots, from, 17, 0)
+	if math.Abs(night.PencePerKWh-12) > 1.5 || math.Abs(peak.PencePerKWh-35) > 1.5 {
+		t.Errorf("predicted %.2fp overnight and %.2fp at the peak, want about 12p and 35p", night.PencePerKWh, peak.PencePerKWh)
+	}
+	// Further out, less sure
+	friday := at(slots, from.AddDate(0, 0, 1), 3, 0)
+	if friday.HighPence-friday.LowPence <= night.HighPence-night.LowPence {
+		t.Errorf("Friday's band %.2f-%.2fp is no wider than Thursday's %.2f-%.2fp",
+			friday.LowPence, friday.HighPence, night.LowPence, night.HighPence)
+	}
+
+	// Weekends run cheaper
+	saturday := time.Date(2024, 12, 7, 0, 0, 0, 0, time.UTC)
+	weekend, err := PredictPrices(pastPrices(saturday, 28, agileShape), saturday, saturday.AddDate(0, 0, 1), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := at(weekend, saturday, 17, 0).PencePerKWh; got > peak.PencePerKWh-3 {
+		t.Errorf("predicted %.2fp at Saturday's peak, want it below Thursday's %.2fp", got, peak.PencePerKWh)
+	}
+
+	// A dearer last week lifts the prediction
+	rising := pastPrices(from, 28, func(t time.Time) float64 {
+		if from.Sub(t) <= 7*24*time.Hour {
+			return agileShape(t) + 6
+		}
+		return agileShape(t)
+	})
+	trended, _ := PredictPrices(rising, from, from.AddDate(0, 0, 1), nil)
+	if got := at(trended, from, 3, 0).PencePerKWh; got < night.PencePerKWh+3 {
+		t.Errorf("predicted %.2fp after a dearer week, want well above %.2fp", got, night.PencePerKWh)
+	}
+
+	// Wind pushes prices down, and a calm raises them
+	windy := []engine.WeatherSlot{{Time: from.Add(3 * time.Hour), WindMps: 16}, {Time: from.Add(17 * time.Hour), WindMps: 2}}
+	blown, _ := PredictPrices(history, from, from.AddDate(0, 0, 1), windy)
+	if got := at(blown, from, 3, 0).PencePerKWh; math.Abs(got-(night.PencePerKWh-6.4)) > 0.01 {
+		t.Errorf("predicted %.2fp in a 16 m/s wind, want 6.4p below %.2fp", got, night.PencePerKWh)
+	}
+	if got := at(blown, from, 17, 0).PencePerKWh; math.Abs(got-(peak.PencePerKWh+4.8)) > 0.01 {
+		t.Errorf("predicted %.2fp in a 2 m/s wind, want 4.8p above %.2fp", got, peak.PencePerKWh)
+	}
+}
+
+func TestPredictPricesClockChange(t *testing.T) {
+	// Sunday 27 October 2024, when the clocks go back
+	from, to := LocalDays(time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC), 1)
+	slots, err := PredictPrices(pastPrices(from, 14, agileShape), from, to, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(slots) != 50 {
+		t.Fatalf("got %d slots, want 50", len(slots))
+	}
+	// 01:00 BST and 01:00 GMT are the same time of day
+	if slots[2].PencePerKWh != slots[4].PencePerKWh {
+		t.Errorf("the repeated 01:00 predicted %.2fp then %.2fp, want the same", slots[2].PencePerKWh, slots[4].PencePerKWh)
+	}
+}
+
+func TestPredictTooLittleHistory(t *testing.T) {
+	from := time.Date(2024, 12, 5, 0, 0, 0, 0, time.UTC)
+	if _, err := PredictPrices(pastPrices(from, 3, agileShape), from, from.AddDate(0, 0, 1), nil); !errors.Is(err, ErrTooLittleHistory) {
+		t.Errorf("got %v, want ErrTooLittleHistory", err)
+	}
+
+	// Predicting goes through the cache, and a tariff worked out locally has
+	// nothing to predict
+	cache := memoryCache{}
+	provider := NewCachingProvider(nil, cache, "C")
+	provider.store(pastPrices(from, 10, agileShape))
+	if slots, err := provider.Predict(from, from.AddDate(0, 0, 1), nil); err != nil || len(slots) != 48 {
+		t.Errorf("got %d slots, err %v; want a day predicted from the cache", len(slots), err)
+	}
+	if slots, err := NewCachingProvider(FlatRate{PencePerKWh: 24}, nil, "").Predict(from, from.AddDate(0, 0, 1), nil); err != nil || slots != nil {
+		t.Errorf("got %d slots, err %v; want none for a flat rate", len(slots), err)
+	}
+}
+
+func TestPredictPricesHistoryGaps(t *testing.T) {
+	// Nine weekdays cached, skipping the weekends, then a Sunday to predict
+	sunday := time.Date(2024, 12, 8, 0, 0, 0, 0, time.UTC)
+	history := []engine.PriceSlot{}
+	for _, slot := range pastPrices(sunday, 12, agileShape) {
+		if day := slot.Start.In(UKTime).Weekday(); day != time.Saturday && day != time.Sunday {
+			history = append(history, slot)
+		}
+	}
+
+	slots, err := PredictPrices(history, sunday, sunday.AddDate(0, 0, 1), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(slots) != 48 {
+		t.Fatalf("got %d slots, want 48", len(slots))
+	}
+	if got := at(slots, sunday, 3, 0).PencePerKWh; math.Abs(got-12) > 1.5 {
+		t.Errorf("predicted %.2fp overnight, want about 12p from the weekday profile", got)
+	}
+}
@@ -0,0 +1,175 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+const (
+	// agileProductKey is the setting the discovered Agile product is kept under
+	agileProductKey = "agile_product"
+	// agileExportProductKey is the same for the Agile Outgoing product
+	agileExportProductKey = "agile_export_product"
+	// productTTL is how long a discovered product is used before looking again
+	productTTL = 24 * time.Hour
+)
+
+// ProductSettings remembers the discovered Agile product between runs;
+// *store.Store satisfies it
+type ProductSettings interface {
+	GetSetting(key string) (string, time.Time, error)
+	SetSetting(key, value string) error
+}
+
+// ProductSource says where the Agile product code in use came from
+type ProductSource string
+
+const (
+	ProductPinned     ProductSource = "pinned"     // Chosen by the user
+	ProductDiscovered ProductSource = "discovered" // Found in the Octopus product listing
+	ProductCached     ProductSource = "cached"     // Found earlier and remembered
+	ProductDefault    ProductSource = "default"    // Built-in fallback
+)
+
+// productsResponse is a page of the Octopus /products/ listing
+type productsResponse struct {
+	Next    *string       `json:"next"`
+	Results []productItem `json:"results"`
+}
+
+type productItem struct {
+	Code          string     `json:"code"`
+	Direction     string     `json:"direction"`
+	IsBusiness    bool       `json:"is_business"`
+	IsPrepay      bool       `json:"is_prepay"`
+	AvailableFrom *time.Time `json:"available_from"`
+	AvailableTo   *time.Time `json:"available_to"`
+}
+
+// NewAgileClient returns an Octopus client for a household's region using its
+// pinned Agile product, or else the current one, discovered and remembered in
+// settings when they are given
+func NewAgileClient(h *engine.Household, settings ProductSettings) *OctopusClient {
+	region := h.Region
+	if region == "" {
+		region = "C"
+	}
+	client := NewOctopusClient(region)
+	client.settings = settings
+	if h.AgileProduct != "" {
+		client.PinProduct(h.AgileProduct)
+	}
+	return client
+}
+
+// NewAgileExportClient returns an Octopus client for Agile Outgoing export
+// rates in a household's region, discovering the current product like
+// NewAgileClient
+func NewAgileExportClient(h *engine.Household, settings ProductSettings) *OctopusClient {
+	region := h.Region
+	if region == "" {
+		region = "C"
+	}
+	client := NewOctopusClient(region)
+	client.settings = settings
+	client.export = true
+	return client
+}
+
+// PinProduct makes the client use an Agile product code instead of discovering one
+func (c *OctopusClient) PinProduct(code string) {
+	c.product = code
+	c.productSource = ProductPinned
+}
+
+// Product returns the Agile product code the client uses and where it came
+// from. An unpinned client looks it up the first time: a product remembered
+// in the last day, then the Octopus listing, then any product remembered
+// before, then the built-in default.
+func (c *OctopusClient) Product(ctx context.Context) (string, ProductSource) {
+	if c.product != "" {
+		return c.product, c.productSource
+	}
+
+	key, fallback := agileProductKey, defaultAgileProduct
+	if c.export {
+		key, fallback = agileExportProductKey, defaultAgileExportProduct
+	}
+
+	var cached string
+	if c.settings != nil {
+		code, updated, err := c.settings.GetSetting(key)
+		if err == nil && code != "" {
+			if time.Since(updated) < productTTL {
+				c.product, c.productSource = code, ProductCached
+				return c.product, c.productSource
+			}
+			cached = code
+		}
+	}
+
+	code, err := c.DiscoverAgileProduct(ctx)
+	switch {
+	case err == nil:
+		c.product, c.productSource = code, ProductDiscovered
+		if c.settings != nil {
+			if err := c.settings.SetSetting(key, code); err != nil {
+				c.logf("remembering Agile product: %v", err)
+			}
+		}
+	case cached != "":
+		c.logf("Agile product discovery failed, using %s: %v", cached, err)
+		c.product, c.productSource = cached, ProductCached
+	default:
+		c.logf("Agile product discovery failed, using %s: %v", fallback, err)
+		c.product, c.productSource = fallback, ProductDefault
+	}
+	return c.product, c.productSource
+}
+
+// DiscoverAgileProduct searches the Octopus product listing for the newest
+// Agile product open to households now: import, or Agile Outgoing for an
+// export client
+func (c *OctopusClient) DiscoverAgileProduct(ctx context.Context) (string, error) {
+	now := time.Now()
+	var best *productItem
+	direction := "IMPORT"
+	if c.export {
+		direction = "EXPORT"
+	}
+
+	next := c.baseURL + "/products/?brand=OCTOPUS_ENERGY&is_business=false"
+	for next != "" {
+		var page productsResponse
+		if err := c.getJSON(ctx, next, &page); err != nil {
+			return "", fmt.Errorf("listing products: %w", err)
+		}
+
+		for i := range page.Results {
+			p := &page.Results[i]
+			if !strings.HasPrefix(p.Code, "AGILE-") || !strings.EqualFold(p.Direction, direction) || p.IsBusiness || p.IsPrepay {
+				continue
+			}
+			if (p.AvailableFrom != nil && p.AvailableFrom.After(now)) || (p.AvailableTo != nil && !p.AvailableTo.After(now)) {
+				continue
+			}
+			if best == nil || (p.AvailableFrom != nil && (best.AvailableFrom == nil || p.AvailableFrom.After(*best.AvailableFrom))) {
+				best = p
+			}
+		}
+
+		next = ""
+		if page.Next != nil {
+			next = *page.Next
+		}
+	}
+
+	if best == nil {
+		return "", fmt.Errorf("no Agile %s product listed", strings.ToLower(direction))
+	}
+	return best.Code, nil
+}
@@ -0,0 +1,124 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// memorySettings is an in-memory ProductSettings
+type memorySettings map[string]string
+
+func (m memorySettings) GetSetting(key string) (string, time.Time, error) {
+	value, ok := m[key]
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("no setting %s", key)
+	}
+	return value, time.Now(), nil
+}
+
+func (m memorySettings) SetSetting(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+// fakeProductsServer lists products over two pages
+func fakeProductsServer(t *testing.T, requests *int) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Path != "/products/" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"next":null,"results":[
+				{"code":"AGILE-24-10-01","direction":"IMPORT","available_from":"2024-10-01T00:00:00Z","available_to":null},
+				{"code":"AGILE-BB-25-01-01","direction":"IMPORT","is_business":true,"available_from":"2025-01-01T00:00:00Z"},
+				{"code":"AGILE-99-01-01","direction":"IMPORT","available_from":"2099-01-01T00:00:00Z"}
+			]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"next":%q,"results":[
+			{"code":"AGILE-OUTGOING-19-05-13","direction":"EXPORT","available_from":"2019-05-13T00:00:00Z"},
+			{"code":"AGILE-23-12-06","direction":"IMPORT","available_from":"2023-12-06T00:00:00Z","available_to":"2024-10-01T00:00:00Z"},
+			{"code":"VAR-22-11-01","direction":"IMPORT","available_from":"2025-11-01T00:00:00Z"}
+		]}`, srv.URL+"/products/?page=2")
+	}))
+	return srv
+}
+
+func TestDiscoverAgileProduct(t *testing.T) {
+	requests := 0
+	srv := fakeProductsServer(t, &requests)
+	defer srv.Close()
+
+	settings := memorySettings{}
+	client := NewOctopusClient("C")
+	client.baseURL = srv.URL
+	client.settings = settings
+
+	code, source := client.Product(context.Background())
+	if code != "AGILE-24-10-01" || source != ProductDiscovered {
+		t.Errorf("got %s (%s), want AGILE-24-10-01 (discovered)", code, source)
+	}
+	if settings[agileProductKey] != "AGILE-24-10-01" {
+		t.Errorf("discovered product not remembered")
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want both pages", requests)
+	}
+
+	// A fresh client finds the remembered product without asking Octopus
+	client = NewOctopusClient("C")
+	client.baseURL = srv.URL
+	client.settings = settings
+	if code, source := client.Product(context.Background()); code != "AGILE-24-10-01" || source != ProductCached {
+		t.Errorf("got %s (%s), want the cached product", code, source)
+	}
+	if requests != 2 {
+		t.Errorf("cached product looked up again")
+	}
+
+	client.PinProduct("AGILE-23-12-06")
+	if code, source := client.Product(context.Background()); code != "AGILE-23-12-06" || source != ProductPinned {
+		t.Errorf("got %s (%s), want the pinned product", code, source)
+	}
+}
+
+func TestDiscoverAgileExportProduct(t *testing.T) {
+	requests := 0
+	srv := fakeProductsServer(t, &requests)
+	defer srv.Close()
+
+	settings := memorySettings{agileProductKey: "AGILE-24-10-01"}
+	client := NewAgileExportClient(&engine.Household{Region: "C", AgileProduct: "AGILE-24-10-01"}, settings)
+	client.baseURL = srv.URL
+
+	// The import product, pinned or remembered, is not used for export
+	code, source := client.Product(context.Background())
+	if code != "AGILE-OUTGOING-19-05-13" || source != ProductDiscovered {
+		t.Errorf("got %s (%s), want AGILE-OUTGOING-19-05-13 (discovered)", code, source)
+	}
+	if settings[agileExportProductKey] != code || settings[agileProductKey] != "AGILE-24-10-01" {
+		t.Errorf("settings = %v, want the export product kept apart from the import one", settings)
+	}
+}
+
+func TestDiscoverAgileProductUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := NewOctopusClient("C")
+	client.baseURL = srv.URL
+	client.retryWait = time.Millisecond
+	if code, source := client.Product(context.Background()); code != defaultAgileProduct || source != ProductDefault {
+		t.Errorf("got %s (%s), want the default product", code, source)
+	}
+}
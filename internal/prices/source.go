@@ -0,0 +1,81 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// PriceSource supplies unit rates for a span of time. Slots come back in time
+// order and cover [from, to) as far as the tariff has published prices.
+type PriceSource interface {
+	Prices(ctx context.Context, from, to time.Time) ([]engine.PriceSlot, error)
+}
+
+// ForHousehold returns the price source for a household's tariff. Sources that
+// are fetched over the network go through cache; tariffs worked out locally
+// are not cached.
+func ForHousehold(h *engine.Household, cache PriceCache) (*CachingProvider, error) {
+	switch h.Tariff {
+	case engine.TariffAgile, "":
+		// A store that caches prices also remembers the discovered product
+		settings, _ := cache.(ProductSettings)
+		client := NewAgileClient(h, settings)
+		return NewCachingProvider(client, cache, client.region), nil
+	case engine.TariffFlat:
+		if h.FlatRatePence <= 0 {
+			return nil, fmt.Errorf("flat tariff needs a flat rate (p/kWh)")
+		}
+		return NewCachingProvider(FlatRate{PencePerKWh: h.FlatRatePence}, nil, ""), nil
+	case engine.TariffTOU:
+		tariff, err := ParseTOUTariff([]byte(h.TariffDefinition))
+		if err != nil {
+			return nil, err
+		}
+		return NewCachingProvider(tariff, nil, ""), nil
+	default:
+		return nil, fmt.Errorf("unknown tariff %q (use agile, flat or tou)", h.Tariff)
+	}
+}
+
+// ExportForHousehold returns the source of what a household is paid for
+// exported energy, or nil when it has no export tariff. Agile Outgoing rates
+// are cached alongside the import prices under the region plus "-export".
+func ExportForHousehold(h *engine.Household, cache PriceCache) (*CachingProvider, error) {
+	switch h.ExportTariff {
+	case "":
+		return nil, nil
+	case engine.TariffAgile:
+		settings, _ := cache.(ProductSettings)
+		client := NewAgileExportClient(h, settings)
+		return NewCachingProvider(client, cache, client.region+"-export"), nil
+	case engine.TariffFlat:
+		if h.ExportRatePence <= 0 {
+			return nil, fmt.Errorf("flat export tariff needs an export rate (p/kWh)")
+		}
+		return NewCachingProvider(FlatRate{PencePerKWh: h.ExportRatePence}, nil, ""), nil
+	default:
+		return nil, fmt.Errorf("unknown export tariff %q (use agile or flat)", h.ExportTariff)
+	}
+}
+
+// FlatRate is a tariff with one unit rate all day
+type FlatRate struct {
+	PencePerKWh float64
+}
+
+// Prices returns half-hour slots at the flat rate covering [from, to)
+func (f FlatRate) Prices(ctx context.Context, from, to time.Time) ([]engine.PriceSlot, error) {
+	slots := []engine.PriceSlot{}
+	for start := from.Truncate(30 * time.Minute); start.Before(to); start = start.Add(30 * time.Minute) {
+		slots = append(slots, engine.PriceSlot{
+			Start:       start,
+			End:         start.Add(30 * time.Minute),
+			PencePerKWh: f.PencePerKWh,
+			IncludesVAT: true,
+		})
+	}
+	return slots, nil
+}
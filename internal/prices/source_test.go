@@ -0,0 +1,82 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+func TestForHousehold(t *testing.T) {
+	from := time.Date(2024, 12, 1, 10, 15, 0, 0, time.UTC)
+
+	source, err := ForHousehold(&engine.Household{Tariff: engine.TariffFlat, FlatRatePence: 24.5}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	slots, err := source.Prices(context.Background(), from, from.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Every half hour the range touches
+	if len(slots) != 5 || slots[0].PencePerKWh != 24.5 || !slots[0].Start.Equal(from.Add(-15*time.Minute)) {
+		t.Errorf("got %d slots starting %v at %.2fp, want 5 from 10:00 at 24.50p", len(slots), slots[0].Start, slots[0].PencePerKWh)
+	}
+
+	if _, err := ForHousehold(&engine.Household{Tariff: engine.TariffFlat}, nil); err == nil {
+		t.Errorf("expected an error for a flat tariff without a rate")
+	}
+	if _, err := ForHousehold(&engine.Household{Tariff: "economy7"}, nil); err == nil {
+		t.Errorf("expected an error for an unknown tariff")
+	}
+	if _, err := ForHousehold(&engine.Household{}, nil); err != nil {
+		t.Errorf("empty tariff should default to agile: %v", err)
+	}
+}
+
+func TestExportForHousehold(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, `{"results":[{"value_inc_vat":15.2,"valid_from":"2024-12-01T00:00:00Z","valid_to":"2024-12-01T00:30:00Z"}]}`)
+	}))
+	defer srv.Close()
+
+	cache := memoryCache{}
+	source, err := ExportForHousehold(&engine.Household{Region: "A", ExportTariff: engine.TariffAgile}, cache)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := source.source.(*OctopusClient)
+	client.baseURL = srv.URL
+	client.PinProduct("AGILE-OUTGOING-19-05-13")
+
+	day := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	slots, _, err := source.HalfHourly(context.Background(), day)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "/products/AGILE-OUTGOING-19-05-13/electricity-tariffs/E-1R-AGILE-OUTGOING-19-05-13-A/standard-unit-rates/"; path != want {
+		t.Errorf("requested %s, want %s", path, want)
+	}
+	if len(slots) != 1 || slots[0].PencePerKWh != 15.2 {
+		t.Errorf("got %v, want one slot at 15.2p", slots)
+	}
+	if cached, _ := cache.GetCachedPrices("A-export", day); len(cached) != 1 {
+		t.Errorf("export prices not cached apart from import prices")
+	}
+
+	if source, err := ExportForHousehold(&engine.Household{}, nil); source != nil || err != nil {
+		t.Errorf("no export tariff should give no source, got %v, %v", source, err)
+	}
+	if _, err := ExportForHousehold(&engine.Household{ExportTariff: engine.TariffFlat}, nil); err == nil {
+		t.Errorf("expected an error for a flat export tariff without a rate")
+	}
+	if _, err := ExportForHousehold(&engine.Household{ExportTariff: engine.TariffTOU}, nil); err == nil {
+		t.Errorf("expected an error for an unsupported export tariff")
+	}
+}
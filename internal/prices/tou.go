@@ -0,0 +1,167 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	_ "time/tzdata" // Tariff bands are in UK time whatever the host's zone database
+
+	"github.com/awaistahir/smart-run/internal/engine"
+	"go.yaml.in/yaml/v3"
+)
+
+// TOUBand is one cheap (or dear) period of a time-of-use tariff. Times are
+// wall-clock HH:mm in the tariff's time zone, so a band keeps its local hours
+// across the clock changes. A band whose end is at or before its start runs
+// past midnight, and belongs to the day it starts on.
+type TOUBand struct {
+	Name  string  `yaml:"name"`
+	Start string  `yaml:"start"`
+	End   string  `yaml:"end"`  // "24:00" or "00:00" for midnight
+	Days  []int   `yaml:"days"` // 1=Monday to 7=Sunday; empty = every day
+	Rate  float64 `yaml:"rate"` // p/kWh including VAT
+}
+
+// TOUTariff is a fixed time-of-use tariff such as Octopus Go or Economy 7:
+// the standard rate applies except during its bands. Where bands overlap the
+// first listed wins.
+type TOUTariff struct {
+	Name     string    `yaml:"name"`
+	Timezone string    `yaml:"timezone"` // IANA zone; empty = Europe/London
+	Rate     float64   `yaml:"rate"`     // Standard unit rate outside every band
+	Bands    []TOUBand `yaml:"bands"`
+
+	location *time.Location
+	bands    []touBand
+}
+
+// touBand is a TOUBand with its times parsed to minutes after midnight
+type touBand struct {
+	start, end int
+	days       []int
+	rate       float64
+}
+
+// ParseTOUTariff reads and checks a YAML tariff definition, for example:
+//
+//	name: Octopus Go
+//	rate: 24.5
+//	bands:
+//	  - name: off-peak
+//	    start: "00:30"
+//	    end: "05:30"
+//	    rate: 8.5
+func ParseTOUTariff(data []byte) (*TOUTariff, error) {
+	var t TOUTariff
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("reading tariff: %w", err)
+	}
+
+	zone := t.Timezone
+	if zone == "" {
+		zone = "Europe/London"
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("tariff time zone: %w", err)
+	}
+	t.location = location
+
+	if t.Rate <= 0 {
+		return nil, fmt.Errorf("tariff needs a standard rate above 0")
+	}
+	for i, b := range t.Bands {
+		name := b.Name
+		if name == "" {
+			name = fmt.Sprintf("band %d", i+1)
+		}
+		start, err := minuteOfDay(b.Start)
+		if err != nil {
+			return nil, fmt.Errorf("%s start: %w", name, err)
+		}
+		end, err := minuteOfDay(b.End)
+		if err != nil {
+			return nil, fmt.Errorf("%s end: %w", name, err)
+		}
+		if start == end%(24*60) {
+			return nil, fmt.Errorf("%s starts and ends at %s", name, b.Start)
+		}
+		if b.Rate < 0 {
+			return nil, fmt.Errorf("%s has a negative rate", name)
+		}
+		for _, d := range b.Days {
+			if d < 1 || d > 7 {
+				return nil, fmt.Errorf("%s: day %d is not 1 (Monday) to 7 (Sunday)", name, d)
+			}
+		}
+		t.bands = append(t.bands, touBand{start: start, end: end, days: b.Days, rate: b.Rate})
+	}
+
+	return &t, nil
+}
+
+// Prices expands the tariff into half-hour slots covering [from, to). Slots
+// follow UTC half hours, so a day the clocks change has 46 or 50 of them.
+func (t *TOUTariff) Prices(ctx context.Context, from, to time.Time) ([]engine.PriceSlot, error) {
+	slots := []engine.PriceSlot{}
+	for start := from.UTC().Truncate(30 * time.Minute); start.Before(to); start = start.Add(30 * time.Minute) {
+		slots = append(slots, engine.PriceSlot{
+			Start:       start,
+			End:         start.Add(30 * time.Minute),
+			PencePerKWh: t.RateAt(start),
+			IncludesVAT: true,
+		})
+	}
+	return slots, nil
+}
+
+// RateAt returns the unit rate in force at a moment
+func (t *TOUTariff) RateAt(at time.Time) float64 {
+	local := at.In(t.location)
+	minute := local.Hour()*60 + local.Minute()
+	day := isoWeekday(local.Weekday())
+	yesterday := (day+5)%7 + 1
+
+	for _, b := range t.bands {
+		if b.start < b.end {
+			if minute >= b.start && minute < b.end && b.onDay(day) {
+				return b.rate
+			}
+			continue
+		}
+		// Runs past midnight: the early hours belong to the previous day's band
+		if minute >= b.start && b.onDay(day) {
+			return b.rate
+		}
+		if minute < b.end && b.onDay(yesterday) {
+			return b.rate
+		}
+	}
+	return t.Rate
+}
+
+// onDay reports whether a band applies on an ISO weekday
+func (b touBand) onDay(day int) bool {
+	return len(b.days) == 0 || slices.Contains(b.days, day)
+}
+
+// isoWeekday numbers days from 1 (Monday) to 7 (Sunday)
+func isoWeekday(d time.Weekday) int {
+	if d == time.Sunday {
+		return 7
+	}
+	return int(d)
+}
+
+// minuteOfDay parses HH:mm, allowing 24:00 for midnight at the end of a day
+func minuteOfDay(hhmm string) (int, error) {
+	if hhmm == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:mm", hhmm)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,124 @@
+package prices
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testTOUTariff = `
+name: Test
+rate: 25
+bands:
+  - name: night
+    start: "23:30"
+    end: "05:30"
+    rate: 7.5
+  - name: weekend
+    start: "12:00"
+    end: "16:00"
+    days: [6, 7]
+    rate: 10
+`
+
+func TestTOUTariffRates(t *testing.T) {
+	tariff, err := ParseTOUTariff([]byte(testTOUTariff))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	london, _ := time.LoadLocation("Europe/London")
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{name: "before the night band", at: time.Date(2026, 1, 5, 23, 0, 0, 0, london), want: 25},
+		{name: "night band before midnight", at: time.Date(2026, 1, 5, 23, 30, 0, 0, london), want: 7.5},
+		{name: "night band after midnight", at: time.Date(2026, 1, 6, 5, 0, 0, 0, london), want: 7.5},
+		{name: "night band ends", at: time.Date(2026, 1, 6, 5, 30, 0, 0, london), want: 25},
+		{name: "weekday afternoon", at: time.Date(2026, 1, 9, 13, 0, 0, 0, london), want: 25},
+		{name: "Saturday afternoon", at: time.Date(2026, 1, 10, 13, 0, 0, 0, london), want: 10},
+		{name: "summer night band in local time", at: time.Date(2026, 7, 1, 4, 0, 0, 0, time.UTC), want: 7.5},
+		{name: "summer band end in local time", at: time.Date(2026, 7, 1, 4, 30, 0, 0, time.UTC), want: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tariff.RateAt(tt.at); got != tt.want {
+				t.Errorf("rate at %s = %.2f, want %.2f", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTOUTariffClockChange(t *testing.T) {
+	tariff, err := ParseTOUTariff([]byte(testTOUTariff))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	london, _ := time.LoadLocation("Europe/London")
+
+	tests := []struct {
+		name      string
+		day       time.Time
+		wantSlots int
+		wantNight int
+	}{
+		// The night band covers 23:30-05:30 wall clock: 00:00-05:30 plus 23:30-24:00
+		{name: "clocks go forward", day: time.Date(2026, 3, 29, 0, 0, 0, 0, london), wantSlots: 46, wantNight: 10},
+		{name: "clocks go back", day: time.Date(2026, 10, 25, 0, 0, 0, 0, london), wantSlots: 50, wantNight: 14},
+		{name: "ordinary day", day: time.Date(2026, 6, 1, 0, 0, 0, 0, london), wantSlots: 48, wantNight: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots, err := tariff.Prices(context.Background(), tt.day, tt.day.AddDate(0, 0, 1))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			night := 0
+			for _, slot := range slots {
+				if slot.PencePerKWh == 7.5 {
+					night++
+				}
+			}
+			if len(slots) != tt.wantSlots || night != tt.wantNight {
+				t.Errorf("got %d slots, %d at the night rate; want %d and %d", len(slots), night, tt.wantSlots, tt.wantNight)
+			}
+		})
+	}
+}
+
+func TestParseTOUTariffInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no standard rate": "bands: []",
+		"bad time":         "rate: 25\nbands:\n  - start: \"25:00\"\n    end: \"05:00\"\n    rate: 7",
+		"empty band":       "rate: 25\nbands:\n  - start: \"05:00\"\n    end: \"05:00\"\n    rate: 7",
+		"bad day":          "rate: 25\nbands:\n  - start: \"01:00\"\n    end: \"05:00\"\n    days: [0]\n    rate: 7",
+		"unknown zone":     "rate: 25\ntimezone: Mars/Olympus",
+	}
+	for name, definition := range tests {
+		if _, err := ParseTOUTariff([]byte(definition)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExampleTariffs(t *testing.T) {
+	files, _ := filepath.Glob("../../tariffs/*.yaml")
+	if len(files) == 0 {
+		t.Fatalf("no example tariffs found")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := ParseTOUTariff(data); err != nil {
+			t.Errorf("%s: %v", filepath.Base(file), err)
+		}
+	}
+}
@@ -43,12 +43,33 @@ func (s *Store) initialize() error {
 		id TEXT PRIMARY KEY,
 		name TEXT NOT NULL,
 		region TEXT DEFAULT 'C',
+		tariff TEXT DEFAULT 'agile',
+		tariff_definition TEXT,
+		agile_product TEXT DEFAULT '',
 		latitude REAL DEFAULT 51.5074,
 		longitude REAL DEFAULT -0.1278,
 		quiet_hours TEXT,
 		blocked_windows TEXT,
 		stagger_heavy_loads INTEGER DEFAULT 0,
+		stagger_gap_minutes INTEGER DEFAULT 30,
 		carbon_weight REAL DEFAULT 0.0,
+		max_import_kw REAL DEFAULT 0.0,
+		pv_kwp REAL DEFAULT 0.0,
+		pv_tilt REAL DEFAULT 35.0,
+		pv_azimuth REAL DEFAULT 180.0,
+		pv_weight REAL DEFAULT 1.0,
+		start_step_minutes INTEGER DEFAULT 30,
+		battery_kwh REAL DEFAULT 0.0,
+		battery_charge_kw REAL DEFAULT 0.0,
+		battery_discharge_kw REAL DEFAULT 0.0,
+		battery_efficiency REAL DEFAULT 0.9,
+		battery_reserve REAL DEFAULT 10.0,
+		base_load TEXT,
+		late_penalty_pence REAL DEFAULT 0.5,
+		flat_rate_pence REAL DEFAULT 0.0,
+		price_cap_pence REAL DEFAULT 26.35,
+		export_tariff TEXT DEFAULT '',
+		export_rate_pence REAL DEFAULT 0.0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -73,6 +94,15 @@ func (s *Store) initialize() error {
 		class TEXT DEFAULT 'standalone',
 		coupled_appliance_id TEXT,
 		can_wait_days INTEGER DEFAULT 0,
+		rated_kw REAL DEFAULT 0.0,
+		power_profile TEXT,
+		interruptible INTEGER DEFAULT 0,
+		min_block_minutes INTEGER DEFAULT 0,
+		max_interruptions INTEGER DEFAULT 0,
+		battery_kwh REAL DEFAULT 0.0,
+		soc_percent REAL DEFAULT 0.0,
+		target_soc_percent REAL DEFAULT 80.0,
+		ready_by TEXT DEFAULT '07:00',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (household_id) REFERENCES households(id)
@@ -97,41 +127,185 @@ func (s *Store) initialize() error {
 		UNIQUE(latitude, longitude, date)
 	);
 
+	CREATE TABLE IF NOT EXISTS runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		appliance_id TEXT NOT NULL,
+		started_at TEXT NOT NULL,
+		ended_at TEXT,
+		kwh REAL DEFAULT 0.0,
+		kwh_measured INTEGER DEFAULT 0,
+		recommended_start TEXT,
+		recommended_cost_gbp REAL DEFAULT 0.0,
+		cost_gbp REAL DEFAULT 0.0,
+		costed INTEGER DEFAULT 0,
+		loaded_at TEXT,
+		immediate_cost_gbp REAL DEFAULT 0.0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (appliance_id) REFERENCES appliances(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS consumption (
+		mpan TEXT NOT NULL,
+		interval_start TEXT NOT NULL,
+		interval_end TEXT NOT NULL,
+		kwh REAL NOT NULL,
+		PRIMARY KEY (mpan, interval_start)
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_appliances_household ON appliances(household_id);
 	CREATE INDEX IF NOT EXISTS idx_price_cache_date ON price_cache(region, date);
 	CREATE INDEX IF NOT EXISTS idx_weather_cache_date ON weather_cache(latitude, longitude, date);
+	CREATE INDEX IF NOT EXISTS idx_runs_appliance ON runs(appliance_id, started_at);
 	`
 
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	return s.migrate()
+}
+
+// columnMigration describes a column added after a table was first created
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+}
+
+// migrations lists columns that older databases may be missing
+var migrations = []columnMigration{
+	{"households", "max_import_kw", "REAL DEFAULT 0.0"},
+	{"households", "stagger_gap_minutes", "INTEGER DEFAULT 30"},
+	{"appliances", "rated_kw", "REAL DEFAULT 0.0"},
+	{"households", "pv_kwp", "REAL DEFAULT 0.0"},
+	{"households", "pv_tilt", "REAL DEFAULT 35.0"},
+	{"households", "pv_azimuth", "REAL DEFAULT 180.0"},
+	{"households", "pv_weight", "REAL DEFAULT 1.0"},
+	{"appliances", "power_profile", "TEXT"},
+	{"households", "start_step_minutes", "INTEGER DEFAULT 30"},
+	{"appliances", "interruptible", "INTEGER DEFAULT 0"},
+	{"appliances", "min_block_minutes", "INTEGER DEFAULT 0"},
+	{"appliances", "max_interruptions", "INTEGER DEFAULT 0"},
+	{"appliances", "battery_kwh", "REAL DEFAULT 0.0"},
+	{"appliances", "soc_percent", "REAL DEFAULT 0.0"},
+	{"appliances", "target_soc_percent", "REAL DEFAULT 80.0"},
+	{"appliances", "ready_by", "TEXT DEFAULT '07:00'"},
+	{"households", "battery_kwh", "REAL DEFAULT 0.0"},
+	{"households", "battery_charge_kw", "REAL DEFAULT 0.0"},
+	{"households", "battery_discharge_kw", "REAL DEFAULT 0.0"},
+	{"households", "battery_efficiency", "REAL DEFAULT 0.9"},
+	{"households", "battery_reserve", "REAL DEFAULT 10.0"},
+	{"households", "base_load", "TEXT"},
+	{"households", "late_penalty_pence", "REAL DEFAULT 0.5"},
+	{"households", "flat_rate_pence", "REAL DEFAULT 0.0"},
+	{"households", "price_cap_pence", "REAL DEFAULT 26.35"},
+	{"households", "tariff", "TEXT DEFAULT 'agile'"},
+	{"households", "tariff_definition", "TEXT"},
+	{"households", "agile_product", "TEXT DEFAULT ''"},
+	{"households", "export_tariff", "TEXT DEFAULT ''"},
+	{"households", "export_rate_pence", "REAL DEFAULT 0.0"},
+	{"runs", "ended_at", "TEXT"},
+	{"runs", "kwh", "REAL DEFAULT 0.0"},
+	{"runs", "kwh_measured", "INTEGER DEFAULT 0"},
+	{"runs", "recommended_start", "TEXT"},
+	{"runs", "recommended_cost_gbp", "REAL DEFAULT 0.0"},
+	{"runs", "cost_gbp", "REAL DEFAULT 0.0"},
+	{"runs", "costed", "INTEGER DEFAULT 0"},
+	{"runs", "loaded_at", "TEXT"},
+	{"runs", "immediate_cost_gbp", "REAL DEFAULT 0.0"},
+}
+
+// migrate adds any missing columns to databases created by earlier versions
+func (s *Store) migrate() error {
+	for _, m := range migrations {
+		exists, err := s.columnExists(m.table, m.column)
+		if err != nil {
+			return fmt.Errorf("checking %s.%s: %w", m.table, m.column, err)
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := s.db.Exec(query); err != nil {
+			return fmt.Errorf("adding %s.%s: %w", m.table, m.column, err)
+		}
+	}
+	return nil
+}
+
+// columnExists reports whether a table has the named column
+func (s *Store) columnExists(table, column string) (bool, error) {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
 
 // SaveHousehold saves or updates a household
 func (s *Store) SaveHousehold(h *engine.Household) error {
 	quietHoursJSON, _ := json.Marshal(h.QuietHours)
 	blockedWindowsJSON, _ := json.Marshal(h.BlockedWindows)
+	baseLoadJSON, _ := json.Marshal(h.BaseLoadKW)
 
 	query := `INSERT OR REPLACE INTO households
-		(id, name, region, latitude, longitude, quiet_hours, blocked_windows, stagger_heavy_loads, carbon_weight, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		(id, name, region, latitude, longitude, quiet_hours, blocked_windows, stagger_heavy_loads, carbon_weight,
+		 max_import_kw, stagger_gap_minutes, pv_kwp, pv_tilt, pv_azimuth, pv_weight,
+		 start_step_minutes, battery_kwh, battery_charge_kw, battery_discharge_kw, battery_efficiency,
+		 battery_reserve, base_load, late_penalty_pence, flat_rate_pence, price_cap_pence, tariff, tariff_definition, agile_product,
+		 export_tariff, export_rate_pence, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err := s.db.Exec(query, h.ID, h.Name, h.Region, h.Latitude, h.Longitude, string(quietHoursJSON), string(blockedWindowsJSON),
-		boolToInt(h.StaggerHeavyLoads), h.CarbonWeight, time.Now())
+		boolToInt(h.StaggerHeavyLoads), h.CarbonWeight, h.MaxImportKW, h.StaggerGapMinutes,
+		h.PVKWp, h.PVTiltDeg, h.PVAzimuthDeg, h.PVWeight, h.StartStepMinutes,
+		h.BatteryKWh, h.BatteryChargeKW, h.BatteryDischargeKW, h.BatteryEfficiency,
+		h.BatteryReservePercent, string(baseLoadJSON), h.LatePenaltyPence, h.FlatRatePence, h.PriceCapPence, string(h.Tariff), h.TariffDefinition, h.AgileProduct,
+		string(h.ExportTariff), h.ExportRatePence, time.Now())
 
 	return err
 }
 
 // GetHousehold retrieves a household by ID
 func (s *Store) GetHousehold(id string) (*engine.Household, error) {
-	query := `SELECT id, name, region, latitude, longitude, quiet_hours, blocked_windows, stagger_heavy_loads, carbon_weight
+	query := `SELECT id, name, region, latitude, longitude, quiet_hours, blocked_windows, stagger_heavy_loads, carbon_weight,
+		max_import_kw, stagger_gap_minutes, pv_kwp, pv_tilt, pv_azimuth, pv_weight,
+		start_step_minutes, battery_kwh, battery_charge_kw, battery_discharge_kw, battery_efficiency,
+		battery_reserve, base_load, late_penalty_pence, flat_rate_pence, price_cap_pence, tariff, tariff_definition, agile_product,
+		export_tariff, export_rate_pence
 		FROM households WHERE id = ?`
 
 	var h engine.Household
 	var quietHoursJSON, blockedWindowsJSON string
 	var staggerInt int
+	var baseLoadJSON, tariff, tariffDefinition, agileProduct, exportTariff sql.NullString
 
 	err := s.db.QueryRow(query, id).Scan(&h.ID, &h.Name, &h.Region, &h.Latitude, &h.Longitude, &quietHoursJSON, &blockedWindowsJSON,
-		&staggerInt, &h.CarbonWeight)
+		&staggerInt, &h.CarbonWeight, &h.MaxImportKW, &h.StaggerGapMinutes,
+		&h.PVKWp, &h.PVTiltDeg, &h.PVAzimuthDeg, &h.PVWeight,
+		&h.StartStepMinutes, &h.BatteryKWh, &h.BatteryChargeKW, &h.BatteryDischargeKW, &h.BatteryEfficiency,
+		&h.BatteryReservePercent, &baseLoadJSON, &h.LatePenaltyPence, &h.FlatRatePence, &h.PriceCapPence, &tariff, &tariffDefinition, &agileProduct,
+		&exportTariff, &h.ExportRatePence)
 
 	if err != nil {
 		return nil, err
@@ -139,7 +313,14 @@ func (s *Store) GetHousehold(id string) (*engine.Household, error) {
 
 	json.Unmarshal([]byte(quietHoursJSON), &h.QuietHours)
 	json.Unmarshal([]byte(blockedWindowsJSON), &h.BlockedWindows)
+	if baseLoadJSON.Valid {
+		json.Unmarshal([]byte(baseLoadJSON.String), &h.BaseLoadKW)
+	}
 	h.StaggerHeavyLoads = staggerInt == 1
+	h.Tariff = engine.TariffType(tariff.String)
+	h.TariffDefinition = tariffDefinition.String
+	h.AgileProduct = agileProduct.String
+	h.ExportTariff = engine.TariffType(exportTariff.String)
 
 	return &h, nil
 }
@@ -148,6 +329,7 @@ func (s *Store) GetHousehold(id string) (*engine.Household, error) {
 func (s *Store) SaveAppliance(a *engine.Appliance, householdID string) error {
 	allowedJSON, _ := json.Marshal(a.AllowedWindows)
 	blockedJSON, _ := json.Marshal(a.BlockedWindows)
+	profileJSON, _ := json.Marshal(a.PowerProfile)
 
 	var finishByStr, startByStr sql.NullString
 	if a.FinishBy != nil {
@@ -179,13 +361,17 @@ func (s *Store) SaveAppliance(a *engine.Appliance, householdID string) error {
 	query := `INSERT OR REPLACE INTO appliances
 		(id, household_id, name, cycle_minutes, tolerance_minutes, allowed_windows, blocked_windows,
 		 finish_by, start_by, noise_level, price_cap_pence, priority, est_kwh, enabled,
-		 control_type, usage_frequency, class, coupled_appliance_id, can_wait_days, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		 control_type, usage_frequency, class, coupled_appliance_id, can_wait_days, rated_kw, power_profile,
+		 interruptible, min_block_minutes, max_interruptions,
+		 battery_kwh, soc_percent, target_soc_percent, ready_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err := s.db.Exec(query, a.ID, householdID, a.Name, a.CycleMinutes, a.ToleranceMinutes,
 		string(allowedJSON), string(blockedJSON), finishByStr, startByStr, a.NoiseLevel,
 		priceCap, a.Priority, a.EstKWh, boolToInt(a.Enabled), controlType, usageFrequency,
-		class, a.CoupledApplianceID, a.CanWaitDays, time.Now())
+		class, a.CoupledApplianceID, a.CanWaitDays, a.RatedKW, string(profileJSON),
+		boolToInt(a.Interruptible), a.MinBlockMinutes, a.MaxInterruptions,
+		a.BatteryKWh, a.SoCPercent, a.TargetSoCPercent, a.ReadyBy, time.Now())
 
 	return err
 }
@@ -194,7 +380,9 @@ func (s *Store) SaveAppliance(a *engine.Appliance, householdID string) error {
 func (s *Store) GetAppliances(householdID string) ([]*engine.Appliance, error) {
 	query := `SELECT id, name, cycle_minutes, tolerance_minutes, allowed_windows, blocked_windows,
 		finish_by, start_by, noise_level, price_cap_pence, priority, est_kwh, enabled,
-		control_type, usage_frequency, class, coupled_appliance_id, can_wait_days
+		control_type, usage_frequency, class, coupled_appliance_id, can_wait_days, rated_kw, power_profile,
+		interruptible, min_block_minutes, max_interruptions,
+		battery_kwh, soc_percent, target_soc_percent, ready_by
 		FROM appliances WHERE household_id = ? ORDER BY priority DESC, name`
 
 	rows, err := s.db.Query(query, householdID)
@@ -213,10 +401,15 @@ func (s *Store) GetAppliances(householdID string) ([]*engine.Appliance, error) {
 		var controlType, usageFrequency, class string
 		var coupledApplianceID sql.NullString
 		var canWaitDays int
+		var profileJSON sql.NullString
+		var interruptibleInt int
+		var readyBy sql.NullString
 
 		err := rows.Scan(&a.ID, &a.Name, &a.CycleMinutes, &a.ToleranceMinutes, &allowedJSON, &blockedJSON,
 			&finishByStr, &startByStr, &a.NoiseLevel, &priceCap, &a.Priority, &a.EstKWh, &enabledInt,
-			&controlType, &usageFrequency, &class, &coupledApplianceID, &canWaitDays)
+			&controlType, &usageFrequency, &class, &coupledApplianceID, &canWaitDays, &a.RatedKW, &profileJSON,
+			&interruptibleInt, &a.MinBlockMinutes, &a.MaxInterruptions,
+			&a.BatteryKWh, &a.SoCPercent, &a.TargetSoCPercent, &readyBy)
 
 		if err != nil {
 			continue
@@ -224,6 +417,9 @@ func (s *Store) GetAppliances(householdID string) ([]*engine.Appliance, error) {
 
 		json.Unmarshal([]byte(allowedJSON), &a.AllowedWindows)
 		json.Unmarshal([]byte(blockedJSON), &a.BlockedWindows)
+		if profileJSON.Valid {
+			json.Unmarshal([]byte(profileJSON.String), &a.PowerProfile)
+		}
 		a.ControlType = engine.ControlType(controlType)
 		a.UsageFrequency = engine.UsageFrequency(usageFrequency)
 		a.Class = engine.ApplianceClass(class)
@@ -231,6 +427,8 @@ func (s *Store) GetAppliances(householdID string) ([]*engine.Appliance, error) {
 			a.CoupledApplianceID = coupledApplianceID.String
 		}
 		a.CanWaitDays = canWaitDays
+		a.Interruptible = interruptibleInt == 1
+		a.ReadyBy = readyBy.String
 
 		if finishByStr.Valid {
 			t, _ := time.Parse(time.RFC3339, finishByStr.String)
@@ -251,6 +449,25 @@ func (s *Store) GetAppliances(householdID string) ([]*engine.Appliance, error) {
 	return appliances, nil
 }
 
+// SetSetting remembers a value under key, replacing any earlier one
+func (s *Store) SetSetting(key, value string) error {
+	query := `INSERT OR REPLACE INTO settings (key, value, updated_at) VALUES (?, ?, ?)`
+	_, err := s.db.Exec(query, key, value, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetSetting returns the value stored under key and when it was set; a
+// missing key returns sql.ErrNoRows
+func (s *Store) GetSetting(key string) (string, time.Time, error) {
+	var value, updatedAt string
+	err := s.db.QueryRow(`SELECT value, updated_at FROM settings WHERE key = ?`, key).Scan(&value, &updatedAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	updated, _ := time.Parse(time.RFC3339, updatedAt)
+	return value, updated, nil
+}
+
 // CachePrices stores fetched prices
 func (s *Store) CachePrices(region string, date time.Time, slots []engine.PriceSlot) error {
 	slotsJSON, _ := json.Marshal(slots)
@@ -282,6 +499,283 @@ func (s *Store) GetCachedPrices(region string, date time.Time) ([]engine.PriceSl
 	return slots, nil
 }
 
+// CacheForecast stores a daily forecast for a location, one row per day
+func (s *Store) CacheForecast(lat, lon float64, forecasts []engine.WeatherForecast) error {
+	query := `INSERT OR REPLACE INTO weather_cache (latitude, longitude, date, slots, fetched_at)
+		VALUES (?, ?, ?, ?, ?)`
+
+	fetchedAt := time.Now().UTC().Format(time.RFC3339)
+	for _, f := range forecasts {
+		forecastJSON, _ := json.Marshal(f)
+		if _, err := s.db.Exec(query, lat, lon, f.Date.Format("2006-01-02"), string(forecastJSON), fetchedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCachedForecast returns the cached daily forecasts for a location from the
+// from date onwards, in date order, and when the oldest of them was fetched
+func (s *Store) GetCachedForecast(lat, lon float64, from time.Time, days int) ([]engine.WeatherForecast, time.Time, error) {
+	query := `SELECT slots, fetched_at FROM weather_cache
+		WHERE latitude = ? AND longitude = ? AND date >= ? ORDER BY date LIMIT ?`
+
+	rows, err := s.db.Query(query, lat, lon, from.Format("2006-01-02"), days)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	forecasts := []engine.WeatherForecast{}
+	var oldest time.Time
+	for rows.Next() {
+		var forecastJSON, fetchedAt string
+		if err := rows.Scan(&forecastJSON, &fetchedAt); err != nil {
+			return nil, time.Time{}, err
+		}
+		var f engine.WeatherForecast
+		if err := json.Unmarshal([]byte(forecastJSON), &f); err != nil {
+			return nil, time.Time{}, err
+		}
+		forecasts = append(forecasts, f)
+
+		if t, err := time.Parse(time.RFC3339, fetchedAt); err == nil && (oldest.IsZero() || t.Before(oldest)) {
+			oldest = t
+		}
+	}
+
+	return forecasts, oldest, rows.Err()
+}
+
+// GetCachedPricesBetween returns every cached slot for a region starting in
+// [from, to), in time order; days missing from the cache are simply absent
+func (s *Store) GetCachedPricesBetween(region string, from, to time.Time) ([]engine.PriceSlot, error) {
+	// Cached days are keyed by UTC date
+	query := `SELECT slots FROM price_cache WHERE region = ? AND date >= ? AND date <= ? ORDER BY date`
+
+	rows, err := s.db.Query(query, region, from.UTC().Format("2006-01-02"), to.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := []engine.PriceSlot{}
+	for rows.Next() {
+		var slotsJSON string
+		if err := rows.Scan(&slotsJSON); err != nil {
+			return nil, err
+		}
+		var day []engine.PriceSlot
+		if err := json.Unmarshal([]byte(slotsJSON), &day); err != nil {
+			return nil, err
+		}
+		for _, slot := range day {
+			if !slot.Start.Before(from) && slot.Start.Before(to) {
+				slots = append(slots, slot)
+			}
+		}
+	}
+
+	return slots, rows.Err()
+}
+
+// SaveConsumption stores half-hourly meter readings for an MPAN, replacing any
+// already held for the same half hours
+func (s *Store) SaveConsumption(mpan string, slots []engine.ConsumptionSlot) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT OR REPLACE INTO consumption (mpan, interval_start, interval_end, kwh) VALUES (?, ?, ?, ?)`
+	for _, slot := range slots {
+		if _, err := tx.Exec(query, mpan, slot.Start.UTC().Format(time.RFC3339), slot.End.UTC().Format(time.RFC3339), slot.KWh); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetConsumption returns the readings for an MPAN starting in [from, to), in
+// time order
+func (s *Store) GetConsumption(mpan string, from, to time.Time) ([]engine.ConsumptionSlot, error) {
+	query := `SELECT interval_start, interval_end, kwh FROM consumption
+		WHERE mpan = ? AND interval_start >= ? AND interval_start < ? ORDER BY interval_start`
+
+	rows, err := s.db.Query(query, mpan, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := []engine.ConsumptionSlot{}
+	for rows.Next() {
+		var start, end string
+		var slot engine.ConsumptionSlot
+		if err := rows.Scan(&start, &end, &slot.KWh); err != nil {
+			return nil, err
+		}
+		slot.Start, _ = time.Parse(time.RFC3339, start)
+		slot.End, _ = time.Parse(time.RFC3339, end)
+		slots = append(slots, slot)
+	}
+
+	return slots, rows.Err()
+}
+
+// LatestConsumption returns the end of the last reading held for an MPAN, or
+// the zero time when there are none
+func (s *Store) LatestConsumption(mpan string) (time.Time, error) {
+	var end sql.NullString
+	err := s.db.QueryRow(`SELECT MAX(interval_end) FROM consumption WHERE mpan = ?`, mpan).Scan(&end)
+	if err != nil || !end.Valid {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, end.String)
+}
+
+// runColumns is the column list shared by the run queries
+const runColumns = `id, appliance_id, started_at, ended_at, kwh, kwh_measured,
+	recommended_start, recommended_cost_gbp, cost_gbp, costed, loaded_at, immediate_cost_gbp`
+
+// SaveRun inserts a new run, setting its ID, or updates an existing one
+func (s *Store) SaveRun(run *engine.Run) error {
+	var endedAt, recommendedStart, loadedAt sql.NullString
+	if run.End != nil {
+		endedAt = sql.NullString{String: run.End.UTC().Format(time.RFC3339), Valid: true}
+	}
+	if run.RecommendedStart != nil {
+		recommendedStart = sql.NullString{String: run.RecommendedStart.UTC().Format(time.RFC3339), Valid: true}
+	}
+	if run.LoadedAt != nil {
+		loadedAt = sql.NullString{String: run.LoadedAt.UTC().Format(time.RFC3339), Valid: true}
+	}
+	args := []interface{}{run.ApplianceID, run.Start.UTC().Format(time.RFC3339), endedAt, run.KWh,
+		boolToInt(run.Measured), recommendedStart, run.RecommendedCostGBP, run.CostGBP, boolToInt(run.Costed),
+		loadedAt, run.ImmediateCostGBP}
+
+	if run.ID != 0 {
+		query := `UPDATE runs SET appliance_id = ?, started_at = ?, ended_at = ?, kwh = ?, kwh_measured = ?,
+			recommended_start = ?, recommended_cost_gbp = ?, cost_gbp = ?, costed = ?,
+			loaded_at = ?, immediate_cost_gbp = ? WHERE id = ?`
+		_, err := s.db.Exec(query, append(args, run.ID)...)
+		return err
+	}
+
+	query := `INSERT INTO runs (appliance_id, started_at, ended_at, kwh, kwh_measured,
+		recommended_start, recommended_cost_gbp, cost_gbp, costed, loaded_at, immediate_cost_gbp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetRun retrieves a single run by ID
+func (s *Store) GetRun(id int64) (*engine.Run, error) {
+	query := `SELECT ` + runColumns + ` FROM runs WHERE id = ?`
+	return scanRun(s.db.QueryRow(query, id))
+}
+
+// GetOpenRun returns the most recent unfinished run of an appliance
+func (s *Store) GetOpenRun(applianceID string) (*engine.Run, error) {
+	query := `SELECT ` + runColumns + ` FROM runs
+		WHERE appliance_id = ? AND ended_at IS NULL ORDER BY started_at DESC LIMIT 1`
+	return scanRun(s.db.QueryRow(query, applianceID))
+}
+
+// GetRuns returns runs started since the given time, newest first; an empty
+// applianceID returns runs of every appliance
+func (s *Store) GetRuns(applianceID string, since time.Time) ([]*engine.Run, error) {
+	query := `SELECT ` + runColumns + ` FROM runs
+		WHERE started_at >= ? AND (? = '' OR appliance_id = ?) ORDER BY started_at DESC`
+
+	rows, err := s.db.Query(query, since.UTC().Format(time.RFC3339), applianceID, applianceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*engine.Run{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRun reads one row selected with runColumns
+func scanRun(row rowScanner) (*engine.Run, error) {
+	var run engine.Run
+	var startedAt string
+	var endedAt, recommendedStart, loadedAt sql.NullString
+	var measuredInt, costedInt int
+
+	err := row.Scan(&run.ID, &run.ApplianceID, &startedAt, &endedAt, &run.KWh, &measuredInt,
+		&recommendedStart, &run.RecommendedCostGBP, &run.CostGBP, &costedInt,
+		&loadedAt, &run.ImmediateCostGBP)
+	if err != nil {
+		return nil, err
+	}
+
+	run.Start, _ = time.Parse(time.RFC3339, startedAt)
+	if endedAt.Valid {
+		t, _ := time.Parse(time.RFC3339, endedAt.String)
+		run.End = &t
+	}
+	if recommendedStart.Valid {
+		t, _ := time.Parse(time.RFC3339, recommendedStart.String)
+		run.RecommendedStart = &t
+	}
+	if loadedAt.Valid {
+		t, _ := time.Parse(time.RFC3339, loadedAt.String)
+		run.LoadedAt = &t
+	}
+	run.Measured = measuredInt == 1
+	run.Costed = costedInt == 1
+
+	return &run, nil
+}
+
+// GetRunHistory returns when an appliance has started runs since the given
+// time, oldest first
+func (s *Store) GetRunHistory(applianceID string, since time.Time) ([]time.Time, error) {
+	query := `SELECT started_at FROM runs WHERE appliance_id = ? AND started_at >= ? ORDER BY started_at`
+
+	rows, err := s.db.Query(query, applianceID, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []time.Time{}
+	for rows.Next() {
+		var startedAt string
+		if err := rows.Scan(&startedAt); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, startedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, t)
+	}
+
+	return history, rows.Err()
+}
+
 // DeleteAppliance deletes an appliance by ID
 func (s *Store) DeleteAppliance(id string) error {
 	query := `DELETE FROM appliances WHERE id = ?`
@@ -293,7 +787,9 @@ func (s *Store) DeleteAppliance(id string) error {
 func (s *Store) GetAppliance(id string) (*engine.Appliance, error) {
 	query := `SELECT id, household_id, name, cycle_minutes, tolerance_minutes, allowed_windows, blocked_windows,
 		finish_by, start_by, noise_level, price_cap_pence, priority, est_kwh, enabled,
-		control_type, usage_frequency, class, coupled_appliance_id, can_wait_days
+		control_type, usage_frequency, class, coupled_appliance_id, can_wait_days, rated_kw, power_profile,
+		interruptible, min_block_minutes, max_interruptions,
+		battery_kwh, soc_percent, target_soc_percent, ready_by
 		FROM appliances WHERE id = ?`
 
 	var a engine.Appliance
@@ -305,10 +801,15 @@ func (s *Store) GetAppliance(id string) (*engine.Appliance, error) {
 	var controlType, usageFrequency, class string
 	var coupledApplianceID sql.NullString
 	var canWaitDays int
+	var profileJSON sql.NullString
+	var interruptibleInt int
+	var readyBy sql.NullString
 
 	err := s.db.QueryRow(query, id).Scan(&a.ID, &householdID, &a.Name, &a.CycleMinutes, &a.ToleranceMinutes,
 		&allowedJSON, &blockedJSON, &finishByStr, &startByStr, &a.NoiseLevel, &priceCap, &a.Priority,
-		&a.EstKWh, &enabledInt, &controlType, &usageFrequency, &class, &coupledApplianceID, &canWaitDays)
+		&a.EstKWh, &enabledInt, &controlType, &usageFrequency, &class, &coupledApplianceID, &canWaitDays, &a.RatedKW, &profileJSON,
+		&interruptibleInt, &a.MinBlockMinutes, &a.MaxInterruptions,
+		&a.BatteryKWh, &a.SoCPercent, &a.TargetSoCPercent, &readyBy)
 
 	if err != nil {
 		return nil, err
@@ -316,6 +817,9 @@ func (s *Store) GetAppliance(id string) (*engine.Appliance, error) {
 
 	json.Unmarshal([]byte(allowedJSON), &a.AllowedWindows)
 	json.Unmarshal([]byte(blockedJSON), &a.BlockedWindows)
+	if profileJSON.Valid {
+		json.Unmarshal([]byte(profileJSON.String), &a.PowerProfile)
+	}
 	a.ControlType = engine.ControlType(controlType)
 	a.UsageFrequency = engine.UsageFrequency(usageFrequency)
 	a.Class = engine.ApplianceClass(class)
@@ -323,6 +827,8 @@ func (s *Store) GetAppliance(id string) (*engine.Appliance, error) {
 		a.CoupledApplianceID = coupledApplianceID.String
 	}
 	a.CanWaitDays = canWaitDays
+	a.Interruptible = interruptibleInt == 1
+	a.ReadyBy = readyBy.String
 
 	if finishByStr.Valid {
 		t, _ := time.Parse(time.RFC3339, finishByStr.String)
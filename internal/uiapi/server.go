@@ -3,9 +3,15 @@ package uiapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/awaistahir/smart-run/internal/carbon"
 	"github.com/awaistahir/smart-run/internal/engine"
 	"github.com/awaistahir/smart-run/internal/prices"
 	"github.com/awaistahir/smart-run/internal/store"
@@ -15,7 +21,8 @@ import (
 )
 
 type Server struct {
-	store *store.Store
+	store      *store.Store
+	weatherTTL time.Duration
 }
 
 func NewServer(store *store.Store) *Server {
@@ -24,13 +31,150 @@ func NewServer(store *store.Store) *Server {
 	}
 }
 
-// getRegion retrieves the region from household settings
-func (s *Server) getRegion() string {
+// SetWeatherTTL sets how long cached weather forecasts are used before they
+// are refreshed from Open-Meteo
+func (s *Server) SetWeatherTTL(ttl time.Duration) {
+	s.weatherTTL = ttl
+}
+
+// priceSource returns the price source for the household's tariff, cached in
+// the store
+func (s *Server) priceSource() (*prices.CachingProvider, error) {
 	household, err := s.store.GetHousehold("default")
-	if err != nil || household.Region == "" {
-		return "C" // Default to London if not set
+	if err != nil {
+		household = &engine.Household{Region: "C"}
+	}
+	source, err := prices.ForHousehold(household, s.store)
+	if err != nil {
+		return nil, err
+	}
+	source.SetLogger(log.Default())
+	return source, nil
+}
+
+// fetchPrices returns today's and tomorrow's prices through the price cache,
+// flagging the response with X-Prices-Stale when the tariff's prices couldn't
+// be fetched and cached prices were served instead
+func (s *Server) fetchPrices(ctx context.Context, w http.ResponseWriter) ([]engine.PriceSlot, error) {
+	source, err := s.priceSource()
+	if err != nil {
+		return nil, err
+	}
+	priceSlots, stale, err := source.FetchTodayAndTomorrow(ctx)
+	if stale {
+		w.Header().Set("X-Prices-Stale", "true")
+	}
+	return priceSlots, err
+}
+
+// carbonIntensity fetches grid intensity covering slots when the household
+// weights carbon; failures are tolerated so cost-only planning still works
+func (s *Server) carbonIntensity(ctx context.Context, household *engine.Household, slots []engine.PriceSlot) []engine.CarbonSlot {
+	if household.CarbonWeight <= 0 {
+		return nil
+	}
+
+	carbonSlots, err := carbon.NewIntensityClient().ForPriceSlots(ctx, slots, household.Region)
+	if err != nil {
+		log.Printf("carbon intensity unavailable: %v", err)
+		return nil
+	}
+	return carbonSlots
+}
+
+// pvForecast estimates solar generation for the next N days when the
+// household has panels; failures are tolerated like carbon lookups
+func (s *Server) pvForecast(ctx context.Context, household *engine.Household, days int) []engine.PVSlot {
+	if household.PVKWp <= 0 {
+		return nil
+	}
+
+	array := weather.PVArray{KWp: household.PVKWp, TiltDeg: household.PVTiltDeg, AzimuthDeg: household.PVAzimuthDeg}
+	pvSlots, err := weather.NewOpenMeteoClient(household.Latitude, household.Longitude).PVForecast(ctx, array, days)
+	if err != nil {
+		log.Printf("solar forecast unavailable: %v", err)
+		return nil
+	}
+	return pvSlots
+}
+
+// exportPrices fetches what the household is paid for exported solar when it
+// has panels and an export tariff; failures are tolerated like PV forecasts,
+// leaving solar valued by PVWeight
+func (s *Server) exportPrices(ctx context.Context, household *engine.Household, pvSlots []engine.PVSlot) []engine.PriceSlot {
+	if len(pvSlots) == 0 {
+		return nil
+	}
+
+	source, err := prices.ExportForHousehold(household, s.store)
+	if err != nil || source == nil {
+		if err != nil {
+			log.Printf("export prices unavailable: %v", err)
+		}
+		return nil
+	}
+	source.SetLogger(log.Default())
+	slots, _, err := source.FetchTodayAndTomorrow(ctx)
+	if err != nil {
+		log.Printf("export prices unavailable: %v", err)
+		return nil
+	}
+	return slots
+}
+
+// predictPrices fills in the part of [from, to) after the published slots with
+// prices predicted from the cache, using the wind forecast when Open-Meteo can
+// be reached. Without enough history nothing is predicted.
+func (s *Server) predictPrices(ctx context.Context, source *prices.CachingProvider, published []engine.PriceSlot, from, to time.Time) []engine.PriceSlot {
+	if n := len(published); n > 0 {
+		from = published[n-1].End
+	}
+	if !from.Before(to) {
+		return nil
+	}
+
+	days := int(math.Ceil(time.Until(to).Hours()/24)) + 1
+	wind, err := weather.NewOpenMeteoClient(weather.GBWindLatitude, weather.GBWindLongitude).WindForecast(ctx, days)
+	if err != nil {
+		log.Printf("wind forecast unavailable: %v", err)
+	}
+	predicted, err := source.Predict(from, to, wind)
+	if err != nil {
+		log.Printf("prices not predicted: %v", err)
+		return nil
+	}
+	return predicted
+}
+
+// matchMeteredRuns fills in the smart-meter reading for runs when an Octopus
+// account has been synced; without readings runs are left as they are
+func (s *Server) matchMeteredRuns(runs []*engine.Run, days int) {
+	mpan, _, err := s.store.GetSetting(prices.MPANSetting)
+	if err != nil {
+		return
+	}
+	consumption, err := s.store.GetConsumption(mpan, time.Now().AddDate(0, 0, -days-1), time.Now())
+	if err != nil {
+		log.Printf("meter readings unavailable: %v", err)
+		return
 	}
-	return household.Region
+
+	var baseLoad []float64
+	if household, err := s.store.GetHousehold("default"); err == nil {
+		baseLoad = household.BaseLoadKW
+	}
+	engine.MatchMeteredRuns(runs, consumption, baseLoad)
+}
+
+// runHistory returns when an appliance has run over the past week; lookup
+// failures are logged and treated as no runs
+func (s *Server) runHistory(applianceID string, now time.Time) []time.Time {
+	history, err := s.store.GetRunHistory(applianceID, engine.WeekStart(now))
+	if err != nil {
+		log.Printf("run history unavailable for %s: %v", applianceID, err)
+		return nil
+	}
+	return history
 }
 
 func (s *Server) Handler() http.Handler {
@@ -69,29 +213,56 @@ func (s *Server) Handler() http.Handler {
 		r.Get("/appliances/{id}", s.handleGetAppliance)
 		r.Put("/appliances/{id}", s.handleUpdateAppliance)
 		r.Delete("/appliances/{id}", s.handleDeleteAppliance)
+		r.Put("/appliances/{id}/profile", s.handleImportProfile)
+		r.Post("/appliances/{id}/runs", s.handleRecordRun)
 		r.Post("/recommendations", s.handleGetRecommendations)
 		r.Post("/smart-recommendations", s.handleSmartRecommendations)
+		r.Post("/household-plan", s.handleHouseholdPlan)
+		r.Get("/battery-schedule", s.handleBatterySchedule)
 		r.Get("/weather", s.handleGetWeather)
+		r.Get("/runs", s.handleGetRuns)
+		r.Post("/runs", s.handleStartRun)
+		r.Post("/runs/{id}/finish", s.handleFinishRun)
+		r.Get("/reports/savings", s.handleSavingsReport)
 	})
 
 	return r
 }
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
-	region := s.getRegion()
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	household, err := s.store.GetHousehold("default")
+	if err != nil {
+		household = &engine.Household{Region: "C"}
+	}
+	if household.Region == "" {
+		household.Region = "C"
+	}
+
+	status := map[string]interface{}{
 		"status":  "ok",
 		"version": "1.0.0",
-		"region":  region,
-	})
+		"region":  household.Region,
+		"tariff":  engine.TariffAgile,
+	}
+	if household.Tariff != "" {
+		status["tariff"] = household.Tariff
+	}
+	if household.Tariff == engine.TariffAgile || household.Tariff == "" {
+		client := prices.NewAgileClient(household, s.store)
+		client.SetLogger(log.Default())
+		product, source := client.Product(r.Context())
+		status["agile_product"] = product
+		status["agile_product_source"] = source
+	}
+	if number, _, err := s.store.GetSetting(prices.AccountNumberSetting); err == nil {
+		status["octopus_account"] = number
+	}
+
+	respondJSON(w, http.StatusOK, status)
 }
 
 func (s *Server) handleGetPrices(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	region := s.getRegion()
-	client := prices.NewOctopusClient(region)
-
-	priceSlots, err := client.FetchTodayAndTomorrow(ctx, region)
+	priceSlots, err := s.fetchPrices(r.Context(), w)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -120,12 +291,25 @@ func (s *Server) handleGetHousehold(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleUpdateHousehold(w http.ResponseWriter, r *http.Request) {
+	// Start from the stored household so fields the UI doesn't send are kept
 	var household engine.Household
+	if existing, err := s.store.GetHousehold("default"); err == nil {
+		household = *existing
+	}
 	if err := json.NewDecoder(r.Body).Decode(&household); err != nil {
 		respondError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
+	if _, err := prices.ForHousehold(&household, nil); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := prices.ExportForHousehold(&household, nil); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	household.ID = "default"
 	if err := s.store.SaveHousehold(&household); err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -177,7 +361,11 @@ func (s *Server) handleGetAppliance(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleUpdateAppliance(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
+	// Start from the stored appliance so fields the UI doesn't send are kept
 	var appliance engine.Appliance
+	if existing, err := s.store.GetAppliance(id); err == nil {
+		appliance = *existing
+	}
 	if err := json.NewDecoder(r.Body).Decode(&appliance); err != nil {
 		respondError(w, http.StatusBadRequest, "invalid request body")
 		return
@@ -201,6 +389,285 @@ func (s *Server) handleDeleteAppliance(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"message": "deleted", "id": id})
 }
 
+// handleRecordRun logs a run that has just finished, for the UI's "Ran it"
+// button: it ended now, or CycleMinutes after started_at if given, and used
+// the appliance's estimated energy
+func (s *Server) handleRecordRun(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	appliance, err := s.store.GetAppliance(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "appliance not found")
+		return
+	}
+
+	var req struct {
+		StartedAt *time.Time `json:"started_at"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	cycle := time.Duration(appliance.CycleMinutes) * time.Minute
+	end := time.Now()
+	if req.StartedAt != nil {
+		end = req.StartedAt.Add(cycle)
+	}
+	run := &engine.Run{ApplianceID: id, Start: end.Add(-cycle), End: &end, KWh: appliance.EstKWh}
+
+	s.priceRun(r.Context(), run)
+	if err := s.store.SaveRun(run); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, run)
+}
+
+// priceRun costs a finished run from cached prices, fetching any missing
+// days; without prices the run is still logged, just uncosted
+func (s *Server) priceRun(ctx context.Context, run *engine.Run) {
+	from, to := run.PriceSpan()
+	source, err := s.priceSource()
+	var slots []engine.PriceSlot
+	if err == nil {
+		slots, err = source.Prices(ctx, from, to)
+	}
+	if err == nil {
+		err = engine.PriceRun(run, slots)
+	}
+	if err != nil {
+		log.Printf("could not cost run of %s: %v", run.ApplianceID, err)
+	}
+}
+
+// handleGetRuns lists logged runs, newest first, optionally for one appliance
+// (?appliance=) over the last ?days= days (default 30)
+func (s *Server) handleGetRuns(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed <= 0 {
+			respondError(w, http.StatusBadRequest, "days must be a positive number")
+			return
+		}
+		days = parsed
+	}
+
+	runs, err := s.store.GetRuns(r.URL.Query().Get("appliance"), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.matchMeteredRuns(runs, days)
+	respondJSON(w, http.StatusOK, runs)
+}
+
+// handleSavingsReport compares logged runs with a baseline: ?baseline=flat,
+// price_cap (default) or immediate, ?period=week (default) or month, ?days=
+// back (default 90), ?rate= to override the household's unit rate, and
+// ?appliance= to report on one appliance
+func (s *Server) handleSavingsReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	baseline := engine.BaselinePriceCap
+	if b := query.Get("baseline"); b != "" {
+		baseline = engine.Baseline(b)
+	}
+	period := engine.PeriodWeek
+	if p := query.Get("period"); p != "" {
+		period = engine.ReportPeriod(p)
+	}
+	days := 90
+	if d := query.Get("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed <= 0 {
+			respondError(w, http.StatusBadRequest, "days must be a positive number")
+			return
+		}
+		days = parsed
+	}
+
+	household, err := s.store.GetHousehold("default")
+	if err != nil {
+		respondError(w, http.StatusNotFound, "household not found")
+		return
+	}
+	rate := engine.BaselineRate(household, baseline)
+	if v := query.Get("rate"); v != "" {
+		rate, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "rate must be a number")
+			return
+		}
+	}
+
+	runs, err := s.store.GetRuns(query.Get("appliance"), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	names := make(map[string]string)
+	if appliances, err := s.store.GetAppliances("default"); err == nil {
+		for _, a := range appliances {
+			names[a.ID] = a.Name
+		}
+	}
+
+	report, err := engine.BuildSavingsReport(runs, names, baseline, rate, period)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// runRequest is the body for starting and finishing runs; every field but
+// appliance_id is optional
+type runRequest struct {
+	ApplianceID      string     `json:"appliance_id"`
+	LoadedAt         *time.Time `json:"loaded_at"`
+	Start            *time.Time `json:"start"`
+	End              *time.Time `json:"end"`
+	KWh              *float64   `json:"kwh"`
+	RecommendedStart *time.Time `json:"recommended_start"`
+}
+
+// handleStartRun logs that an appliance has started, now unless start is
+// given; a body with end as well logs a finished run in one go. A start in
+// the future (a delay timer) counts as loaded now unless loaded_at says otherwise.
+func (s *Server) handleStartRun(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	appliance, err := s.store.GetAppliance(req.ApplianceID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "appliance not found")
+		return
+	}
+
+	now := time.Now()
+	run := &engine.Run{ApplianceID: appliance.ID, Start: now, LoadedAt: req.LoadedAt, RecommendedStart: req.RecommendedStart}
+	if req.Start != nil {
+		run.Start = *req.Start
+	}
+	if run.LoadedAt == nil && run.Start.After(now) {
+		run.LoadedAt = &now
+	}
+	if req.End != nil {
+		if err := s.finishRun(r.Context(), run, appliance, req); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err := s.store.SaveRun(run); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, run)
+}
+
+// handleFinishRun ends a run, now unless end is given, and works out its cost
+func (s *Server) handleFinishRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid run id")
+		return
+	}
+	run, err := s.store.GetRun(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "run not found")
+		return
+	}
+
+	var req runRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if req.End == nil {
+		now := time.Now()
+		req.End = &now
+	}
+
+	appliance, err := s.store.GetAppliance(run.ApplianceID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "appliance not found")
+		return
+	}
+	if err := s.finishRun(r.Context(), run, appliance, req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.store.SaveRun(run); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
+
+// finishRun sets a run's end and energy, measured if the request gives it and
+// the appliance's estimate otherwise, then costs it
+func (s *Server) finishRun(ctx context.Context, run *engine.Run, appliance *engine.Appliance, req runRequest) error {
+	if !req.End.After(run.Start) {
+		return fmt.Errorf("run must end after it started")
+	}
+	end := *req.End
+	run.End = &end
+	run.KWh, run.Measured = appliance.EstKWh, false
+	if req.KWh != nil {
+		run.KWh, run.Measured = *req.KWh, true
+	}
+
+	s.priceRun(ctx, run)
+	return nil
+}
+
+// handleImportProfile replaces an appliance's power profile from a CSV body
+// of smart-plug readings
+func (s *Server) handleImportProfile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	appliance, err := s.store.GetAppliance(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "appliance not found")
+		return
+	}
+
+	segmentMinutes := 5
+	if v := r.URL.Query().Get("segment"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(w, http.StatusBadRequest, "invalid segment length")
+			return
+		}
+		segmentMinutes = n
+	}
+
+	profile, err := engine.ParsePowerProfileCSV(r.Body, segmentMinutes)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The profile is the cycle: its length and energy replace the estimates
+	appliance.PowerProfile = profile
+	appliance.EstKWh = engine.ProfileKWh(profile)
+	appliance.CycleMinutes = engine.ProfileMinutes(profile)
+	if err := s.store.SaveAppliance(appliance, "default"); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, appliance)
+}
+
 type RecommendationRequest struct {
 	ApplianceIDs []string `json:"appliance_ids"`
 }
@@ -208,17 +675,33 @@ type RecommendationRequest struct {
 type RecommendationResponse struct {
 	Appliance       string                  `json:"appliance"`
 	Recommendations []engine.Recommendation `json:"recommendations"`
+	EVCharge        *engine.EVChargePlan    `json:"ev_charge,omitempty"`
+	Unscheduled     string                  `json:"unscheduled,omitempty"` // Why the household plan has no run for it
 }
 
+// RecommendationsRequest asks for the household schedule, or with
+// PerAppliance set each appliance's own best windows, planned alone
+type RecommendationsRequest struct {
+	PerAppliance bool     `json:"per_appliance"`
+	MaxImportKW  *float64 `json:"max_import_kw"`
+}
+
+// handleGetRecommendations returns when to run each appliance due today, from
+// one household schedule so appliances don't all pile into the same cheap
+// slots beyond the household's power limit
 func (s *Server) handleGetRecommendations(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 
-	// Get region from household
-	region := s.getRegion()
+	var req RecommendationsRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
 
 	// Fetch prices
-	client := prices.NewOctopusClient(region)
-	priceSlots, err := client.FetchTodayAndTomorrow(ctx, region)
+	priceSlots, err := s.fetchPrices(ctx, w)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to fetch prices: "+err.Error())
 		return
@@ -238,68 +721,112 @@ func (s *Server) handleGetRecommendations(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Generate recommendations
-	results := []RecommendationResponse{}
-	currentDate := time.Now().Format("2006-01-02")
-
-	for _, a := range appliances {
-		if !a.Enabled {
-			continue
+	// Only plan over slots that haven't started yet
+	now := time.Now()
+	futureSlots := []engine.PriceSlot{}
+	for _, slot := range priceSlots {
+		if slot.Start.After(now) {
+			futureSlots = append(futureSlots, slot)
 		}
+	}
+	if len(futureSlots) == 0 {
+		respondJSON(w, http.StatusOK, []RecommendationResponse{})
+		return
+	}
 
-		// Check if we should show recommendation based on usage frequency
-		if !engine.ShouldShowRecommendation(a, "", currentDate) {
-			continue
-		}
+	loads := s.householdLoads(ctx, household, appliances, futureSlots, now)
+	if req.PerAppliance {
+		respondJSON(w, http.StatusOK, perApplianceRecommendations(loads, futureSlots, now))
+		return
+	}
 
-		constraints := engine.Constraints{
-			Allowed:       a.AllowedWindows,
-			Blocked:       a.BlockedWindows,
-			QuietHours:    household.QuietHours,
-			FinishBy:      a.FinishBy,
-			StartBy:       a.StartBy,
-			PriceCapPence: a.PriceCapPencePerKWh,
-			NoiseLevel:    a.NoiseLevel,
-		}
+	if req.MaxImportKW != nil {
+		household.MaxImportKW = *req.MaxImportKW
+	}
+	plan, err := engine.PlanHousehold(futureSlots, loads, household)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-		// Apply practical constraints based on control type
-		engine.ApplyPracticalConstraints(a, household, &constraints)
+	results := []RecommendationResponse{}
+	for _, run := range plan.Runs {
+		results = append(results, RecommendationResponse{
+			Appliance:       run.ApplianceName,
+			Recommendations: []engine.Recommendation{run.Recommendation},
+			EVCharge:        run.EVCharge,
+		})
+	}
+	for _, u := range plan.Unscheduled {
+		results = append(results, RecommendationResponse{
+			Appliance:       u.ApplianceName,
+			Recommendations: []engine.Recommendation{},
+			Unscheduled:     u.Reason,
+		})
+	}
 
-		opts := engine.Options{
-			EstKWh:       a.EstKWh,
-			CarbonWeight: household.CarbonWeight,
+	respondJSON(w, http.StatusOK, results)
+}
+
+// perApplianceRecommendations plans each load on its own, ignoring the
+// others: the best window left today and the best tomorrow, or for EVs and
+// other interruptible loads the best plan across both days
+func perApplianceRecommendations(loads []engine.HouseholdLoad, slots []engine.PriceSlot, now time.Time) []RecommendationResponse {
+	// Split slots into today and tomorrow
+	todayEnd := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
+	todaySlots := []engine.PriceSlot{}
+	tomorrowSlots := []engine.PriceSlot{}
+	for _, slot := range slots {
+		if slot.Start.Before(todayEnd) {
+			todaySlots = append(todaySlots, slot)
+		} else {
+			tomorrowSlots = append(tomorrowSlots, slot)
 		}
+	}
 
-		// Get recommendations for remaining TODAY and TOMORROW separately
-		now := time.Now()
-		todayEnd := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
-
-		// Split slots into today and tomorrow
-		todaySlots := []engine.PriceSlot{}
-		tomorrowSlots := []engine.PriceSlot{}
-		for _, slot := range priceSlots {
-			if slot.Start.After(now) && slot.Start.Before(todayEnd) {
-				todaySlots = append(todaySlots, slot)
-			} else if slot.Start.After(todayEnd) {
-				tomorrowSlots = append(tomorrowSlots, slot)
+	results := []RecommendationResponse{}
+	for _, load := range loads {
+		a := load.Appliance
+
+		// EVs charge to a state-of-charge target by their ready-by time
+		if load.EVCharge != nil {
+			plan, err := engine.PlanEVCharge(slots, *load.EVCharge, load.Constraints, load.Options)
+			if err != nil {
+				log.Printf("Skipping %s: %v", a.Name, err)
+				continue
 			}
+
+			resp := RecommendationResponse{Appliance: a.Name, Recommendations: []engine.Recommendation{}, EVCharge: plan}
+			if plan.Recommendation != nil {
+				resp.Recommendations = append(resp.Recommendations, *plan.Recommendation)
+			}
+			results = append(results, resp)
+			continue
 		}
 
 		var bestRecs []engine.Recommendation
 
-		// Get best for today (if any slots left today)
-		if len(todaySlots) > 0 {
-			todayRecs, err := engine.BestWindows(todaySlots, a.CycleMinutes, constraints, opts, 1)
-			if err == nil && len(todayRecs) > 0 {
-				bestRecs = append(bestRecs, todayRecs...)
+		// Interruptible loads plan across midnight in one go
+		if load.Options.Interruptible != nil {
+			recs, err := engine.BestWindows(slots, a.CycleMinutes, load.Constraints, load.Options, 1)
+			if err == nil {
+				bestRecs = append(bestRecs, recs...)
+			}
+		} else {
+			// Get best for today (if any slots left today)
+			if len(todaySlots) > 0 {
+				todayRecs, err := engine.BestWindows(todaySlots, a.CycleMinutes, load.Constraints, load.Options, 1)
+				if err == nil && len(todayRecs) > 0 {
+					bestRecs = append(bestRecs, todayRecs...)
+				}
 			}
-		}
 
-		// Get best for tomorrow
-		if len(tomorrowSlots) > 0 {
-			tomorrowRecs, err := engine.BestWindows(tomorrowSlots, a.CycleMinutes, constraints, opts, 1)
-			if err == nil && len(tomorrowRecs) > 0 {
-				bestRecs = append(bestRecs, tomorrowRecs...)
+			// Get best for tomorrow
+			if len(tomorrowSlots) > 0 {
+				tomorrowRecs, err := engine.BestWindows(tomorrowSlots, a.CycleMinutes, load.Constraints, load.Options, 1)
+				if err == nil && len(tomorrowRecs) > 0 {
+					bestRecs = append(bestRecs, tomorrowRecs...)
+				}
 			}
 		}
 
@@ -313,8 +840,183 @@ func (s *Server) handleGetRecommendations(w http.ResponseWriter, r *http.Request
 			Recommendations: bestRecs,
 		})
 	}
+	return results
+}
 
-	respondJSON(w, http.StatusOK, results)
+// householdLoads builds the load, with its constraints and options, of every
+// enabled appliance due to run, for planning over slots
+func (s *Server) householdLoads(ctx context.Context, household *engine.Household, appliances []*engine.Appliance, slots []engine.PriceSlot, now time.Time) []engine.HouseholdLoad {
+	carbonSlots := s.carbonIntensity(ctx, household, slots)
+	pvSlots := s.pvForecast(ctx, household, 2)
+	exportSlots := s.exportPrices(ctx, household, pvSlots)
+
+	loads := []engine.HouseholdLoad{}
+	for _, a := range appliances {
+		if !a.Enabled {
+			continue
+		}
+
+		constraints := engine.Constraints{
+			Allowed:          a.AllowedWindows,
+			Blocked:          a.BlockedWindows,
+			QuietHours:       household.QuietHours,
+			FinishBy:         a.FinishBy,
+			StartBy:          a.StartBy,
+			PriceCapPence:    a.PriceCapPencePerKWh,
+			NoiseLevel:       a.NoiseLevel,
+			ToleranceMinutes: a.ToleranceMinutes,
+		}
+
+		// Apply practical constraints based on control type
+		engine.ApplyPracticalConstraints(a, household, &constraints)
+
+		// Check if we should show recommendation based on usage frequency
+		if !engine.ShouldShowRecommendation(a, s.runHistory(a.ID, now), slots, constraints, now) {
+			continue
+		}
+
+		opts := engine.Options{
+			EstKWh:           a.EstKWh,
+			CarbonWeight:     household.CarbonWeight,
+			CarbonIntensity:  carbonSlots,
+			PVWeight:         household.PVWeight,
+			PVForecast:       pvSlots,
+			ExportPrices:     exportSlots,
+			PowerProfile:     a.PowerProfile,
+			StartStepMinutes: household.StartStepMinutes,
+			Interruptible:    engine.InterruptibleFor(a),
+			LatePenaltyPence: household.LatePenaltyPence,
+		}
+
+		// EVs charge to a state-of-charge target by their ready-by time
+		ev, err := engine.EVChargeFor(a, now)
+		if err != nil {
+			log.Printf("Skipping %s: %v", a.Name, err)
+			continue
+		}
+		if ev != nil {
+			ev.Apply(&constraints, &opts)
+		}
+
+		loads = append(loads, engine.HouseholdLoad{Appliance: a, Constraints: constraints, Options: opts, EVCharge: ev})
+	}
+	return loads
+}
+
+type HouseholdPlanRequest struct {
+	MaxImportKW *float64 `json:"max_import_kw"`
+}
+
+func (s *Server) handleHouseholdPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req HouseholdPlanRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	household, err := s.store.GetHousehold("default")
+	if err != nil {
+		respondError(w, http.StatusNotFound, "household not found")
+		return
+	}
+
+	appliances, err := s.store.GetAppliances("default")
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	priceSlots, err := s.fetchPrices(ctx, w)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to fetch prices: "+err.Error())
+		return
+	}
+
+	// Only plan over slots that haven't started yet
+	now := time.Now()
+	futureSlots := []engine.PriceSlot{}
+	for _, slot := range priceSlots {
+		if slot.Start.After(now) {
+			futureSlots = append(futureSlots, slot)
+		}
+	}
+	if len(futureSlots) == 0 {
+		respondError(w, http.StatusServiceUnavailable, "no upcoming price slots available")
+		return
+	}
+
+	loads := s.householdLoads(ctx, household, appliances, futureSlots, now)
+
+	if req.MaxImportKW != nil {
+		household.MaxImportKW = *req.MaxImportKW
+	}
+
+	plan, err := engine.PlanHousehold(futureSlots, loads, household)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, plan)
+}
+
+// handleBatterySchedule plans home battery charging and discharging from the
+// current state of charge, given as ?soc= in percent (default 50)
+func (s *Server) handleBatterySchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	soc := 50.0
+	if v := r.URL.Query().Get("soc"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 || n > 100 {
+			respondError(w, http.StatusBadRequest, "invalid state of charge")
+			return
+		}
+		soc = n
+	}
+
+	household, err := s.store.GetHousehold("default")
+	if err != nil {
+		respondError(w, http.StatusNotFound, "household not found")
+		return
+	}
+
+	battery := engine.HouseholdBattery(household, soc)
+	if battery == nil {
+		respondError(w, http.StatusBadRequest, "no battery configured")
+		return
+	}
+
+	priceSlots, err := s.fetchPrices(ctx, w)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to fetch prices: "+err.Error())
+		return
+	}
+
+	// Plan from the slot in progress onwards
+	now := time.Now()
+	upcoming := []engine.PriceSlot{}
+	for _, slot := range priceSlots {
+		if slot.End.After(now) {
+			upcoming = append(upcoming, slot)
+		}
+	}
+	if len(upcoming) == 0 {
+		respondError(w, http.StatusServiceUnavailable, "no upcoming price slots available")
+		return
+	}
+
+	schedule, err := engine.OptimiseBattery(upcoming, *battery, household.BaseLoadKW)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schedule)
 }
 
 func (s *Server) handleSmartRecommendations(w http.ResponseWriter, r *http.Request) {
@@ -335,7 +1037,8 @@ func (s *Server) handleSmartRecommendations(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Fetch weather forecast for next 3 days
-	weatherClient := weather.NewForecastClient(household.Latitude, household.Longitude)
+	weatherClient := weather.NewCachingForecastClient(household.Latitude, household.Longitude, s.store, s.weatherTTL)
+	weatherClient.SetLogger(log.Default())
 	forecasts, err := weatherClient.GetForecast(ctx, 3)
 	if err != nil {
 		// Continue without weather if forecast fails
@@ -350,19 +1053,32 @@ func (s *Server) handleSmartRecommendations(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Fetch prices for next 3 days
-	region := s.getRegion()
-	pricesClient := prices.NewOctopusClient(region)
+	source, err := s.priceSource()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	pricesByDay := make(map[string][]engine.PriceSlot)
-	for dayOffset := 0; dayOffset < 3; dayOffset++ {
-		day := time.Now().AddDate(0, 0, dayOffset)
-		dateStr := day.Format("2006-01-02")
+	// One request covers all three UK days; days not yet published are absent
+	from, to := prices.LocalDays(time.Now(), 3)
+	allSlots, stale, err := source.Range(ctx, from, to)
+	if err != nil && !errors.Is(err, prices.ErrNotPublished) {
+		log.Printf("prices unavailable: %v", err)
+	}
+	if stale {
+		w.Header().Set("X-Prices-Stale", "true")
+	}
+	// Days Octopus hasn't published yet are planned on predicted prices
+	allSlots = append(allSlots, s.predictPrices(ctx, source, allSlots, from, to)...)
 
-		dayPrices, err := pricesClient.HalfHourly(ctx, day, region)
-		if err == nil {
-			pricesByDay[dateStr] = dayPrices
-		}
+	pricesByDay := make(map[string][]engine.PriceSlot)
+	for _, slot := range allSlots {
+		date := prices.LocalDate(slot.Start)
+		pricesByDay[date] = append(pricesByDay[date], slot)
 	}
+	carbonSlots := s.carbonIntensity(ctx, household, allSlots)
+	pvSlots := s.pvForecast(ctx, household, 3)
+	exportSlots := s.exportPrices(ctx, household, pvSlots)
 
 	// Generate smart recommendations for coupled appliances only
 	smartResults := []engine.SmartRecommendation{}
@@ -372,12 +1088,6 @@ func (s *Server) handleSmartRecommendations(w http.ResponseWriter, r *http.Reque
 			continue
 		}
 
-		// Check if we should show recommendation based on usage frequency
-		currentDate := time.Now().Format("2006-01-02")
-		if !engine.ShouldShowRecommendation(a, "", currentDate) {
-			continue
-		}
-
 		// Find coupled appliance (dryer)
 		var coupledAppliance *engine.Appliance
 		if a.CoupledApplianceID != "" {
@@ -391,21 +1101,36 @@ func (s *Server) handleSmartRecommendations(w http.ResponseWriter, r *http.Reque
 
 		// Build constraints
 		constraints := engine.Constraints{
-			Allowed:       a.AllowedWindows,
-			Blocked:       a.BlockedWindows,
-			QuietHours:    household.QuietHours,
-			FinishBy:      a.FinishBy,
-			StartBy:       a.StartBy,
-			PriceCapPence: a.PriceCapPencePerKWh,
-			NoiseLevel:    a.NoiseLevel,
+			Allowed:          a.AllowedWindows,
+			Blocked:          a.BlockedWindows,
+			QuietHours:       household.QuietHours,
+			FinishBy:         a.FinishBy,
+			StartBy:          a.StartBy,
+			PriceCapPence:    a.PriceCapPencePerKWh,
+			NoiseLevel:       a.NoiseLevel,
+			ToleranceMinutes: a.ToleranceMinutes,
 		}
 
 		// Apply practical constraints
 		engine.ApplyPracticalConstraints(a, household, &constraints)
 
+		// Check if we should show recommendation based on usage frequency
+		now := time.Now()
+		if !engine.ShouldShowRecommendation(a, s.runHistory(a.ID, now), allSlots, constraints, now) {
+			continue
+		}
+
 		opts := engine.Options{
-			EstKWh:       a.EstKWh,
-			CarbonWeight: household.CarbonWeight,
+			EstKWh:           a.EstKWh,
+			CarbonWeight:     household.CarbonWeight,
+			CarbonIntensity:  carbonSlots,
+			PVWeight:         household.PVWeight,
+			PVForecast:       pvSlots,
+			ExportPrices:     exportSlots,
+			PowerProfile:     a.PowerProfile,
+			StartStepMinutes: household.StartStepMinutes,
+			Interruptible:    engine.InterruptibleFor(a),
+			LatePenaltyPence: household.LatePenaltyPence,
 		}
 
 		// Generate smart recommendations
@@ -448,7 +1173,8 @@ func (s *Server) handleGetWeather(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch 3-day weather forecast
-	weatherClient := weather.NewForecastClient(household.Latitude, household.Longitude)
+	weatherClient := weather.NewCachingForecastClient(household.Latitude, household.Longitude, s.store, s.weatherTTL)
+	weatherClient.SetLogger(log.Default())
 	forecasts, err := weatherClient.GetForecast(ctx, 3)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to fetch weather: "+err.Error())
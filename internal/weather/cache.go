@@ -0,0 +1,91 @@
+package weather
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// DefaultForecastTTL is how long a cached forecast is used before refreshing
+const DefaultForecastTTL = 3 * time.Hour
+
+// ForecastCache keeps daily forecasts by location; *store.Store satisfies it
+type ForecastCache interface {
+	CacheForecast(lat, lon float64, forecasts []engine.WeatherForecast) error
+	GetCachedForecast(lat, lon float64, from time.Time, days int) ([]engine.WeatherForecast, time.Time, error)
+}
+
+// CachingForecastClient serves forecasts from a cache while they are younger
+// than the TTL, and falls back to older cached forecasts when Open-Meteo can't
+// be reached. Locations are rounded to two decimal places (about 1 km) so
+// nearby coordinates share a cache entry.
+type CachingForecastClient struct {
+	client *ForecastClient
+	cache  ForecastCache
+	ttl    time.Duration
+	logger *log.Logger // Told of fallbacks and cache failures; nil = silent
+}
+
+// NewCachingForecastClient creates a cached forecast client for a location; a
+// ttl of zero or less uses DefaultForecastTTL
+func NewCachingForecastClient(lat, lon float64, cache ForecastCache, ttl time.Duration) *CachingForecastClient {
+	if ttl <= 0 {
+		ttl = DefaultForecastTTL
+	}
+	return &CachingForecastClient{
+		client: NewForecastClient(roundCoordinate(lat), roundCoordinate(lon)),
+		cache:  cache,
+		ttl:    ttl,
+	}
+}
+
+// SetLogger has problems the client works around rather than returns, such as
+// a forecast it couldn't cache or a failed fetch served from the cache,
+// reported to logger. Nothing is reported by default.
+func (c *CachingForecastClient) SetLogger(logger *log.Logger) {
+	c.logger = logger
+}
+
+// logf reports a problem worked around, when there is a logger to tell
+func (c *CachingForecastClient) logf(format string, v ...any) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+// GetForecast returns the forecast for the next N days, from the cache when
+// it is fresh enough
+func (c *CachingForecastClient) GetForecast(ctx context.Context, days int) ([]engine.WeatherForecast, error) {
+	today := time.Now().Format("2006-01-02")
+	from, _ := time.Parse("2006-01-02", today)
+
+	cached, fetchedAt, err := c.cache.GetCachedForecast(c.client.lat, c.client.lon, from, days)
+	if err != nil {
+		c.logf("reading cached forecast: %v", err)
+	}
+	if len(cached) >= days && time.Since(fetchedAt) < c.ttl {
+		return cached, nil
+	}
+
+	forecasts, err := c.client.GetForecast(ctx, days)
+	if err != nil {
+		if len(cached) > 0 {
+			c.logf("serving cached forecast: %v", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if err := c.cache.CacheForecast(c.client.lat, c.client.lon, forecasts); err != nil {
+		c.logf("caching forecast: %v", err)
+	}
+	return forecasts, nil
+}
+
+// roundCoordinate rounds a latitude or longitude to two decimal places
+func roundCoordinate(deg float64) float64 {
+	return math.Round(deg*100) / 100
+}
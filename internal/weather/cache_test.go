@@ -0,0 +1,78 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+// memoryForecastCache is an in-memory ForecastCache that records the keys used
+type memoryForecastCache struct {
+	forecasts []engine.WeatherForecast
+	fetchedAt time.Time
+	lat, lon  float64
+}
+
+func (m *memoryForecastCache) CacheForecast(lat, lon float64, forecasts []engine.WeatherForecast) error {
+	m.lat, m.lon = lat, lon
+	m.forecasts, m.fetchedAt = forecasts, time.Now()
+	return nil
+}
+
+func (m *memoryForecastCache) GetCachedForecast(lat, lon float64, from time.Time, days int) ([]engine.WeatherForecast, time.Time, error) {
+	return m.forecasts, m.fetchedAt, nil
+}
+
+func TestCachingForecastClient(t *testing.T) {
+	requests := 0
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !up {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if !strings.Contains(r.URL.RawQuery, "latitude=51.5100&longitude=-0.1300") {
+			t.Errorf("location not rounded: %s", r.URL.RawQuery)
+		}
+		today := time.Now().Format("2006-01-02")
+		fmt.Fprintf(w, `{"daily":{"time":[%q],"temperature_2m_max":[18],"temperature_2m_min":[9],`+
+			`"precipitation_probability_max":[10],"sunshine_duration":[21600]}}`, today)
+	}))
+	defer srv.Close()
+
+	cache := &memoryForecastCache{}
+	c := NewCachingForecastClient(51.5074, -0.1278, cache, time.Hour)
+	c.client.baseURL = srv.URL
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		forecasts, err := c.GetForecast(ctx, 1)
+		if err != nil || len(forecasts) != 1 || !forecasts[0].IsSunny {
+			t.Fatalf("got %+v, %v; want one sunny day", forecasts, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests within the TTL, want 1", requests)
+	}
+	if cache.lat != 51.51 || cache.lon != -0.13 {
+		t.Errorf("cached under %.4f,%.4f, want 51.51,-0.13", cache.lat, cache.lon)
+	}
+
+	// Expired but Open-Meteo unreachable: the old forecast is still served
+	cache.fetchedAt = time.Now().Add(-2 * time.Hour)
+	up = false
+	forecasts, err := c.GetForecast(ctx, 1)
+	if err != nil || len(forecasts) != 1 {
+		t.Errorf("offline: got %+v, %v; want the cached forecast", forecasts, err)
+	}
+	if requests != 2 {
+		t.Errorf("expired forecast not refreshed")
+	}
+}
@@ -14,15 +14,17 @@ const openMeteoAPI = "https://api.open-meteo.com/v1/forecast"
 
 // ForecastClient fetches weather forecasts
 type ForecastClient struct {
-	lat float64
-	lon float64
+	baseURL string
+	lat     float64
+	lon     float64
 }
 
 // NewForecastClient creates a weather forecast client for a location
 func NewForecastClient(lat, lon float64) *ForecastClient {
 	return &ForecastClient{
-		lat: lat,
-		lon: lon,
+		baseURL: openMeteoAPI,
+		lat:     lat,
+		lon:     lon,
 	}
 }
 
@@ -39,7 +41,7 @@ type dailyForecastResponse struct {
 // GetForecast fetches weather forecast for next N days
 func (c *ForecastClient) GetForecast(ctx context.Context, days int) ([]engine.WeatherForecast, error) {
 	url := fmt.Sprintf("%s?latitude=%.4f&longitude=%.4f&daily=temperature_2m_max,temperature_2m_min,precipitation_probability_max,sunshine_duration&timezone=Europe/London&forecast_days=%d",
-		openMeteoAPI, c.lat, c.lon, days)
+		c.baseURL, c.lat, c.lon, days)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
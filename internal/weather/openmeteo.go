@@ -126,3 +126,125 @@ func absDuration(d time.Duration) time.Duration {
 	}
 	return d
 }
+
+// radiationResponse represents the hourly irradiance API response
+type radiationResponse struct {
+	Hourly struct {
+		Time               []string  `json:"time"`
+		ShortwaveRadiation []float64 `json:"shortwave_radiation"`
+	} `json:"hourly"`
+}
+
+// PVForecast estimates half-hourly solar generation for the next N days from
+// forecast shortwave radiation and the panel geometry
+func (c *OpenMeteoClient) PVForecast(ctx context.Context, array PVArray, days int) ([]engine.PVSlot, error) {
+	params := url.Values{}
+	params.Add("latitude", fmt.Sprintf("%.4f", c.latitude))
+	params.Add("longitude", fmt.Sprintf("%.4f", c.longitude))
+	params.Add("hourly", "shortwave_radiation")
+	params.Add("forecast_days", fmt.Sprintf("%d", days))
+	params.Add("timezone", "UTC")
+
+	fullURL := fmt.Sprintf("%s?%s", openMeteoAPIBase, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching radiation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var radResp radiationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&radResp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	times := make([]time.Time, 0, len(radResp.Hourly.Time))
+	ghi := make([]float64, 0, len(radResp.Hourly.Time))
+	for i, ts := range radResp.Hourly.Time {
+		if i >= len(radResp.Hourly.ShortwaveRadiation) {
+			break
+		}
+		t, err := time.Parse("2006-01-02T15:04", ts)
+		if err != nil {
+			continue
+		}
+		times = append(times, t)
+		ghi = append(ghi, radResp.Hourly.ShortwaveRadiation[i])
+	}
+
+	return pvSlotsFromRadiation(array, c.latitude, c.longitude, times, ghi), nil
+}
+
+// A point near the middle of Great Britain's wind fleet, whose forecast
+// stands in for national wind generation when predicting prices
+const (
+	GBWindLatitude  = 55.0
+	GBWindLongitude = -3.0
+)
+
+// windResponse represents the hourly hub-height wind API response
+type windResponse struct {
+	Hourly struct {
+		Time         []string  `json:"time"`
+		WindSpeed100 []float64 `json:"wind_speed_100m"`
+	} `json:"hourly"`
+}
+
+// WindForecast fetches the hourly wind speed at 100 m, turbine hub height, in
+// m/s for the next N days. Only Time and WindMps are set on the slots.
+func (c *OpenMeteoClient) WindForecast(ctx context.Context, days int) ([]engine.WeatherSlot, error) {
+	params := url.Values{}
+	params.Add("latitude", fmt.Sprintf("%.4f", c.latitude))
+	params.Add("longitude", fmt.Sprintf("%.4f", c.longitude))
+	params.Add("hourly", "wind_speed_100m")
+	params.Add("wind_speed_unit", "ms")
+	params.Add("forecast_days", fmt.Sprintf("%d", days))
+	params.Add("timezone", "UTC")
+
+	fullURL := fmt.Sprintf("%s?%s", openMeteoAPIBase, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching wind: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var windResp windResponse
+	if err := json.NewDecoder(resp.Body).Decode(&windResp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	slots := make([]engine.WeatherSlot, 0, len(windResp.Hourly.Time))
+	for i, ts := range windResp.Hourly.Time {
+		if i >= len(windResp.Hourly.WindSpeed100) {
+			break
+		}
+		t, err := time.Parse("2006-01-02T15:04", ts)
+		if err != nil {
+			continue
+		}
+		slots = append(slots, engine.WeatherSlot{Time: t, WindMps: windResp.Hourly.WindSpeed100[i]})
+	}
+
+	return slots, nil
+}
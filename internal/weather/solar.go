@@ -0,0 +1,118 @@
+package weather
+
+import (
+	"math"
+	"time"
+
+	"github.com/awaistahir/smart-run/internal/engine"
+)
+
+const (
+	// Share of global horizontal irradiance assumed to be diffuse
+	diffuseFraction = 0.3
+	// Ground reflectance for grass/roofs
+	groundAlbedo = 0.2
+	// System losses (inverter, wiring, temperature, soiling)
+	performanceRatio = 0.85
+)
+
+// PVArray describes a rooftop solar installation
+type PVArray struct {
+	KWp        float64 // Peak DC rating
+	TiltDeg    float64 // Panel tilt from horizontal
+	AzimuthDeg float64 // Compass bearing the panels face; 180 = south
+}
+
+// EstimatePVOutput converts global horizontal irradiance (W/m²) at time t into
+// expected array output in kW, using a simple isotropic-sky transposition
+func EstimatePVOutput(array PVArray, lat, lon float64, t time.Time, ghi float64) float64 {
+	if array.KWp <= 0 || ghi <= 0 {
+		return 0
+	}
+
+	elevation, azimuth := sunPosition(lat, lon, t)
+	if elevation <= 0 {
+		return 0
+	}
+
+	zenith := 90 - elevation
+	tilt := array.TiltDeg * math.Pi / 180
+
+	// Angle between the sun and the panel normal
+	cosIncidence := math.Cos(zenith*math.Pi/180)*math.Cos(tilt) +
+		math.Sin(zenith*math.Pi/180)*math.Sin(tilt)*math.Cos((azimuth-array.AzimuthDeg)*math.Pi/180)
+
+	beam := ghi * (1 - diffuseFraction)
+	diffuse := ghi * diffuseFraction
+
+	poa := diffuse*(1+math.Cos(tilt))/2 + ghi*groundAlbedo*(1-math.Cos(tilt))/2
+	if cosIncidence > 0 && zenith < 85 {
+		poa += beam * cosIncidence / math.Cos(zenith*math.Pi/180)
+	}
+
+	kw := array.KWp * poa / 1000 * performanceRatio
+	return math.Min(kw, array.KWp)
+}
+
+// sunPosition returns solar elevation and compass azimuth in degrees (NOAA approximation)
+func sunPosition(lat, lon float64, t time.Time) (elevation, azimuth float64) {
+	t = t.UTC()
+	dayOfYear := float64(t.YearDay())
+	hour := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+
+	gamma := 2 * math.Pi / 365 * (dayOfYear - 1 + (hour-12)/24)
+
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(gamma) - 0.032077*math.Sin(gamma) -
+		0.014615*math.Cos(2*gamma) - 0.040849*math.Sin(2*gamma))
+	decl := 0.006918 - 0.399912*math.Cos(gamma) + 0.070257*math.Sin(gamma) -
+		0.006758*math.Cos(2*gamma) + 0.000907*math.Sin(2*gamma) -
+		0.002697*math.Cos(3*gamma) + 0.00148*math.Sin(3*gamma)
+
+	trueSolarMinutes := hour*60 + eqTime + 4*lon
+	hourAngle := (trueSolarMinutes/4 - 180) * math.Pi / 180
+
+	latRad := lat * math.Pi / 180
+	cosZenith := math.Sin(latRad)*math.Sin(decl) + math.Cos(latRad)*math.Cos(decl)*math.Cos(hourAngle)
+	cosZenith = math.Max(-1, math.Min(1, cosZenith))
+	zenith := math.Acos(cosZenith)
+
+	elevation = 90 - zenith*180/math.Pi
+
+	// Azimuth measured clockwise from north
+	sinZenith := math.Sin(zenith)
+	if sinZenith == 0 {
+		return elevation, 180
+	}
+	cosAz := (math.Sin(latRad)*cosZenith - math.Sin(decl)) / (math.Cos(latRad) * sinZenith)
+	cosAz = math.Max(-1, math.Min(1, cosAz))
+	az := math.Acos(cosAz) * 180 / math.Pi
+	if hourAngle > 0 {
+		azimuth = math.Mod(180+az, 360)
+	} else {
+		azimuth = math.Mod(540-az, 360)
+	}
+
+	return elevation, azimuth
+}
+
+// pvSlotsFromRadiation turns hourly mean irradiance readings, each covering the
+// hour before its timestamp, into half-hourly generation slots
+func pvSlotsFromRadiation(array PVArray, lat, lon float64, times []time.Time, ghi []float64) []engine.PVSlot {
+	slots := make([]engine.PVSlot, 0, len(times)*2)
+	for i, end := range times {
+		if i >= len(ghi) {
+			break
+		}
+		hourStart := end.Add(-time.Hour)
+		for half := 0; half < 2; half++ {
+			start := hourStart.Add(time.Duration(half) * 30 * time.Minute)
+			mid := start.Add(15 * time.Minute)
+			slots = append(slots, engine.PVSlot{
+				Start:        start,
+				End:          start.Add(30 * time.Minute),
+				GenerationKW: EstimatePVOutput(array, lat, lon, mid, ghi[i]),
+			})
+		}
+	}
+	return slots
+}
@@ -0,0 +1,48 @@
+package weather
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimatePVOutput(t *testing.T) {
+	array := PVArray{KWp: 4, TiltDeg: 35, AzimuthDeg: 180}
+	lat, lon := 51.5, -0.13
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		ghi     float64
+		wantMin float64
+		wantMax float64
+	}{
+		{name: "summer noon", at: time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), ghi: 800, wantMin: 2.5, wantMax: 4},
+		{name: "winter noon", at: time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), ghi: 200, wantMin: 0.5, wantMax: 2},
+		{name: "midnight", at: time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), ghi: 0, wantMin: 0, wantMax: 0},
+		{name: "no panels", at: time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), ghi: 800, wantMin: 0, wantMax: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := array
+			if tt.name == "no panels" {
+				a.KWp = 0
+			}
+			got := EstimatePVOutput(a, lat, lon, tt.at, tt.ghi)
+			if got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("EstimatePVOutput() = %.2f kW, want between %.2f and %.2f", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestSunPositionNoonSouth(t *testing.T) {
+	// Solar noon in London is within a few minutes of 12:00 UTC
+	elevation, azimuth := sunPosition(51.5, 0, time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC))
+	if elevation < 60 || elevation > 63 {
+		t.Errorf("midsummer noon elevation = %.1f, want ~62", elevation)
+	}
+	if azimuth < 170 || azimuth > 190 {
+		t.Errorf("noon azimuth = %.1f, want ~180", azimuth)
+	}
+}
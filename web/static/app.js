@@ -7,6 +7,8 @@ let appliances = [];
 let recommendations = [];
 let smartRecommendations = [];
 let priceChart = null;
+let statusText = 'Loading...';
+let pricesStale = false;
 
 // UK DNO Region mapping (approximate boundaries)
 const DNO_REGIONS = {
@@ -37,6 +39,7 @@ document.addEventListener('DOMContentLoaded', () => {
     loadRecommendations();
     loadSmartRecommendations();
     loadWeatherForecast();
+    loadSavingsReport();
 });
 
 // Tab Navigation
@@ -90,6 +93,7 @@ let editingApplianceId = null;
 function closeApplianceForm() {
     document.getElementById('add-appliance-form').style.display = 'none';
     document.getElementById('appliance-form').reset();
+    toggleInterruptibleFields();
     editingApplianceId = null;
     document.querySelector('#add-appliance-form h3').textContent = 'Add New Appliance';
     document.querySelector('#appliance-form button[type="submit"]').textContent = 'Add Appliance';
@@ -100,13 +104,22 @@ async function loadStatus() {
     try {
         const response = await fetch(`${API_BASE}/status`);
         const data = await response.json();
-        document.getElementById('status-text').textContent = `Connected - Region ${data.region}`;
+        statusText = `Connected - Region ${data.region}`;
+        if (data.agile_product) {
+            statusText += ` - ${data.agile_product}`;
+        }
+        renderStatus();
     } catch (error) {
         document.getElementById('status-text').textContent = 'Disconnected';
         console.error('Failed to load status:', error);
     }
 }
 
+function renderStatus() {
+    document.getElementById('status-text').textContent =
+        pricesStale ? `${statusText} - offline, showing cached prices` : statusText;
+}
+
 async function loadHousehold() {
     try {
         const response = await fetch(`${API_BASE}/household`);
@@ -116,9 +129,22 @@ async function loadHousehold() {
         if (household) {
             document.getElementById('household-name').value = household.Name || 'My Household';
             document.getElementById('household-region').value = household.Region || 'C';
+            document.getElementById('household-tariff').value = household.Tariff || 'agile';
+            document.getElementById('tariff-definition').value = household.TariffDefinition || '';
+            document.getElementById('agile-product').value = household.AgileProduct || '';
             document.getElementById('household-lat').value = household.Latitude || '';
             document.getElementById('household-lon').value = household.Longitude || '';
             document.getElementById('stagger-loads').checked = household.StaggerHeavyLoads || false;
+            document.getElementById('max-import-kw').value = household.MaxImportKW || '';
+            document.getElementById('late-penalty').value = household.LatePenaltyPence ?? 0.5;
+            document.getElementById('flat-rate').value = household.FlatRatePence || '';
+            document.getElementById('price-cap').value = household.PriceCapPence || '';
+            document.getElementById('carbon-weight').value = household.CarbonWeight || 0;
+            document.getElementById('pv-kwp').value = household.PVKWp || '';
+            document.getElementById('pv-tilt').value = household.PVTiltDeg || '';
+            document.getElementById('pv-azimuth').value = household.PVAzimuthDeg || '';
+            document.getElementById('export-tariff').value = household.ExportTariff || '';
+            document.getElementById('export-rate').value = household.ExportRatePence || '';
 
             if (household.QuietHours && household.QuietHours.length > 0) {
                 document.getElementById('quiet-start').value = household.QuietHours[0].Start || '22:00';
@@ -134,6 +160,8 @@ async function loadPrices() {
     try {
         const response = await fetch(`${API_BASE}/prices`);
         prices = await response.json();
+        pricesStale = response.headers.get('X-Prices-Stale') === 'true';
+        renderStatus();
         renderPriceChart();
         renderPricesTable();
     } catch (error) {
@@ -191,6 +219,9 @@ async function addAppliance() {
         Class: document.getElementById('appliance-class').value,
         CoupledApplianceID: document.getElementById('appliance-coupled').value,
         CanWaitDays: parseInt(document.getElementById('appliance-can-wait').value),
+        Interruptible: document.getElementById('appliance-interruptible').checked,
+        MinBlockMinutes: parseInt(document.getElementById('appliance-min-block').value) || 0,
+        MaxInterruptions: parseInt(document.getElementById('appliance-max-interruptions').value) || 0,
         Enabled: true,
         AllowedWindows: [],
         BlockedWindows: []
@@ -242,8 +273,12 @@ async function editAppliance(id) {
         document.getElementById('appliance-class').value = appliance.Class || 'standalone';
         document.getElementById('appliance-coupled').value = appliance.CoupledApplianceID || '';
         document.getElementById('appliance-can-wait').value = appliance.CanWaitDays || 0;
+        document.getElementById('appliance-interruptible').checked = appliance.Interruptible || false;
+        document.getElementById('appliance-min-block').value = appliance.MinBlockMinutes || 0;
+        document.getElementById('appliance-max-interruptions').value = appliance.MaxInterruptions || 0;
         updateWaitDaysLabel(appliance.CanWaitDays || 0);
         toggleCoupledFields();
+        toggleInterruptibleFields();
 
         // Update form UI
         editingApplianceId = id;
@@ -256,6 +291,21 @@ async function editAppliance(id) {
     }
 }
 
+async function markApplianceRun(id) {
+    try {
+        const response = await fetch(`${API_BASE}/appliances/${id}/runs`, {
+            method: 'POST'
+        });
+
+        if (response.ok) {
+            await loadRecommendations();
+        }
+    } catch (error) {
+        console.error('Failed to record run:', error);
+        alert('Failed to record run');
+    }
+}
+
 async function deleteAppliance(id) {
     if (!confirm('Are you sure you want to delete this appliance?')) {
         return;
@@ -336,16 +386,28 @@ async function saveSettings() {
     const household = {
         Name: document.getElementById('household-name').value,
         Region: document.getElementById('household-region').value,
+        Tariff: document.getElementById('household-tariff').value,
+        TariffDefinition: document.getElementById('tariff-definition').value,
+        AgileProduct: document.getElementById('agile-product').value.trim(),
         Latitude: parseFloat(document.getElementById('household-lat').value) || 0,
         Longitude: parseFloat(document.getElementById('household-lon').value) || 0,
         StaggerHeavyLoads: document.getElementById('stagger-loads').checked,
+        MaxImportKW: parseFloat(document.getElementById('max-import-kw').value) || 0,
+        LatePenaltyPence: parseFloat(document.getElementById('late-penalty').value) || 0,
+        FlatRatePence: parseFloat(document.getElementById('flat-rate').value) || 0,
+        PriceCapPence: parseFloat(document.getElementById('price-cap').value) || 0,
+        PVKWp: parseFloat(document.getElementById('pv-kwp').value) || 0,
+        PVTiltDeg: parseFloat(document.getElementById('pv-tilt').value) || 35,
+        PVAzimuthDeg: parseFloat(document.getElementById('pv-azimuth').value) || 180,
+        ExportTariff: document.getElementById('export-tariff').value,
+        ExportRatePence: parseFloat(document.getElementById('export-rate').value) || 0,
         QuietHours: [{
             Start: document.getElementById('quiet-start').value,
             End: document.getElementById('quiet-end').value,
             DaysOfWeek: [1, 2, 3, 4, 5, 6, 7]
         }],
         BlockedWindows: [],
-        CarbonWeight: 0.0
+        CarbonWeight: parseFloat(document.getElementById('carbon-weight').value) || 0
     };
 
     try {
@@ -357,7 +419,12 @@ async function saveSettings() {
 
         if (response.ok) {
             alert('Settings saved successfully!');
+            await loadPrices();
             await loadRecommendations();
+            await loadSavingsReport();
+        } else {
+            const data = await response.json();
+            alert(`Failed to save settings: ${data.error}`);
         }
     } catch (error) {
         console.error('Failed to save settings:', error);
@@ -405,10 +472,10 @@ function renderRecommendations() {
         });
 
         if (todayWindows.length > 0) {
-            todayRecs.push({ appliance: rec.appliance, recommendations: todayWindows });
+            todayRecs.push({ appliance: rec.appliance, recommendations: todayWindows, ev_charge: rec.ev_charge });
         }
         if (tomorrowWindows.length > 0) {
-            tomorrowRecs.push({ appliance: rec.appliance, recommendations: tomorrowWindows });
+            tomorrowRecs.push({ appliance: rec.appliance, recommendations: tomorrowWindows, ev_charge: rec.ev_charge });
         }
     });
 
@@ -424,6 +491,20 @@ function renderRecommendations() {
         html += tomorrowRecs.map((rec, index) => renderRecommendationCard(rec, index)).join('');
     }
 
+    // Appliances the household schedule had no room for, and why
+    const unscheduled = recommendations.filter(rec => rec.unscheduled);
+    if (unscheduled.length > 0) {
+        html += '<h2 style="margin-top: 2rem; margin-bottom: 1rem;">Not scheduled</h2>';
+        html += unscheduled.map(rec => `
+            <div class="recommendation-card">
+                <div class="rec-header">
+                    <div class="rec-appliance">${rec.appliance}</div>
+                </div>
+                <div class="rec-reason">⚠️ ${rec.unscheduled}</div>
+            </div>
+        `).join('');
+    }
+
     container.innerHTML = html;
 }
 
@@ -431,9 +512,14 @@ function renderRecommendationCard(rec, index) {
     const cost = rec.recommendations[0].CostGBP;
     const costStr = cost < 0 ? `+£${Math.abs(cost).toFixed(2)}` : `£${cost.toFixed(2)}`;
 
-    // Format time slots
+    // Format time slots; interruptible runs list each charging block
     let timeDisplay;
-    if (rec.recommendations.length === 1) {
+    const blocks = rec.recommendations[0].Blocks;
+    if (blocks && blocks.length > 1) {
+        timeDisplay = blocks.map(b =>
+            `${formatTime(b.Start)} - ${formatTime(b.End)}`
+        ).join(', ');
+    } else if (rec.recommendations.length === 1) {
         timeDisplay = `${formatTime(rec.recommendations[0].Start)} - ${formatTime(rec.recommendations[0].End)}`;
     } else {
         timeDisplay = rec.recommendations.map(w =>
@@ -450,6 +536,7 @@ function renderRecommendationCard(rec, index) {
                 <div class="best-time-slots">${timeDisplay}</div>
                 <div class="best-time-cost ${cost < 0 ? 'negative' : 'low'}">${costStr}</div>
             </div>
+            ${rec.ev_charge ? `<div class="rec-reason">Charges to ${rec.ev_charge.ExpectedSoC.toFixed(0)}%${rec.ev_charge.Warning ? ` - ⚠️ ${rec.ev_charge.Warning}` : ''}</div>` : ''}
         </div>
     `;
 }
@@ -483,6 +570,7 @@ function renderAppliances() {
                 </div>
             </div>
             <div class="appliance-actions">
+                <button class="btn btn-secondary btn-sm" onclick="markApplianceRun('${app.ID}')">Ran it</button>
                 <button class="btn btn-secondary btn-sm" onclick="editAppliance('${app.ID}')">Edit</button>
                 <button class="btn btn-danger btn-sm" onclick="deleteAppliance('${app.ID}')">Delete</button>
             </div>
@@ -591,6 +679,41 @@ function renderPricesTable() {
     }).join('');
 }
 
+async function loadSavingsReport() {
+    const container = document.getElementById('savings-report');
+    const baseline = document.getElementById('savings-baseline').value;
+    const period = document.getElementById('savings-period').value;
+
+    try {
+        const response = await fetch(`${API_BASE}/reports/savings?baseline=${baseline}&period=${period}`);
+        const report = await response.json();
+
+        if (!response.ok) {
+            container.innerHTML = `<div class="empty-state">${report.error || 'Report unavailable'}</div>`;
+            return;
+        }
+        if (!report.Rows || report.Rows.length === 0) {
+            container.innerHTML = '<div class="empty-state">No costed runs logged yet</div>';
+            return;
+        }
+
+        const row = (label, r) => `
+            <div class="price-row">
+                <span class="price-time">${label}${r.ApplianceName} (${r.Runs} runs, ${r.KWh.toFixed(1)} kWh)</span>
+                <span class="price-value ${r.SavingsGBP >= 0 ? 'low' : 'high'}">
+                    £${r.CostGBP.toFixed(2)} vs £${r.BaselineGBP.toFixed(2)}: saved £${r.SavingsGBP.toFixed(2)}
+                </span>
+            </div>
+        `;
+        container.innerHTML = `<div class="prices-table">
+            ${report.Rows.map(r => row(`${formatDate(r.PeriodStart)} - `, r)).join('')}
+            ${row('', report.Total)}
+        </div>`;
+    } catch (error) {
+        console.error('Failed to load savings report:', error);
+    }
+}
+
 // Utility Functions
 function formatTime(dateStr) {
     const date = new Date(dateStr);
@@ -601,6 +724,13 @@ function formatTime(dateStr) {
     });
 }
 
+function formatDate(dateStr) {
+    return new Date(dateStr).toLocaleDateString('en-GB', {
+        day: 'numeric',
+        month: 'short'
+    });
+}
+
 function formatDateTime(dateStr) {
     const date = new Date(dateStr);
     const today = new Date();
@@ -666,6 +796,7 @@ function renderSmartRecommendations() {
                             ${bestOption.UsesNaturalDry ? '☀️ Wash & line dry' : '🔥 Wash & tumble dry'}
                             <strong>£${bestOption.TotalCostGBP.toFixed(2)}</strong>
                             ${bestOption.SavingsVsToday > 0 ? `<span class="savings">Save £${bestOption.SavingsVsToday.toFixed(2)}!</span>` : ''}
+                            ${renderPredicted(bestOption)}
                         </div>
                         <div class="option-recommendation">${bestOption.Recommendation}</div>
                     </div>
@@ -680,6 +811,7 @@ function renderSmartRecommendations() {
                                 <div class="option-cost">
                                     ${opt.UsesNaturalDry ? '☀️ Wash & line dry' : '🔥 Wash & tumble dry'}
                                     £${opt.TotalCostGBP.toFixed(2)}
+                                    ${renderPredicted(opt)}
                                 </div>
                                 <div class="option-recommendation">${opt.Recommendation}</div>
                             </div>
@@ -691,6 +823,11 @@ function renderSmartRecommendations() {
     }).join('');
 }
 
+function renderPredicted(option) {
+    if (!option.Predicted) return '';
+    return `<span class="predicted">predicted prices, £${option.CostLowGBP.toFixed(2)}-£${option.CostHighGBP.toFixed(2)}</span>`;
+}
+
 function renderWeather(weather) {
     if (!weather) return '';
     return `
@@ -784,6 +921,11 @@ function toggleCoupledFields() {
     }
 }
 
+function toggleInterruptibleFields() {
+    const interruptible = document.getElementById('appliance-interruptible').checked;
+    document.getElementById('interruptible-group').style.display = interruptible ? 'grid' : 'none';
+}
+
 function updateCoupledApplianceDropdown() {
     const dropdown = document.getElementById('appliance-coupled');
     dropdown.innerHTML = '<option value="">None</option>';